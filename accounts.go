@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	accountActive       = "active"
+	accountSuspended    = "suspended"
+	accountShadowBanned = "shadow_banned"
+
+	// Moderation log actions for account state changes
+	actionShadowBan = "shadow_ban"
+	actionReinstate = "reinstate"
+)
+
+type accountStateResponse struct {
+	UserID           uuid.UUID  `json:"user_id"`
+	State            string     `json:"state"`
+	SuspendedUntil   *time.Time `json:"suspended_until,omitempty"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
+	ShadowBanned     bool       `json:"shadow_banned"`
+}
+
+func isSuspended(until sql.NullTime) bool {
+	return until.Valid && until.Time.After(time.Now())
+}
+
+func suspensionMessage(until sql.NullTime, reason sql.NullString) string {
+	msg := "Account suspended until " + until.Time.UTC().Format(time.RFC3339)
+	if reason.Valid && reason.String != "" {
+		msg += ": " + reason.String
+	}
+	return msg
+}
+
+func newAccountStateResponse(state database.GetAccountStateRow) accountStateResponse {
+	resp := accountStateResponse{
+		UserID:       state.ID,
+		State:        accountActive,
+		ShadowBanned: state.ShadowBannedAt.Valid,
+	}
+	if resp.ShadowBanned {
+		resp.State = accountShadowBanned
+	}
+	// A suspension outranks a shadow-ban while it lasts
+	if isSuspended(state.SuspendedUntil) {
+		resp.State = accountSuspended
+		resp.SuspendedUntil = &state.SuspendedUntil.Time
+		resp.SuspensionReason = state.SuspensionReason.String
+	}
+	return resp
+}
+
+// Rejects writes from suspended accounts; reports whether the request may
+// go on. Unknown users are let through for the handler to deal with.
+func (cfg *apiConfig) allowWrite(w http.ResponseWriter, userID uuid.UUID) bool {
+	state, err := cfg.dbQueries.GetAccountState(context.Background(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if isSuspended(state.SuspendedUntil) {
+		api.RespondWithError(w, suspensionMessage(state.SuspendedUntil, state.SuspensionReason), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// Reports whether the author of a chirp is shadow-banned, in which case only
+// the author may see it
+func (cfg *apiConfig) shadowBanned(ctx context.Context, userID uuid.UUID) (bool, error) {
+	ids, err := cfg.dbQueries.GetShadowBannedAmong(ctx, []uuid.UUID{userID})
+	return len(ids) > 0, err
+}
+
+func (cfg *apiConfig) GetAccountState(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	state, err := cfg.dbQueries.GetAccountState(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	history, err := cfg.dbQueries.GetModerationLogForUser(ctx, database.GetModerationLogForUserParams{
+		TargetUserID: uuid.NullUUID{UUID: userID, Valid: true},
+		Before:       before,
+		Lim:          limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, struct {
+		accountStateResponse
+		History []database.ModerationLog `json:"history"`
+	}{newAccountStateResponse(state), history}, http.StatusOK)
+}
+
+// Suspends, shadow-bans or reinstates a user. A reason is required and the
+// change is recorded in the moderation log.
+func (cfg *apiConfig) SetAccountState(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reqBody := struct {
+		State  string     `json:"state"`
+		Reason string     `json:"reason"`
+		Until  *time.Time `json:"until"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if reqBody.Reason == "" {
+		api.RespondWithError(w, "A reason is required", http.StatusBadRequest)
+		return
+	}
+	var action string
+	switch reqBody.State {
+	case accountActive:
+		action = actionReinstate
+	case accountSuspended:
+		if reqBody.Until == nil || !reqBody.Until.After(time.Now()) {
+			api.RespondWithError(w, "Suspensions need an until time in the future", http.StatusBadRequest)
+			return
+		}
+		action = moderationSuspend
+	case accountShadowBanned:
+		action = actionShadowBan
+	default:
+		api.RespondWithError(w, fmt.Sprintf("Unknown account state: %q", reqBody.State), http.StatusBadRequest)
+		return
+	}
+	adminID := userIDFromContext(r.Context())
+	if userID == adminID {
+		api.RespondWithError(w, "You cannot change your own account state", http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	if _, err := qtx.GetAccountState(ctx, userID); errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "User not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch reqBody.State {
+	case accountActive:
+		err = qtx.LiftAccountRestrictions(ctx, userID)
+	case accountSuspended:
+		err = qtx.SuspendUser(ctx, database.SuspendUserParams{
+			ID:               userID,
+			SuspendedUntil:   sql.NullTime{Time: *reqBody.Until, Valid: true},
+			SuspensionReason: sql.NullString{String: reqBody.Reason, Valid: true},
+		})
+	case accountShadowBanned:
+		err = qtx.ShadowBanUser(ctx, userID)
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, err = qtx.CreateModerationLogEntry(ctx, database.CreateModerationLogEntryParams{
+		ModeratorID:  adminID,
+		Action:       action,
+		TargetUserID: uuid.NullUUID{UUID: userID, Valid: true},
+		Reason:       reqBody.Reason,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	state, err := qtx.GetAccountState(ctx, userID)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, newAccountStateResponse(state), http.StatusOK)
+}
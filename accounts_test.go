@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newAdmin creates a user with the admin role and returns its token
+func (ts *testServer) newAdmin(handle string) string {
+	ts.t.Helper()
+	id, token := ts.createUser(handle)
+	if _, err := ts.cfg.db.Exec(`UPDATE users SET role = $2 WHERE id = $1`, id, roleAdmin); err != nil {
+		ts.t.Fatal(err)
+	}
+	return token
+}
+
+func (ts *testServer) setAccountState(adminToken string, userID uuid.UUID, state string, until *time.Time) {
+	ts.t.Helper()
+	ts.must(http.StatusOK, http.MethodPut, "/admin/users/"+userID.String()+"/account", adminToken,
+		map[string]any{"state": state, "reason": "testing", "until": until}, nil)
+}
+
+func TestSuspendedAccountsCanReadButNotWrite(t *testing.T) {
+	ts := newTestServer(t)
+	adminToken := ts.newAdmin("admin")
+	user, token := ts.createUser("suspended")
+	until := time.Now().Add(time.Hour)
+	ts.setAccountState(adminToken, user, accountSuspended, &until)
+
+	if status, body := ts.do(http.MethodPost, "/api/chirps", token, map[string]string{"body": "still here"}); status != http.StatusForbidden {
+		t.Errorf("posting while suspended: status %d, want 403: %s", status, body)
+	}
+	ts.must(http.StatusOK, http.MethodGet, "/api/chirps", token, nil, nil)
+
+	login := map[string]string{"email": "suspended@example.com", "password": "password"}
+	if status, body := ts.do(http.MethodPost, "/api/users/login", "", login); status != http.StatusForbidden {
+		t.Errorf("logging in while suspended: status %d, want 403: %s", status, body)
+	}
+
+	ts.setAccountState(adminToken, user, accountActive, nil)
+	ts.chirp(token, "back again", nil)
+	ts.must(http.StatusOK, http.MethodPost, "/api/users/login", "", login, nil)
+}
+
+func TestShadowBannedChirpsOnlyReachTheirAuthor(t *testing.T) {
+	ts := newTestServer(t)
+	adminToken := ts.newAdmin("admin")
+	user, token := ts.createUser("banned")
+	_, readerToken := ts.createUser("reader")
+	ts.setAccountState(adminToken, user, accountShadowBanned, nil)
+
+	c := ts.chirp(token, "can anyone hear me", nil)
+	path := "/api/chirps/" + c.ID.String()
+	ts.must(http.StatusOK, http.MethodGet, path, token, nil, nil)
+	if status, body := ts.do(http.MethodGet, path, readerToken, nil); status != http.StatusNotFound {
+		t.Errorf("reader: status %d, want 404: %s", status, body)
+	}
+	var chirps []chirpResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/chirps", readerToken, nil, &chirps)
+	if len(chirps) != 0 {
+		t.Errorf("reader's GET /api/chirps lists %d chirps, want none", len(chirps))
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type relationAction struct {
+	do      func(ctx context.Context, q *database.Queries, actor, target uuid.UUID) (int64, error)
+	changed string
+	same    string
+}
+
+var (
+	blockAction = relationAction{
+		do: func(ctx context.Context, q *database.Queries, actor, target uuid.UUID) (int64, error) {
+			n, err := q.BlockUser(ctx, database.BlockUserParams{BlockerID: actor, BlockedID: target})
+			if err != nil || n == 0 {
+				return n, err
+			}
+			// A block ends any follow relationship in either direction
+			return n, q.RemoveFollowsBetween(ctx, database.RemoveFollowsBetweenParams{A: actor, B: target})
+		},
+		changed: "Blocked",
+		same:    "Already blocked",
+	}
+	unblockAction = relationAction{
+		do: func(ctx context.Context, q *database.Queries, actor, target uuid.UUID) (int64, error) {
+			return q.UnblockUser(ctx, database.UnblockUserParams{BlockerID: actor, BlockedID: target})
+		},
+		changed: "Unblocked",
+		same:    "Not blocked",
+	}
+	muteAction = relationAction{
+		do: func(ctx context.Context, q *database.Queries, actor, target uuid.UUID) (int64, error) {
+			return q.MuteUser(ctx, database.MuteUserParams{MuterID: actor, MutedID: target})
+		},
+		changed: "Muted",
+		same:    "Already muted",
+	}
+	unmuteAction = relationAction{
+		do: func(ctx context.Context, q *database.Queries, actor, target uuid.UUID) (int64, error) {
+			return q.UnmuteUser(ctx, database.UnmuteUserParams{MuterID: actor, MutedID: target})
+		},
+		changed: "Unmuted",
+		same:    "Not muted",
+	}
+)
+
+func (cfg *apiConfig) BlockUser(w http.ResponseWriter, r *http.Request) {
+	cfg.applyRelationAction(w, r, blockAction)
+}
+
+func (cfg *apiConfig) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	cfg.applyRelationAction(w, r, unblockAction)
+}
+
+func (cfg *apiConfig) MuteUser(w http.ResponseWriter, r *http.Request) {
+	cfg.applyRelationAction(w, r, muteAction)
+}
+
+func (cfg *apiConfig) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	cfg.applyRelationAction(w, r, unmuteAction)
+}
+
+func (cfg *apiConfig) applyRelationAction(w http.ResponseWriter, r *http.Request, action relationAction) {
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	if targetID == userID {
+		api.RespondWithError(w, "You cannot do that to yourself", http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+
+	users, err := cfg.dbQueries.GetUsersByIDs(ctx, []uuid.UUID{targetID})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(users) == 0 {
+		api.RespondWithError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	n, err := action.do(ctx, cfg.dbQueries.WithTx(tx), userID, targetID)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		api.RespondWithJSON(w, action.same, http.StatusOK)
+		return
+	}
+	api.RespondWithJSON(w, action.changed, http.StatusOK)
+}
+
+type relatedUser struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) ListBlocks(w http.ResponseWriter, r *http.Request) {
+	rows, err := cfg.dbQueries.ListBlocks(context.Background(), userIDFromContext(r.Context()))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]relatedUser, len(rows))
+	for i, row := range rows {
+		resp[i] = relatedUser{UserID: row.BlockedID, CreatedAt: row.CreatedAt}
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+func (cfg *apiConfig) ListMutes(w http.ResponseWriter, r *http.Request) {
+	rows, err := cfg.dbQueries.ListMutes(context.Background(), userIDFromContext(r.Context()))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]relatedUser, len(rows))
+	for i, row := range rows {
+		resp[i] = relatedUser{UserID: row.MutedID, CreatedAt: row.CreatedAt}
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+// hasBlocked reports whether blocker has blocked user
+func hasBlocked(ctx context.Context, q *database.Queries, blocker, user uuid.UUID) (bool, error) {
+	ids, err := q.GetBlockedAmong(ctx, database.GetBlockedAmongParams{
+		BlockerID: blocker,
+		UserIds:   []uuid.UUID{user},
+	})
+	return len(ids) > 0, err
+}
+
+// hiddenAuthors is the set of authors whose chirps the viewer never sees
+func (cfg *apiConfig) hiddenAuthors(ctx context.Context, viewer uuid.NullUUID) (map[uuid.UUID]struct{}, error) {
+	if !viewer.Valid {
+		return nil, nil
+	}
+	ids, err := cfg.dbQueries.GetHiddenAuthorIDs(ctx, viewer.UUID)
+	if err != nil {
+		return nil, err
+	}
+	return toSet(ids), nil
+}
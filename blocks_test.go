@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBlockedUsersCannotReachTheBlocker(t *testing.T) {
+	ts := newTestServer(t)
+	blocker, blockerToken := ts.createUser("blocker")
+	pest, pestToken := ts.createUser("pest")
+	c := ts.chirp(blockerToken, "leave me be", nil)
+	ts.must(http.StatusOK, http.MethodPost, "/api/users/"+pest.String()+"/block", blockerToken, nil, nil)
+
+	for _, tc := range []struct {
+		what   string
+		method string
+		path   string
+		body   any
+	}{
+		{"reply", http.MethodPost, "/api/chirps", map[string]any{"body": "hey", "reply_to_id": c.ID}},
+		{"quote", http.MethodPost, "/api/chirps", map[string]any{"body": "look", "quote_of_id": c.ID}},
+		{"like", http.MethodPost, "/api/chirps/" + c.ID.String() + "/like", nil},
+		{"rechirp", http.MethodPost, "/api/chirps/" + c.ID.String() + "/rechirp", nil},
+		{"follow", http.MethodPost, "/api/users/" + blocker.String() + "/follow", nil},
+		{"message", http.MethodPost, "/api/conversations", map[string]any{"member_ids": []any{blocker}, "body": "hey"}},
+	} {
+		if status, body := ts.do(tc.method, tc.path, pestToken, tc.body); status != http.StatusForbidden {
+			t.Errorf("%s: status %d, want 403: %s", tc.what, status, body)
+		}
+	}
+
+	ts.chirp(pestToken, "you can't see this", nil)
+	var chirps []chirpResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/chirps", blockerToken, nil, &chirps)
+	for _, got := range chirps {
+		if got.UserID == pest {
+			t.Errorf("blocker's GET /api/chirps lists a chirp by the blocked user")
+		}
+	}
+}
+
+func TestPinsOfBlockedOrMutedUsersAreHidden(t *testing.T) {
+	ts := newTestServer(t)
+	author, authorToken := ts.createUser("author")
+	_, blockerToken := ts.createUser("blocker")
+	_, muterToken := ts.createUser("muter")
+	c := ts.chirp(authorToken, "pinned", nil)
+	ts.must(http.StatusCreated, http.MethodPost, "/api/chirps/"+c.ID.String()+"/pin", authorToken, nil, nil)
+	ts.must(http.StatusOK, http.MethodPost, "/api/users/"+author.String()+"/block", blockerToken, nil, nil)
+	ts.must(http.StatusOK, http.MethodPost, "/api/users/"+author.String()+"/mute", muterToken, nil, nil)
+
+	for who, token := range map[string]string{"blocker": blockerToken, "muter": muterToken} {
+		var profile profileResponse
+		ts.must(http.StatusOK, http.MethodGet, "/api/users/author", token, nil, &profile)
+		if len(profile.PinnedChirps) != 0 {
+			t.Errorf("%s sees %d pins, want none", who, len(profile.PinnedChirps))
+		}
+	}
+	var profile profileResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/users/author", "", nil, &profile)
+	if len(profile.PinnedChirps) != 1 {
+		t.Errorf("anonymous viewer sees %d pins, want 1", len(profile.PinnedChirps))
+	}
+}
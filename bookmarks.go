@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const maxCollectionName = 50
+
+// Bookmarks and collections are private, so unlike likes they notify
+// nobody and appear only to their owner
+func (cfg *apiConfig) BookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.applyChirpAction(w, r, chirpAction{
+		do: func(ctx context.Context, userID, chirpID uuid.UUID) (int64, error) {
+			return cfg.dbQueries.BookmarkChirp(ctx, database.BookmarkChirpParams{UserID: userID, ChirpID: chirpID})
+		},
+		changedStatus: http.StatusCreated,
+	})
+}
+
+// Also takes the chirp out of all the caller's collections. A bookmark can
+// be removed even once its chirp is hidden or no longer visible to the
+// caller, answering 204 as the chirp itself can't be shown.
+func (cfg *apiConfig) UnbookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.applyChirpAction(w, r, chirpAction{
+		do: func(ctx context.Context, userID, chirpID uuid.UUID) (int64, error) {
+			return cfg.dbQueries.UnbookmarkChirp(ctx, database.UnbookmarkChirpParams{UserID: userID, ChirpID: chirpID})
+		},
+		changedStatus:    http.StatusOK,
+		ignoreVisibility: true,
+	})
+}
+
+// The caller's bookmarks, most recently saved first. Pages are keyed on
+// saved_at rather than created_at.
+func (cfg *apiConfig) ListBookmarks(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+	rows, err := cfg.dbQueries.GetBookmarks(ctx, database.GetBookmarksParams{
+		UserID: userID,
+		Before: before,
+		Lim:    limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	chirps := make([]database.Chirp, len(rows))
+	savedAt := make([]time.Time, len(rows))
+	for i, row := range rows {
+		chirps[i], savedAt[i] = row.Chirp, row.SavedAt
+	}
+	cfg.respondWithSavedChirps(ctx, w, userID, chirps, savedAt)
+}
+
+func (cfg *apiConfig) respondWithSavedChirps(ctx context.Context, w http.ResponseWriter, userID uuid.UUID, chirps []database.Chirp, savedAt []time.Time) {
+	resp, err := cfg.buildChirpResponses(ctx, uuid.NullUUID{UUID: userID, Valid: true}, chirps)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range resp {
+		resp[i].SavedAt = &savedAt[i]
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+type collectionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	ChirpCount int64     `json:"chirp_count"`
+}
+
+func toCollectionResponse(c database.Collection, count int64) collectionResponse {
+	return collectionResponse{
+		ID:         c.ID,
+		Name:       c.Name,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		ChirpCount: count,
+	}
+}
+
+// Reads and checks the name from a create or rename request
+func decodeCollectionName(r *http.Request) (string, error) {
+	reqBody := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(reqBody.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxCollectionName {
+		return "", fmt.Errorf("Name must be 1-%d characters", maxCollectionName)
+	}
+	return name, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func (cfg *apiConfig) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	name, err := decodeCollectionName(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c, err := cfg.dbQueries.CreateCollection(context.Background(), database.CreateCollectionParams{
+		UserID: userIDFromContext(r.Context()),
+		Name:   name,
+	})
+	if isUniqueViolation(err) {
+		api.RespondWithError(w, "You already have a collection with that name", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, toCollectionResponse(c, 0), http.StatusCreated)
+}
+
+func (cfg *apiConfig) ListCollections(w http.ResponseWriter, r *http.Request) {
+	rows, err := cfg.dbQueries.ListCollections(context.Background(), userIDFromContext(r.Context()))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]collectionResponse, len(rows))
+	for i, row := range rows {
+		resp[i] = toCollectionResponse(row.Collection, row.ChirpCount)
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+func (cfg *apiConfig) RenameCollection(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name, err := decodeCollectionName(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+	n, err := cfg.dbQueries.RenameCollection(ctx, database.RenameCollectionParams{
+		ID:     id,
+		UserID: userID,
+		Name:   name,
+	})
+	if isUniqueViolation(err) {
+		api.RespondWithError(w, "You already have a collection with that name", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		api.RespondWithError(w, "Collection not found", http.StatusNotFound)
+		return
+	}
+	cfg.respondWithCollection(ctx, w, id, userID, http.StatusOK)
+}
+
+func (cfg *apiConfig) respondWithCollection(ctx context.Context, w http.ResponseWriter, id, userID uuid.UUID, status int) {
+	row, err := cfg.dbQueries.GetCollection(ctx, database.GetCollectionParams{ID: id, UserID: userID})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, toCollectionResponse(row.Collection, row.ChirpCount), status)
+}
+
+// Deletes the collection but keeps the bookmarks in it
+func (cfg *apiConfig) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := cfg.dbQueries.DeleteCollection(context.Background(), database.DeleteCollectionParams{
+		ID:     id,
+		UserID: userIDFromContext(r.Context()),
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		api.RespondWithError(w, "Collection not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists a collection's chirps, most recently added first, paged on saved_at
+func (cfg *apiConfig) GetCollectionChirps(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+	if _, err := cfg.dbQueries.GetCollection(ctx, database.GetCollectionParams{ID: id, UserID: userID}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			api.RespondWithError(w, "Collection not found", http.StatusNotFound)
+			return
+		}
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rows, err := cfg.dbQueries.GetCollectionChirps(ctx, database.GetCollectionChirpsParams{
+		CollectionID: id,
+		UserID:       userID,
+		Before:       before,
+		Lim:          limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	chirps := make([]database.Chirp, len(rows))
+	savedAt := make([]time.Time, len(rows))
+	for i, row := range rows {
+		chirps[i], savedAt[i] = row.Chirp, row.SavedAt
+	}
+	cfg.respondWithSavedChirps(ctx, w, userID, chirps, savedAt)
+}
+
+// Adds a chirp to a collection, bookmarking it first if need be. Adding it
+// again is a no-op (200).
+func (cfg *apiConfig) AddToCollection(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+
+	chirp, err := cfg.dbQueries.GetChirpByID(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	visible, err := cfg.canViewChirp(ctx, uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if _, err := cfg.dbQueries.GetCollection(ctx, database.GetCollectionParams{ID: id, UserID: userID}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			api.RespondWithError(w, "Collection not found", http.StatusNotFound)
+			return
+		}
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	if _, err := qtx.BookmarkChirp(ctx, database.BookmarkChirpParams{UserID: userID, ChirpID: chirpID}); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	n, err := qtx.AddToCollection(ctx, database.AddToCollectionParams{
+		ChirpID:      chirpID,
+		CollectionID: id,
+		UserID:       userID,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if n > 0 {
+		status = http.StatusCreated
+	}
+	cfg.respondWithCollection(ctx, w, id, userID, status)
+}
+
+// Takes a chirp out of a collection. It stays bookmarked.
+func (cfg *apiConfig) RemoveFromCollection(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := cfg.dbQueries.RemoveFromCollection(context.Background(), database.RemoveFromCollectionParams{
+		CollectionID: id,
+		UserID:       userIDFromContext(r.Context()),
+		ChirpID:      chirpID,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		api.RespondWithError(w, "Chirp is not in this collection", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBookmarksArePrivateToTheirOwner(t *testing.T) {
+	ts := newTestServer(t)
+	_, authorToken := ts.createUser("author")
+	_, saverToken := ts.createUser("saver")
+	c := ts.chirp(authorToken, "worth keeping", nil)
+	path := "/api/chirps/" + c.ID.String() + "/bookmark"
+
+	var saved chirpResponse
+	ts.must(http.StatusCreated, http.MethodPost, path, saverToken, nil, &saved)
+	if !saved.BookmarkedByMe {
+		t.Error("bookmarked chirp has bookmarked_by_me unset")
+	}
+	ts.must(http.StatusOK, http.MethodPost, path, saverToken, nil, nil)
+
+	var mine, theirs []chirpResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/users/me/bookmarks", saverToken, nil, &mine)
+	ts.must(http.StatusOK, http.MethodGet, "/api/users/me/bookmarks", authorToken, nil, &theirs)
+	if len(mine) != 1 || mine[0].ID != c.ID || len(theirs) != 0 {
+		t.Errorf("saver has %d bookmarks, author %d; want 1 and 0", len(mine), len(theirs))
+	}
+	var seen chirpResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/chirps/"+c.ID.String(), authorToken, nil, &seen)
+	if seen.BookmarkedByMe {
+		t.Error("author sees the saver's bookmark as their own")
+	}
+}
+
+func TestBookmarkOfHiddenChirpCanBeRemoved(t *testing.T) {
+	ts := newTestServer(t)
+	_, authorToken := ts.createUser("author")
+	_, saverToken := ts.createUser("saver")
+	moderator, modToken := ts.createUser("mod")
+	if _, err := ts.cfg.db.Exec(`UPDATE users SET role = $2 WHERE id = $1`, moderator, roleModerator); err != nil {
+		t.Fatal(err)
+	}
+	c := ts.chirp(authorToken, "soon to be hidden", nil)
+	path := "/api/chirps/" + c.ID.String() + "/bookmark"
+	ts.must(http.StatusCreated, http.MethodPost, path, saverToken, nil, nil)
+	ts.must(http.StatusOK, http.MethodPost, "/admin/moderation/chirps/"+c.ID.String(), modToken,
+		map[string]string{"action": moderationHide}, nil)
+
+	var bookmarks []chirpResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/users/me/bookmarks", saverToken, nil, &bookmarks)
+	if len(bookmarks) != 0 {
+		t.Errorf("hidden chirp is still listed among %d bookmarks", len(bookmarks))
+	}
+	if status, body := ts.do(http.MethodPost, path, saverToken, nil); status != http.StatusNotFound {
+		t.Errorf("bookmarking a hidden chirp: status %d, want 404: %s", status, body)
+	}
+	if status, body := ts.do(http.MethodDelete, path, saverToken, nil); status != http.StatusNoContent || len(body) != 0 {
+		t.Errorf("removing the bookmark: status %d, want 204: %s", status, body)
+	}
+	if status, body := ts.do(http.MethodDelete, path, saverToken, nil); status != http.StatusNotFound {
+		t.Errorf("removing it again: status %d, want 404: %s", status, body)
+	}
+}
+
+func TestBookmarksOfBlockedOrMutedAuthorsAreNotListed(t *testing.T) {
+	ts := newTestServer(t)
+	for _, action := range []string{"block", "mute"} {
+		author, authorToken := ts.createUser(action + "ed")
+		_, saverToken := ts.createUser(action + "er")
+		c := ts.chirp(authorToken, "worth keeping", nil)
+		var collection collectionResponse
+		ts.must(http.StatusCreated, http.MethodPost, "/api/collections", saverToken, map[string]string{"name": "keep"}, &collection)
+		ts.must(http.StatusCreated, http.MethodPut, "/api/collections/"+collection.ID.String()+"/chirps/"+c.ID.String(), saverToken, nil, nil)
+		ts.must(http.StatusOK, http.MethodPost, "/api/users/"+author.String()+"/"+action, saverToken, nil, nil)
+
+		for _, path := range []string{"/api/users/me/bookmarks", "/api/collections/" + collection.ID.String() + "/chirps"} {
+			var listed []chirpResponse
+			ts.must(http.StatusOK, http.MethodGet, path, saverToken, nil, &listed)
+			if len(listed) != 0 {
+				t.Errorf("after a %s, %s lists %d chirps, want none", action, path, len(listed))
+			}
+		}
+	}
+}
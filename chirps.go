@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/Lewvy/chirpy/internal/linkpreview"
+	"github.com/google/uuid"
+)
+
+type chirpResponse struct {
+	database.Chirp
+	LikedByMe      bool            `json:"liked_by_me"`
+	RechirpedByMe  bool            `json:"rechirped_by_me"`
+	BookmarkedByMe bool            `json:"bookmarked_by_me"`
+	RechirpedBy    *uuid.UUID      `json:"rechirped_by,omitempty"`
+	QuotedChirp    any             `json:"quoted_chirp,omitempty"`
+	Entities       chirpEntities   `json:"entities"`
+	Media          []mediaResponse `json:"media"`
+	// Set while any attached media is still being processed
+	Processing  bool                 `json:"processing"`
+	LinkPreview *linkpreview.Preview `json:"link_preview,omitempty"`
+	Poll        *pollResponse        `json:"poll,omitempty"`
+	// When the chirp was bookmarked or added to the collection being
+	// listed; it is the cursor for the next page
+	SavedAt *time.Time `json:"saved_at,omitempty"`
+	// On timelines, when the chirp was posted or, for a rechirp, rechirped;
+	// it is the cursor for the next page
+	ActivityAt *time.Time `json:"activity_at,omitempty"`
+}
+
+// Stands in for a quoted chirp that no longer exists or cannot be shown
+type chirpTombstone struct {
+	ID        uuid.UUID `json:"id"`
+	Tombstone bool      `json:"tombstone"`
+	Reason    string    `json:"reason"`
+}
+
+const (
+	tombstoneDeleted     = "deleted"
+	tombstoneUnavailable = "unavailable"
+)
+
+// Decorates chirps with the viewer's like/rechirp state. viewer is invalid for
+// anonymous requests, in which case every flag is false.
+func (cfg *apiConfig) buildChirpResponses(ctx context.Context, viewer uuid.NullUUID, chirps []database.Chirp) ([]chirpResponse, error) {
+	resp := make([]chirpResponse, len(chirps))
+	ids := make([]uuid.UUID, len(chirps))
+	for i, chirp := range chirps {
+		resp[i] = chirpResponse{Chirp: chirp}
+		ids[i] = chirp.ID
+	}
+	if err := cfg.attachQuotedChirps(ctx, viewer, resp); err != nil {
+		return nil, err
+	}
+	if err := cfg.attachEntities(ctx, resp); err != nil {
+		return nil, err
+	}
+	if err := cfg.attachMedia(ctx, resp); err != nil {
+		return nil, err
+	}
+	if err := cfg.attachLinkPreviews(ctx, resp); err != nil {
+		return nil, err
+	}
+	if err := cfg.attachPolls(ctx, viewer, resp); err != nil {
+		return nil, err
+	}
+	if !viewer.Valid || len(chirps) == 0 {
+		return resp, nil
+	}
+
+	liked, err := cfg.dbQueries.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{
+		UserID:   viewer.UUID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rechirped, err := cfg.dbQueries.GetRechirpedChirpIDs(ctx, database.GetRechirpedChirpIDsParams{
+		UserID:   viewer.UUID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bookmarked, err := cfg.dbQueries.GetBookmarkedChirpIDs(ctx, database.GetBookmarkedChirpIDsParams{
+		UserID:   viewer.UUID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	likedSet := toSet(liked)
+	rechirpedSet := toSet(rechirped)
+	bookmarkedSet := toSet(bookmarked)
+	for i := range resp {
+		_, resp[i].LikedByMe = likedSet[resp[i].ID]
+		_, resp[i].RechirpedByMe = rechirpedSet[resp[i].ID]
+		_, resp[i].BookmarkedByMe = bookmarkedSet[resp[i].ID]
+	}
+	return resp, nil
+}
+
+func (cfg *apiConfig) buildChirpResponse(ctx context.Context, viewer uuid.NullUUID, chirp database.Chirp) (chirpResponse, error) {
+	resp, err := cfg.buildChirpResponses(ctx, viewer, []database.Chirp{chirp})
+	if err != nil {
+		return chirpResponse{}, err
+	}
+	return resp[0], nil
+}
+
+// Inlines quoted chirps one level deep; quotes of quotes only carry quote_of_id.
+// Chirps by authors the viewer blocked, or that the viewer may not see, are
+// replaced with a tombstone.
+func (cfg *apiConfig) attachQuotedChirps(ctx context.Context, viewer uuid.NullUUID, resp []chirpResponse) error {
+	var quotedIDs []uuid.UUID
+	for _, c := range resp {
+		if c.QuoteOfID.Valid {
+			quotedIDs = append(quotedIDs, c.QuoteOfID.UUID)
+		}
+	}
+	if len(quotedIDs) == 0 {
+		return nil
+	}
+
+	quoted, err := cfg.dbQueries.GetChirpsByIDs(ctx, quotedIDs)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]database.Chirp, len(quoted))
+	authors := make([]uuid.UUID, len(quoted))
+	for i, q := range quoted {
+		byID[q.ID] = q
+		authors[i] = q.UserID
+	}
+	var blocked map[uuid.UUID]struct{}
+	if viewer.Valid {
+		ids, err := cfg.dbQueries.GetBlockedAmong(ctx, database.GetBlockedAmongParams{
+			BlockerID: viewer.UUID,
+			UserIds:   authors,
+		})
+		if err != nil {
+			return err
+		}
+		blocked = toSet(ids)
+	}
+	visible, err := cfg.visibleChirpIDs(ctx, viewer, quotedIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range resp {
+		if !resp[i].QuoteOfID.Valid {
+			continue
+		}
+		id := resp[i].QuoteOfID.UUID
+		q, ok := byID[id]
+		_, hidden := blocked[q.UserID]
+		if _, allowed := visible[id]; !allowed {
+			hidden = true
+		}
+		switch {
+		case ok && hidden:
+			resp[i].QuotedChirp = chirpTombstone{ID: id, Tombstone: true, Reason: tombstoneUnavailable}
+		case ok:
+			resp[i].QuotedChirp = q
+		default:
+			resp[i].QuotedChirp = chirpTombstone{ID: id, Tombstone: true, Reason: tombstoneDeleted}
+		}
+	}
+	return nil
+}
+
+func toSet(ids []uuid.UUID) map[uuid.UUID]struct{} {
+	set := make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
+
+// Fans a chirp that has just become visible out to trending, live streams,
+// webhooks and federation, and queues its link for a preview. Streams check
+// each subscriber; the rest have no viewer, so only public chirps reach them.
+func (cfg *apiConfig) announceChirp(resp chirpResponse, tags []string) {
+	queueLinkPreview(resp.Body)
+	go cfg.publishChirp(resp)
+	if resp.Visibility != visibilityPublic {
+		return
+	}
+	go cfg.recordTrending(tags, resp.CreatedAt)
+	go cfg.emitWebhookEvent(webhookChirpCreated, resp)
+	go cfg.federateChirp(resp.Chirp)
+}
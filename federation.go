@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/activitypub"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// federationStore adapts the generated queries to activitypub.Store
+type federationStore struct {
+	q *database.Queries
+}
+
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return activitypub.ErrNotFound
+	}
+	return err
+}
+
+func (s federationStore) LocalUserByHandle(ctx context.Context, handle string) (activitypub.LocalUser, error) {
+	row, err := s.q.GetFederatedUserByHandle(ctx, handle)
+	if err != nil {
+		return activitypub.LocalUser{}, notFound(err)
+	}
+	return activitypub.LocalUser{
+		ID:            row.ID,
+		Handle:        row.Handle.String,
+		PublicKeyPEM:  row.PublicKeyPem.String,
+		PrivateKeyPEM: row.PrivateKeyPem.String,
+	}, nil
+}
+
+func (s federationStore) LocalUserByID(ctx context.Context, id uuid.UUID) (activitypub.LocalUser, error) {
+	row, err := s.q.GetFederatedUserByID(ctx, id)
+	if err != nil {
+		return activitypub.LocalUser{}, notFound(err)
+	}
+	return activitypub.LocalUser{
+		ID:            row.ID,
+		Handle:        row.Handle.String,
+		PublicKeyPEM:  row.PublicKeyPem.String,
+		PrivateKeyPEM: row.PrivateKeyPem.String,
+	}, nil
+}
+
+func (s federationStore) SaveKeys(ctx context.Context, userID uuid.UUID, publicPEM, privatePEM string) (string, string, error) {
+	row, err := s.q.SaveActorKeys(ctx, database.SaveActorKeysParams{
+		UserID:        userID,
+		PublicKeyPem:  publicPEM,
+		PrivateKeyPem: privatePEM,
+	})
+	return row.PublicKeyPem, row.PrivateKeyPem, err
+}
+
+func localNote(c database.Chirp) activitypub.LocalNote {
+	return activitypub.LocalNote{ID: c.ID, UserID: c.UserID, Content: c.Body, Published: c.CreatedAt}
+}
+
+func (s federationStore) LocalNote(ctx context.Context, id uuid.UUID) (activitypub.LocalNote, error) {
+	chirp, err := s.q.GetChirpByID(ctx, id)
+	if err != nil {
+		return activitypub.LocalNote{}, notFound(err)
+	}
+	// No viewer: only chirps anyone may read are federated
+	visible, err := s.q.GetVisibleChirpIDs(ctx, database.GetVisibleChirpIDsParams{Ids: []uuid.UUID{id}})
+	if err != nil {
+		return activitypub.LocalNote{}, err
+	}
+	if len(visible) == 0 || chirp.Visibility != visibilityPublic {
+		return activitypub.LocalNote{}, activitypub.ErrNotFound
+	}
+	return localNote(chirp), nil
+}
+
+func (s federationStore) LocalNotes(ctx context.Context, userID uuid.UUID, limit int) ([]activitypub.LocalNote, error) {
+	chirps, err := s.q.GetChirpsByUser(ctx, database.GetChirpsByUserParams{UserID: userID, Limit: int32(limit)})
+	if err != nil {
+		return nil, err
+	}
+	notes := make([]activitypub.LocalNote, len(chirps))
+	for i, c := range chirps {
+		notes[i] = localNote(c)
+	}
+	return notes, nil
+}
+
+func (s federationStore) AddFollower(ctx context.Context, userID uuid.UUID, f activitypub.Follower) error {
+	return s.q.AddRemoteFollower(ctx, database.AddRemoteFollowerParams{UserID: userID, ActorID: f.ActorID, Inbox: f.Inbox})
+}
+
+func (s federationStore) RemoveFollower(ctx context.Context, userID uuid.UUID, actorID string) error {
+	return s.q.RemoveRemoteFollower(ctx, database.RemoveRemoteFollowerParams{UserID: userID, ActorID: actorID})
+}
+
+func (s federationStore) Followers(ctx context.Context, userID uuid.UUID) ([]activitypub.Follower, error) {
+	rows, err := s.q.GetRemoteFollowers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	followers := make([]activitypub.Follower, len(rows))
+	for i, row := range rows {
+		followers[i] = activitypub.Follower{ActorID: row.ActorID, Inbox: row.Inbox}
+	}
+	return followers, nil
+}
+
+func (s federationStore) AddFollowing(ctx context.Context, userID uuid.UUID, actorID string) error {
+	return s.q.AddRemoteFollowing(ctx, database.AddRemoteFollowingParams{UserID: userID, ActorID: actorID})
+}
+
+func (s federationStore) AcceptFollowing(ctx context.Context, userID uuid.UUID, actorID string) error {
+	n, err := s.q.AcceptRemoteFollowing(ctx, database.AcceptRemoteFollowingParams{UserID: userID, ActorID: actorID})
+	if err == nil && n == 0 {
+		return activitypub.ErrNotFound
+	}
+	return err
+}
+
+func (s federationStore) RemoveFollowing(ctx context.Context, userID uuid.UUID, actorID string) error {
+	return s.q.RemoveRemoteFollowing(ctx, database.RemoveRemoteFollowingParams{UserID: userID, ActorID: actorID})
+}
+
+func (s federationStore) IsFollowedLocally(ctx context.Context, actorID string) (bool, error) {
+	return s.q.IsRemoteActorFollowed(ctx, actorID)
+}
+
+func (s federationStore) RemoteActor(ctx context.Context, id string) (activitypub.RemoteActor, error) {
+	a, err := s.q.GetRemoteActor(ctx, id)
+	if err != nil {
+		return activitypub.RemoteActor{}, notFound(err)
+	}
+	return activitypub.RemoteActor{
+		ID:                a.ID,
+		PreferredUsername: a.PreferredUsername,
+		Inbox:             a.Inbox,
+		SharedInbox:       a.SharedInbox.String,
+		KeyID:             a.KeyID,
+		PublicKeyPEM:      a.PublicKeyPem,
+		FetchedAt:         a.FetchedAt,
+	}, nil
+}
+
+func (s federationStore) SaveRemoteActor(ctx context.Context, a activitypub.RemoteActor) error {
+	return s.q.UpsertRemoteActor(ctx, database.UpsertRemoteActorParams{
+		ID:                a.ID,
+		PreferredUsername: a.PreferredUsername,
+		Inbox:             a.Inbox,
+		SharedInbox:       sql.NullString{String: a.SharedInbox, Valid: a.SharedInbox != ""},
+		KeyID:             a.KeyID,
+		PublicKeyPem:      a.PublicKeyPEM,
+		FetchedAt:         a.FetchedAt,
+	})
+}
+
+func (s federationStore) SaveRemoteNote(ctx context.Context, n activitypub.RemoteNote) error {
+	return s.q.CreateRemoteNote(ctx, database.CreateRemoteNoteParams{
+		ID:        n.ID,
+		ActorID:   n.ActorID,
+		Content:   n.Content,
+		Published: n.Published,
+	})
+}
+
+func (s federationStore) DeleteRemoteNote(ctx context.Context, id, actorID string) error {
+	return s.q.DeleteRemoteNote(ctx, database.DeleteRemoteNoteParams{ID: id, ActorID: actorID})
+}
+
+func (s federationStore) QueueDelivery(ctx context.Context, d activitypub.Delivery) error {
+	return s.q.QueueRemoteDelivery(ctx, database.QueueRemoteDeliveryParams{UserID: d.UserID, Inbox: d.Inbox, Body: d.Body})
+}
+
+func (s federationStore) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]activitypub.Delivery, error) {
+	rows, err := s.q.ClaimDueRemoteDeliveries(ctx, database.ClaimDueRemoteDeliveriesParams{
+		LeaseSeconds: int32(lease / time.Second),
+		Batch:        int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]activitypub.Delivery, len(rows))
+	for i, row := range rows {
+		deliveries[i] = activitypub.Delivery{
+			ID:       row.ID,
+			UserID:   row.UserID,
+			Inbox:    row.Inbox,
+			Body:     row.Body,
+			Attempts: int(row.Attempts),
+		}
+	}
+	return deliveries, nil
+}
+
+func (s federationStore) DeliverySucceeded(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteRemoteDelivery(ctx, id)
+}
+
+func (s federationStore) DeliveryFailed(ctx context.Context, id uuid.UUID, next time.Time, giveUp bool, reason string) error {
+	status := "pending"
+	if giveUp {
+		status = "failed"
+	}
+	return s.q.MarkRemoteDeliveryFailed(ctx, database.MarkRemoteDeliveryFailedParams{
+		ID:            id,
+		Status:        status,
+		LastError:     sql.NullString{String: reason, Valid: true},
+		NextAttemptAt: next,
+	})
+}
+
+// federateChirp delivers a new chirp to the author's remote followers
+func (cfg *apiConfig) federateChirp(chirp database.Chirp) {
+	err := cfg.federation.PublishNote(context.Background(), localNote(chirp))
+	if err != nil && !errors.Is(err, activitypub.ErrNotFederated) {
+		log.Println("Error federating chirp: ", err)
+	}
+}
+
+func (cfg *apiConfig) federateChirpDeletion(chirp database.Chirp) {
+	err := cfg.federation.DeleteNote(context.Background(), chirp.UserID, chirp.ID)
+	if err != nil && !errors.Is(err, activitypub.ErrNotFederated) {
+		log.Println("Error federating chirp deletion: ", err)
+	}
+}
+
+func (cfg *apiConfig) remoteFollowAction(w http.ResponseWriter, r *http.Request, follow bool) {
+	var body struct {
+		Account string `json:"account"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+
+	var err error
+	if follow {
+		var remote activitypub.RemoteActor
+		remote, err = cfg.federation.Follow(r.Context(), userID, body.Account)
+		if err == nil {
+			api.RespondWithJSON(w, struct {
+				Actor  string `json:"actor"`
+				Status string `json:"status"`
+			}{Actor: remote.ID, Status: "pending"}, http.StatusAccepted)
+			return
+		}
+	} else {
+		err = cfg.federation.Unfollow(r.Context(), userID, body.Account)
+		if err == nil {
+			api.RespondWithJSON(w, "Unfollowed", http.StatusOK)
+			return
+		}
+	}
+
+	switch {
+	case errors.Is(err, activitypub.ErrBadAccount):
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, activitypub.ErrNotFederated):
+		api.RespondWithError(w, "Set a handle before following remote accounts", http.StatusConflict)
+	default:
+		api.RespondWithError(w, "Couldn't resolve remote account: "+err.Error(), http.StatusBadGateway)
+	}
+}
+
+func (cfg *apiConfig) FollowRemote(w http.ResponseWriter, r *http.Request) {
+	cfg.remoteFollowAction(w, r, true)
+}
+
+func (cfg *apiConfig) UnfollowRemote(w http.ResponseWriter, r *http.Request) {
+	cfg.remoteFollowAction(w, r, false)
+}
+
+// Notes delivered by followed remote accounts, newest first
+func (cfg *apiConfig) GetFederatedTimeline(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := cfg.dbQueries.GetFederatedTimeline(context.Background(), database.GetFederatedTimelineParams{
+		UserID: userIDFromContext(r.Context()),
+		Before: before,
+		Lim:    limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type remoteNote struct {
+		ID        string    `json:"id"`
+		Actor     string    `json:"actor"`
+		Username  string    `json:"username"`
+		Content   string    `json:"content"`
+		Published time.Time `json:"published"`
+	}
+	notes := make([]remoteNote, len(rows))
+	for i, row := range rows {
+		notes[i] = remoteNote{
+			ID:        row.ID,
+			Actor:     row.ActorID,
+			Username:  row.PreferredUsername,
+			Content:   row.Content,
+			Published: row.Published,
+		}
+	}
+	api.RespondWithJSON(w, notes, http.StatusOK)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Runs a follow, a note and its deletion between two servers, each on its
+// own schema, so every step goes through federationStore and the stored
+// delivery queue
+func TestTwoInstancesFederate(t *testing.T) {
+	a, b := newTestServer(t), newTestServer(t)
+	alice, aliceToken := a.createUser("alice")
+	bob, bobToken := b.createUser("bob")
+	bHost := strings.TrimPrefix(b.srv.URL, "http://")
+
+	var followed struct {
+		Actor  string `json:"actor"`
+		Status string `json:"status"`
+	}
+	a.must(http.StatusAccepted, http.MethodPost, "/api/federation/follow", aliceToken,
+		map[string]string{"account": "bob@" + bHost}, &followed)
+	if followed.Actor != b.srv.URL+"/ap/users/bob" {
+		t.Fatalf("followed actor = %q", followed.Actor)
+	}
+	eventually(t, "Accept", func() bool {
+		var accepted bool
+		a.cfg.db.QueryRow(`SELECT accepted FROM ap_following WHERE user_id = $1 AND actor_id = $2`, alice, followed.Actor).Scan(&accepted)
+		return accepted
+	})
+	followers, err := b.cfg.dbQueries.GetRemoteFollowers(t.Context(), bob)
+	if err != nil || len(followers) != 1 || followers[0].ActorID != a.srv.URL+"/ap/users/alice" {
+		t.Fatalf("bob's followers = %+v, %v", followers, err)
+	}
+
+	type remoteNote struct {
+		ID      string `json:"id"`
+		Actor   string `json:"actor"`
+		Content string `json:"content"`
+	}
+	timeline := func() []remoteNote {
+		var notes []remoteNote
+		a.must(http.StatusOK, http.MethodGet, "/api/federation/timeline", aliceToken, nil, &notes)
+		return notes
+	}
+	chirp := b.chirp(bobToken, "hello <fediverse>", nil)
+	eventually(t, "Create(Note)", func() bool { return len(timeline()) == 1 })
+	note := timeline()[0]
+	if note.ID != b.srv.URL+"/ap/notes/"+chirp.ID.String() || note.Actor != followed.Actor ||
+		note.Content != "<p>hello &lt;fediverse&gt;</p>" {
+		t.Errorf("remote note = %+v", note)
+	}
+
+	b.must(http.StatusNoContent, http.MethodDelete, "/api/chirps/"+chirp.ID.String(), bobToken, nil, nil)
+	eventually(t, "Delete", func() bool { return len(timeline()) == 0 })
+
+	a.must(http.StatusOK, http.MethodPost, "/api/federation/unfollow", aliceToken,
+		map[string]string{"account": "bob@" + bHost}, nil)
+	eventually(t, "Undo(Follow)", func() bool {
+		followers, err := b.cfg.dbQueries.GetRemoteFollowers(t.Context(), bob)
+		return err == nil && len(followers) == 0
+	})
+
+	for _, ts := range []*testServer{a, b} {
+		eventually(t, "deliveries to clear", func() bool {
+			var pending int
+			ts.cfg.db.QueryRow(`SELECT count(*) FROM ap_deliveries`).Scan(&pending)
+			return pending == 0
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/Lewvy/chirpy/internal/entities"
+	"github.com/Lewvy/chirpy/internal/feeds"
+	"github.com/google/uuid"
+)
+
+const (
+	feedAtom = "atom"
+	feedRSS  = "rss"
+	feedJSON = "json"
+
+	feedSize = 50
+)
+
+var feedContentTypes = map[string]string{
+	feedAtom: "application/atom+xml; charset=utf-8",
+	feedRSS:  "application/rss+xml; charset=utf-8",
+	feedJSON: "application/feed+json; charset=utf-8",
+}
+
+func (cfg *apiConfig) UserFeed(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx := context.Background()
+		users, err := cfg.dbQueries.GetUsersByIDs(ctx, []uuid.UUID{userID})
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(users) == 0 {
+			api.RespondWithError(w, "User not found", http.StatusNotFound)
+			return
+		}
+		chirps, err := cfg.dbQueries.GetChirpsByUser(ctx, database.GetChirpsByUserParams{
+			UserID: userID,
+			Limit:  feedSize,
+		})
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		name := userID.String()
+		if users[0].Handle.Valid {
+			name = "@" + users[0].Handle.String
+		}
+		feed := feeds.Feed{
+			Title:       name + " on Chirpy",
+			Description: "Latest chirps from " + name,
+			HomeURL:     fmt.Sprintf("%s/api/users/%s", cfg.baseURL, userID),
+			FeedURL:     fmt.Sprintf("%s/users/%s/feed.%s", cfg.baseURL, userID, format),
+		}
+		cfg.serveFeed(w, r, feed, chirps, format)
+	}
+}
+
+func (cfg *apiConfig) HashtagFeed(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tag := entities.NormalizeTag(r.PathValue("tag"))
+		if tag == "" {
+			api.RespondWithError(w, "Hashtag is required", http.StatusBadRequest)
+			return
+		}
+		chirps, err := cfg.dbQueries.GetChirpsByHashtag(context.Background(), database.GetChirpsByHashtagParams{
+			Tag:    tag,
+			Before: time.Now().Add(time.Minute),
+			Lim:    feedSize,
+		})
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		feed := feeds.Feed{
+			Title:       "#" + tag + " on Chirpy",
+			Description: "Latest chirps tagged #" + tag,
+			HomeURL:     fmt.Sprintf("%s/api/hashtags/%s/chirps", cfg.baseURL, tag),
+			FeedURL:     fmt.Sprintf("%s/hashtags/%s/feed.%s", cfg.baseURL, tag, format),
+		}
+		cfg.serveFeed(w, r, feed, chirps, format)
+	}
+}
+
+// Renders the feed and serves it with validators so readers that poll with
+// If-None-Match / If-Modified-Since get a cheap 304.
+func (cfg *apiConfig) serveFeed(w http.ResponseWriter, r *http.Request, feed feeds.Feed, chirps []database.Chirp, format string) {
+	for _, c := range chirps {
+		feed.Items = append(feed.Items, feeds.Item{
+			ID:        c.ID,
+			URL:       fmt.Sprintf("%s/api/chirps/%s", cfg.baseURL, c.ID),
+			Title:     feedTitle(c.Body),
+			Content:   c.Body,
+			Author:    c.UserID.String(),
+			Published: c.CreatedAt,
+			Updated:   c.UpdatedAt,
+		})
+	}
+
+	var body []byte
+	var err error
+	switch format {
+	case feedAtom:
+		body, err = feed.Atom()
+	case feedRSS:
+		body, err = feed.RSS()
+	default:
+		body, err = feed.JSON()
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", feedContentTypes[format])
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", feed.Updated(), bytes.NewReader(body))
+}
+
+func feedTitle(body string) string {
+	runes := []rune(body)
+	if len(runes) <= 50 {
+		return body
+	}
+	return string(runes[:49]) + "…"
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) FollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	followerID := userIDFromContext(r.Context())
+	if followeeID == followerID {
+		api.RespondWithError(w, "You cannot follow yourself", http.StatusBadRequest)
+		return
+	}
+
+	blocked, err := cfg.dbQueries.IsBlockedEitherWay(context.Background(), database.IsBlockedEitherWayParams{
+		A: followerID,
+		B: followeeID,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		api.RespondWithError(w, "You cannot follow this user", http.StatusForbidden)
+		return
+	}
+
+	n, err := cfg.dbQueries.FollowUser(context.Background(), database.FollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		api.RespondWithJSON(w, "Already following", http.StatusOK)
+		return
+	}
+	cfg.recordNotification(followeeID, followerID, notificationFollow, uuid.NullUUID{})
+	api.RespondWithJSON(w, "Followed", http.StatusCreated)
+}
+
+func (cfg *apiConfig) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	followerID := userIDFromContext(r.Context())
+	n, err := cfg.dbQueries.UnfollowUser(context.Background(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n > 0 {
+		cfg.retractNotification(followeeID, followerID, notificationFollow, uuid.NullUUID{})
+	}
+	api.RespondWithJSON(w, "Unfollowed", http.StatusOK)
+}
+
+// Chirps by the caller and the accounts they follow, interleaved with
+// rechirps by followed accounts, newest activity first. Pages are keyed on
+// activity_at rather than created_at.
+func (cfg *apiConfig) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+
+	authorIDs, err := cfg.dbQueries.GetFolloweeIDs(ctx, userID)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	authorIDs = append(authorIDs, userID)
+	cfg.respondWithTimeline(ctx, w, uuid.NullUUID{UUID: userID, Valid: true}, authorIDs, before, limit)
+}
+
+// Shared by the home and list timelines, which differ only in whose
+// chirps and rechirps they gather
+func (cfg *apiConfig) respondWithTimeline(ctx context.Context, w http.ResponseWriter, viewer uuid.NullUUID, authorIDs []uuid.UUID, before time.Time, limit int32) {
+	rows, err := cfg.dbQueries.GetTimeline(ctx, database.GetTimelineParams{
+		AuthorIds: authorIDs,
+		ViewerID:  viewer,
+		Before:    before,
+		Lim:       limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	chirps := make([]database.Chirp, len(rows))
+	for i, row := range rows {
+		chirps[i] = row.Chirp
+	}
+	resp, err := cfg.buildChirpResponses(ctx, viewer, chirps)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i, row := range rows {
+		if row.RechirpedBy.Valid {
+			resp[i].RechirpedBy = &row.RechirpedBy.UUID
+		}
+		resp[i].ActivityAt = &row.ActivityAt
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// A rechirp of an older chirp sits in the timeline by when it was
+// rechirped, so the next page must start from its activity_at
+func TestTimelinePagesAcrossRechirps(t *testing.T) {
+	ts := newTestServer(t)
+	author, authorToken := ts.createUser("author")
+	rechirper, rechirperToken := ts.createUser("rechirper")
+	_, readerToken := ts.createUser("reader")
+	ts.must(http.StatusCreated, http.MethodPost, "/api/users/"+author.String()+"/follow", readerToken, nil, nil)
+	ts.must(http.StatusCreated, http.MethodPost, "/api/users/"+rechirper.String()+"/follow", readerToken, nil, nil)
+
+	oldest := ts.chirp(authorToken, "oldest", nil)
+	ts.chirp(authorToken, "older", nil)
+	ts.must(http.StatusCreated, http.MethodPost, "/api/chirps/"+oldest.ID.String()+"/rechirp", rechirperToken, nil, nil)
+	ts.chirp(authorToken, "newest", nil)
+
+	type entry struct {
+		body      string
+		rechirped bool
+	}
+	want := []entry{{"newest", false}, {"oldest", true}, {"older", false}, {"oldest", false}}
+	var got []entry
+	path := "/api/timeline?limit=2"
+	for range 3 {
+		var page []chirpResponse
+		ts.must(http.StatusOK, http.MethodGet, path, readerToken, nil, &page)
+		if len(page) == 0 {
+			break
+		}
+		for _, c := range page {
+			got = append(got, entry{c.Body, c.RechirpedBy != nil})
+		}
+		last := page[len(page)-1]
+		if last.ActivityAt == nil {
+			t.Fatalf("timeline entry %s has no activity_at", last.ID)
+		}
+		path = "/api/timeline?limit=2&before=" + url.QueryEscape(last.ActivityAt.Format(time.RFC3339Nano))
+	}
+	if len(got) != len(want) {
+		t.Fatalf("paged through %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("paged through %+v, want %+v", got, want)
+		}
+	}
+}
@@ -3,14 +3,14 @@ module github.com/Lewvy/chirpy
 go 1.24.4
 
 require (
+	github.com/golang-jwt/jwt/v5 v5.2.2
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 	github.com/valkey-io/valkey-go v1.0.61
 	golang.org/x/crypto v0.39.0
+	golang.org/x/net v0.41.0
 )
 
-require (
-	golang.org/x/net v0.41.0 // indirect
-	golang.org/x/sys v0.33.0 // indirect
-)
+require golang.org/x/sys v0.33.0 // indirect
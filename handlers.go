@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -17,6 +19,8 @@ import (
 	"github.com/Lewvy/chirpy/api"
 	"github.com/Lewvy/chirpy/internal/auth"
 	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/Lewvy/chirpy/internal/entities"
+	"github.com/Lewvy/chirpy/internal/spam"
 	"github.com/google/uuid"
 )
 
@@ -40,58 +44,325 @@ func (cfg *apiConfig) GetChirp(w http.ResponseWriter, r *http.Request) {
 		api.RespondWithError(w, err.Error(), http.StatusNotFound)
 		return
 	}
-	api.RespondWithJSON(w, chirp, http.StatusOK)
+	viewer := cfg.viewerID(r)
+	visible, err := cfg.canViewChirp(context.Background(), viewer, chirp)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if viewer.Valid {
+		blocked, err := hasBlocked(context.Background(), cfg.dbQueries, viewer.UUID, chirp.UserID)
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if blocked {
+			api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+	}
+	resp, err := cfg.buildChirpResponse(context.Background(), viewer, chirp)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+func (cfg *apiConfig) DeleteChirp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	chirp, err := cfg.dbQueries.DeleteChirp(context.Background(), database.DeleteChirpParams{
+		ID:     id,
+		UserID: userIDFromContext(r.Context()),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Only public chirps were ever announced to webhooks and federation
+	if chirp.Visibility == visibilityPublic {
+		go cfg.emitWebhookEvent(webhookChirpDeleted, struct {
+			ID     uuid.UUID `json:"id"`
+			UserID uuid.UUID `json:"user_id"`
+		}{ID: chirp.ID, UserID: chirp.UserID})
+		go cfg.federateChirpDeletion(chirp)
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (cfg *apiConfig) GetAllChirps(w http.ResponseWriter, r *http.Request) {
-	chirps, err := cfg.dbQueries.GetAllChirps(context.Background())
+	viewer := cfg.viewerID(r)
+	chirps, err := cfg.dbQueries.GetAllChirps(context.Background(), viewer)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp, err := cfg.buildChirpResponses(context.Background(), viewer, chirps)
 	if err != nil {
 		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	api.RespondWithJSON(w, chirps, http.StatusOK)
+	api.RespondWithJSON(w, resp, http.StatusOK)
 }
 
-func (cfg *apiConfig) PostChirps(w http.ResponseWriter, r *http.Request) {
-	maxLen := 140
-	dataStr := struct {
-		Body    string    `json:"body"`
-		User_id uuid.UUID `json:"user_id"`
-	}{}
+const maxChirpLength = 140
+
+// A new chirp, whether posted directly or published from a schedule. The
+// author is never read from the body: it is the signed-in caller, or the
+// owner of the schedule.
+type newChirp struct {
+	Body      string       `json:"body"`
+	UserID    uuid.UUID    `json:"-"`
+	QuoteOfID *uuid.UUID   `json:"quote_of_id"`
+	ReplyToID *uuid.UUID   `json:"reply_to_id"`
+	MediaIDs  []uuid.UUID  `json:"media_ids"`
+	Poll      *pollRequest `json:"poll"`
+	// Defaults to public
+	Visibility string `json:"visibility"`
+}
 
-	err := json.NewDecoder(r.Body).Decode(&dataStr)
-	if err != nil {
+// Why a chirp was refused, when it is down to the chirp or its author
+// rather than the server. status is the HTTP status to answer with.
+type chirpRejection struct {
+	status int
+	msg    string
+}
+
+func (e *chirpRejection) Error() string {
+	return e.msg
+}
+
+func rejectChirp(status int, msg string) error {
+	return &chirpRejection{status: status, msg: msg}
+}
+
+type postedChirp struct {
+	resp chirpResponse
+	// Held for spam review; nobody but the author can see it yet
+	held bool
+}
+
+func (cfg *apiConfig) PostChirps(w http.ResponseWriter, r *http.Request) {
+	var in newChirp
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	chirpstr := strings.TrimSpace(string(dataStr.Body))
-	if len(chirpstr) > maxLen {
-		api.RespondWithError(w, "Chirp is too long", http.StatusBadRequest)
+	in.UserID = userIDFromContext(r.Context())
+	posted, err := cfg.postChirp(context.Background(), in, nil)
+	var rejection *chirpRejection
+	if errors.As(err, &rejection) {
+		api.RespondWithError(w, rejection.msg, rejection.status)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if posted.held {
+		api.RespondWithJSON(w, posted.resp, http.StatusAccepted)
 		return
 	}
+	api.RespondWithJSON(w, posted.resp, 200)
+}
+
+// Validates, scores and stores a chirp, then notifies and announces it.
+// inTx, when given, runs inside the chirp's transaction just before it
+// commits; an error from it abandons the chirp.
+func (cfg *apiConfig) postChirp(ctx context.Context, in newChirp, inTx func(q *database.Queries, chirp database.Chirp) error) (postedChirp, error) {
+	shadowed, err := cfg.shadowBanned(ctx, in.UserID)
+	if err != nil {
+		return postedChirp{}, err
+	}
 
-	dataStr.Body = api.CleanseChirp(chirpstr)
+	chirpstr := strings.TrimSpace(string(in.Body))
+	if len(chirpstr) > maxChirpLength {
+		return postedChirp{}, rejectChirp(http.StatusBadRequest, "Chirp is too long")
+	}
+	if len(in.MediaIDs) > maxChirpMedia {
+		return postedChirp{}, rejectChirp(http.StatusBadRequest, fmt.Sprintf("A chirp can have at most %d attachments", maxChirpMedia))
+	}
+	if in.Poll != nil {
+		if err := in.Poll.validate(time.Now()); err != nil {
+			return postedChirp{}, rejectChirp(http.StatusBadRequest, err.Error())
+		}
+	}
+	if in.Visibility == "" {
+		in.Visibility = visibilityPublic
+	}
+	if !validVisibility(in.Visibility) {
+		return postedChirp{}, rejectChirp(http.StatusBadRequest, "Unknown visibility: "+in.Visibility)
+	}
+
+	author := uuid.NullUUID{UUID: in.UserID, Valid: true}
+	var quoteOf uuid.NullUUID
+	if in.QuoteOfID != nil {
+		quoted, err := cfg.dbQueries.GetChirpByID(ctx, *in.QuoteOfID)
+		if err != nil {
+			return postedChirp{}, rejectChirp(http.StatusBadRequest, "Quoted chirp not found")
+		}
+		if visible, err := cfg.canViewChirp(ctx, author, quoted); err != nil {
+			return postedChirp{}, err
+		} else if !visible {
+			return postedChirp{}, rejectChirp(http.StatusBadRequest, "Quoted chirp not found")
+		}
+		blocked, err := hasBlocked(ctx, cfg.dbQueries, quoted.UserID, in.UserID)
+		if err != nil {
+			return postedChirp{}, err
+		}
+		if blocked {
+			return postedChirp{}, rejectChirp(http.StatusForbidden, "You cannot quote this chirp")
+		}
+		quoteOf = uuid.NullUUID{UUID: *in.QuoteOfID, Valid: true}
+	}
+	var replyTo uuid.NullUUID
+	var parent database.Chirp
+	if in.ReplyToID != nil {
+		parent, err = cfg.dbQueries.GetChirpByID(ctx, *in.ReplyToID)
+		if err != nil {
+			return postedChirp{}, rejectChirp(http.StatusBadRequest, "Chirp being replied to not found")
+		}
+		if visible, err := cfg.canViewChirp(ctx, author, parent); err != nil {
+			return postedChirp{}, err
+		} else if !visible {
+			return postedChirp{}, rejectChirp(http.StatusBadRequest, "Chirp being replied to not found")
+		}
+		blocked, err := hasBlocked(ctx, cfg.dbQueries, parent.UserID, in.UserID)
+		if err != nil {
+			return postedChirp{}, err
+		}
+		if blocked {
+			return postedChirp{}, rejectChirp(http.StatusForbidden, "You cannot reply to this chirp")
+		}
+		replyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+
+	in.Body = api.CleanseChirp(chirpstr)
+	var verdict spam.Verdict
+	if !shadowed {
+		verdict, err = cfg.scoreChirp(ctx, in.UserID, in.Body)
+		if err != nil {
+			return postedChirp{}, err
+		}
+	}
 	chirp := database.CreateChirpParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Body:      dataStr.Body,
-		UserID:    dataStr.User_id,
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		Body:       in.Body,
+		UserID:     in.UserID,
+		QuoteOfID:  quoteOf,
+		ReplyToID:  replyTo,
+		Visibility: in.Visibility,
+	}
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return postedChirp{}, err
 	}
-	chirpResp, err := cfg.dbQueries.CreateChirp(context.Background(), chirp)
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	chirpResp, err := qtx.CreateChirp(ctx, chirp)
 	if err != nil {
-		api.RespondWithError(w, "Unexpected error occured: "+err.Error(), http.StatusInternalServerError)
-		return
+		return postedChirp{}, fmt.Errorf("Unexpected error occured: %w", err)
+	}
+	if err := attachChirpMedia(ctx, qtx, chirpResp, in.MediaIDs); errors.Is(err, errUnknownMedia) {
+		return postedChirp{}, rejectChirp(http.StatusBadRequest, err.Error())
+	} else if err != nil {
+		return postedChirp{}, err
+	}
+	if in.Poll != nil {
+		if err := createPoll(ctx, qtx, chirpResp.ID, *in.Poll); err != nil {
+			return postedChirp{}, fmt.Errorf("Error saving poll: %w", err)
+		}
+	}
+	tags, err := saveChirpHashtags(ctx, qtx, chirpResp)
+	if err != nil {
+		return postedChirp{}, fmt.Errorf("Error saving hashtags: %w", err)
+	}
+	mentioned, err := saveChirpMentions(ctx, qtx, chirpResp)
+	if err != nil {
+		return postedChirp{}, fmt.Errorf("Error saving mentions: %w", err)
+	}
+	// Held chirps stay hidden, and nobody is notified, until a moderator
+	// approves them
+	if verdict.Held {
+		chirpResp, err = holdChirp(ctx, qtx, chirpResp, verdict)
+		if err != nil {
+			return postedChirp{}, err
+		}
+		if inTx != nil {
+			if err := inTx(qtx, chirpResp); err != nil {
+				return postedChirp{}, err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return postedChirp{}, err
+		}
+		resp, err := cfg.buildChirpResponse(ctx, uuid.NullUUID{}, chirpResp)
+		if err != nil {
+			return postedChirp{}, err
+		}
+		return postedChirp{resp: resp, held: true}, nil
+	}
+	mentioned, err = notifyMentioned(ctx, qtx, chirpResp, mentioned)
+	if err != nil {
+		return postedChirp{}, err
+	}
+	var replyNotified bool
+	if replyTo.Valid {
+		replyNotified, err = notify(ctx, qtx, parent.UserID, chirpResp.UserID, notificationReply, uuid.NullUUID{UUID: chirpResp.ID, Valid: true})
+		if err != nil {
+			return postedChirp{}, err
+		}
+	}
+	if inTx != nil {
+		if err := inTx(qtx, chirpResp); err != nil {
+			return postedChirp{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return postedChirp{}, err
+	}
+	resp, err := cfg.buildChirpResponse(ctx, uuid.NullUUID{}, chirpResp)
+	if err != nil {
+		return postedChirp{}, err
+	}
+	// Nobody else may learn a shadow-banned user's chirp exists
+	if shadowed {
+		return postedChirp{resp: resp}, nil
 	}
 
-	api.RespondWithJSON(w, chirpResp, 200)
+	subject := uuid.NullUUID{UUID: chirpResp.ID, Valid: true}
+	for _, userID := range mentioned {
+		go cfg.publishNotification(userID, chirpResp.UserID, notificationMention, subject)
+	}
+	if replyNotified {
+		go cfg.publishNotification(parent.UserID, chirpResp.UserID, notificationReply, subject)
+	}
+	cfg.announceChirp(resp, tags)
+	return postedChirp{resp: resp}, nil
 }
 
+const accessTokenTTL = time.Hour
+
 type UserLogins struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Handle   string `json:"handle"`
 }
 
 func getUserCreds(r io.Reader) (*UserLogins, error) {
@@ -136,7 +407,29 @@ func (cfg *apiConfig) Login(w http.ResponseWriter, r *http.Request) {
 		api.RespondWithError(w, "Incorrect Password", http.StatusUnauthorized)
 		return
 	}
-	api.RespondWithJSON(w, "Logged in successfully", http.StatusOK)
+	if isSuspended(userDetails.SuspendedUntil) {
+		api.RespondWithError(w, suspensionMessage(userDetails.SuspendedUntil, userDetails.SuspensionReason), http.StatusForbidden)
+		return
+	}
+	token, err := auth.MakeJWT(userDetails.ID, cfg.jwtSecret, accessTokenTTL)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	loginResponse := struct {
+		Email     string    `json:"email"`
+		ID        uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		Token     string    `json:"token"`
+	}{
+		Email:     userDetails.Email,
+		ID:        userDetails.ID,
+		CreatedAt: userDetails.CreatedAt,
+		UpdatedAt: userDetails.UpdatedAt,
+		Token:     token,
+	}
+	api.RespondWithJSON(w, loginResponse, http.StatusOK)
 }
 
 func generateOTP() (string, error) {
@@ -254,6 +547,10 @@ func (cfg *apiConfig) PasswordReset(w http.ResponseWriter, r *http.Request) {
 
 func (cfg *apiConfig) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	user, err := getUserCreds(r.Body)
+	if user.Handle != "" && !entities.ValidHandle(user.Handle) {
+		api.RespondWithError(w, "Handle must be 1-30 letters, digits or underscores", http.StatusBadRequest)
+		return
+	}
 	hashed_pwd, err := auth.HashPassword(user.Password)
 	if err != nil {
 		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
@@ -266,6 +563,7 @@ func (cfg *apiConfig) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 		HashedPassword: *hashed_pwd,
+		Handle:         sql.NullString{String: user.Handle, Valid: user.Handle != ""},
 	}
 
 	usr, err := cfg.dbQueries.CreateUser(context.Background(), DBuser)
@@ -276,14 +574,21 @@ func (cfg *apiConfig) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	userResponse := struct {
 		Email     string    `json:"email"`
 		ID        uuid.UUID `json:"id"`
+		Handle    string    `json:"handle,omitempty"`
 		CreatedAt time.Time `json:"created_at"`
 		UpdatedAt time.Time `json:"updated_at"`
 	}{
 		Email:     usr.Email,
 		ID:        usr.ID,
+		Handle:    usr.Handle.String,
 		CreatedAt: usr.CreatedAt,
 		UpdatedAt: usr.UpdatedAt,
 	}
+	go cfg.emitWebhookEvent(webhookUserRegistered, struct {
+		ID        uuid.UUID `json:"id"`
+		Handle    string    `json:"handle,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+	}{ID: usr.ID, Handle: usr.Handle.String, CreatedAt: usr.CreatedAt})
 	api.RespondWithJSON(w, userResponse, http.StatusCreated)
 }
 
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/Lewvy/chirpy/internal/entities"
+	"github.com/Lewvy/chirpy/internal/trending"
+)
+
+const defaultTrendingWindow = "24h"
+
+// Stores the chirp's hashtags inside the caller's transaction and returns the
+// distinct tags so they can be fed to the trending tracker after commit.
+func saveChirpHashtags(ctx context.Context, q *database.Queries, chirp database.Chirp) ([]string, error) {
+	tags := entities.Unique(entities.Hashtags(chirp.Body))
+	for _, tag := range tags {
+		hashtagID, err := q.UpsertHashtag(ctx, tag)
+		if err != nil {
+			return nil, err
+		}
+		err = q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID:   chirp.ID,
+			HashtagID: hashtagID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+func (cfg *apiConfig) recordTrending(tags []string, at time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cfg.trending.Record(ctx, tags, at); err != nil {
+		log.Println("Error recording trending tags: ", err)
+	}
+}
+
+func (cfg *apiConfig) GetHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := entities.NormalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		api.RespondWithError(w, "Hashtag is required", http.StatusBadRequest)
+		return
+	}
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	viewer := cfg.viewerID(r)
+	chirps, err := cfg.dbQueries.GetChirpsByHashtag(context.Background(), database.GetChirpsByHashtagParams{
+		Tag:      tag,
+		Before:   before,
+		ViewerID: viewer,
+		Lim:      limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp, err := cfg.buildChirpResponses(context.Background(), viewer, chirps)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+func (cfg *apiConfig) GetTrending(w http.ResponseWriter, r *http.Request) {
+	windowName := r.URL.Query().Get("window")
+	if windowName == "" {
+		windowName = defaultTrendingWindow
+	}
+	window, ok := trending.Windows[windowName]
+	if !ok {
+		api.RespondWithError(w, "Unknown window: "+windowName, http.StatusBadRequest)
+		return
+	}
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			api.RespondWithError(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, 50)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	topics, err := cfg.trending.Top(ctx, window, limit, time.Now())
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, topics, http.StatusOK)
+}
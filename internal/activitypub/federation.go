@@ -0,0 +1,298 @@
+// Package activitypub makes Chirpy accounts followable from the fediverse.
+// It serves WebFinger and actor documents, signs and verifies requests with
+// HTTP Signatures, maps chirps to Notes and follows to Follow/Accept, and
+// delivers outgoing activities through a persistent, retrying queue.
+package activitypub
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/linkpreview"
+	"github.com/Lewvy/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+const (
+	outboxPageSize  = 20
+	maxInboxBody    = 1 << 20
+	maxFetchBody    = 1 << 20
+	fetchTimeout    = 10 * time.Second
+	actorCacheTTL   = 24 * time.Hour
+	deliveryWorkers = 4
+	deliveryBatch   = 32
+	// Long enough for a whole batch to time out before it is due again
+	deliveryLease        = 5 * time.Minute
+	deliveryPollInterval = 5 * time.Second
+
+	MaxDeliveryAttempts = 8
+)
+
+var (
+	ErrBadAccount   = errors.New("account must look like user@host")
+	ErrNoActor      = errors.New("account has no ActivityPub actor")
+	ErrNotFederated = errors.New("user has no handle and cannot federate")
+)
+
+type Federation struct {
+	store   Store
+	baseURL string
+	domain  string
+
+	// Scheme used to reach other servers for WebFinger, "https" unless the
+	// base URL is plain http
+	Scheme string
+	// Client reaches other servers. It refuses private and loopback
+	// addresses, since inboxes and key ids come from unauthenticated input.
+	Client *http.Client
+	// Backoff returns the wait before retrying a delivery's nth attempt
+	Backoff func(attempt int) time.Duration
+
+	// nudge wakes the delivery loop when something is queued or due
+	nudge chan struct{}
+	now   func() time.Time
+}
+
+func New(store Store, baseURL string) (*Federation, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	transport := linkpreview.NewTransport()
+	transport.ResponseHeaderTimeout = fetchTimeout
+	return &Federation{
+		store:   store,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		domain:  u.Host,
+		Scheme:  u.Scheme,
+		Client:  &http.Client{Transport: transport, Timeout: fetchTimeout},
+		Backoff: func(attempt int) time.Duration { return webhooks.Backoff(attempt, rand.Float64()) },
+		nudge:   make(chan struct{}, 1),
+		now:     time.Now,
+	}, nil
+}
+
+func (f *Federation) actorURL(handle string) string { return f.baseURL + "/ap/users/" + handle }
+func (f *Federation) keyID(handle string) string    { return f.actorURL(handle) + "#main-key" }
+func (f *Federation) noteURL(id uuid.UUID) string   { return f.baseURL + "/ap/notes/" + id.String() }
+func (f *Federation) sharedInbox() string           { return f.baseURL + "/ap/inbox" }
+
+func (f *Federation) followID(handle, target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return f.actorURL(handle) + "#follow-" + hex.EncodeToString(sum[:8])
+}
+
+// localHandle extracts the handle from one of our actor (or actor-derived) IRIs
+func (f *Federation) localHandle(iri string) (string, bool) {
+	rest, ok := strings.CutPrefix(iri, f.baseURL+"/ap/users/")
+	if !ok {
+		return "", false
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, _, _ = strings.Cut(rest, "/")
+	return rest, rest != ""
+}
+
+// Register mounts the federation endpoints on mux
+func (f *Federation) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /.well-known/webfinger", f.WebFinger)
+	mux.HandleFunc("GET /ap/users/{handle}", f.Actor)
+	mux.HandleFunc("GET /ap/users/{handle}/outbox", f.Outbox)
+	mux.HandleFunc("GET /ap/users/{handle}/followers", f.Followers)
+	mux.HandleFunc("POST /ap/users/{handle}/inbox", f.Inbox)
+	mux.HandleFunc("POST /ap/inbox", f.Inbox)
+	mux.HandleFunc("GET /ap/notes/{id}", f.Note)
+}
+
+func writeJSON(w http.ResponseWriter, contentType string, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println("Error encoding activity: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// localUser loads a user by handle, generating its key pair on first use
+func (f *Federation) localUser(ctx context.Context, handle string) (LocalUser, error) {
+	u, err := f.store.LocalUserByHandle(ctx, handle)
+	if err != nil {
+		return u, err
+	}
+	return f.withKeys(ctx, u)
+}
+
+func (f *Federation) withKeys(ctx context.Context, u LocalUser) (LocalUser, error) {
+	if u.PrivateKeyPEM != "" {
+		return u, nil
+	}
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		return u, err
+	}
+	u.PublicKeyPEM, u.PrivateKeyPEM, err = f.store.SaveKeys(ctx, u.ID, pub, priv)
+	return u, err
+}
+
+func (f *Federation) WebFinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	var handle string
+	if acct, ok := strings.CutPrefix(resource, "acct:"); ok {
+		user, host, ok := strings.Cut(acct, "@")
+		if !ok || !strings.EqualFold(host, f.domain) {
+			http.Error(w, "Unknown resource", http.StatusNotFound)
+			return
+		}
+		handle = user
+	} else if h, ok := f.localHandle(resource); ok {
+		handle = h
+	} else {
+		http.Error(w, "Unsupported resource", http.StatusBadRequest)
+		return
+	}
+
+	u, err := f.store.LocalUserByHandle(r.Context(), handle)
+	if err != nil {
+		http.Error(w, "Unknown resource", http.StatusNotFound)
+		return
+	}
+	actor := f.actorURL(u.Handle)
+	writeJSON(w, jrdType, http.StatusOK, jrd{
+		Subject: "acct:" + u.Handle + "@" + f.domain,
+		Aliases: []string{actor},
+		Links:   []jrdLink{{Rel: "self", Type: ContentType, Href: actor}},
+	})
+}
+
+func (f *Federation) Actor(w http.ResponseWriter, r *http.Request) {
+	u, err := f.localUser(r.Context(), r.PathValue("handle"))
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Actor not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println("Error loading actor: ", err)
+		http.Error(w, "Error loading actor", http.StatusInternalServerError)
+		return
+	}
+	id := f.actorURL(u.Handle)
+	writeJSON(w, ContentType, http.StatusOK, Actor{
+		Context:           defaultContext,
+		ID:                id,
+		Type:              "Person",
+		PreferredUsername: u.Handle,
+		Inbox:             id + "/inbox",
+		Outbox:            id + "/outbox",
+		Followers:         id + "/followers",
+		PublicKey:         PublicKey{ID: f.keyID(u.Handle), Owner: id, PublicKeyPem: u.PublicKeyPEM},
+		Endpoints:         &Endpoints{SharedInbox: f.sharedInbox()},
+	})
+}
+
+func (f *Federation) note(handle string, n LocalNote) Note {
+	actor := f.actorURL(handle)
+	return Note{
+		ID:           f.noteURL(n.ID),
+		Type:         "Note",
+		AttributedTo: actor,
+		Content:      "<p>" + html.EscapeString(n.Content) + "</p>",
+		Published:    n.Published.UTC(),
+		To:           []string{PublicCollection},
+		Cc:           []string{actor + "/followers"},
+	}
+}
+
+func (f *Federation) create(handle string, n LocalNote) Activity {
+	note := f.note(handle, n)
+	published := note.Published
+	return Activity{
+		ID:        note.ID + "/activity",
+		Type:      "Create",
+		Actor:     note.AttributedTo,
+		Object:    mustRaw(note),
+		To:        note.To,
+		Cc:        note.Cc,
+		Published: &published,
+	}
+}
+
+func (f *Federation) Outbox(w http.ResponseWriter, r *http.Request) {
+	u, err := f.store.LocalUserByHandle(r.Context(), r.PathValue("handle"))
+	if err != nil {
+		http.Error(w, "Actor not found", http.StatusNotFound)
+		return
+	}
+	notes, err := f.store.LocalNotes(r.Context(), u.ID, outboxPageSize)
+	if err != nil {
+		log.Println("Error loading outbox: ", err)
+		http.Error(w, "Error loading outbox", http.StatusInternalServerError)
+		return
+	}
+	items := make([]any, len(notes))
+	for i, n := range notes {
+		items[i] = f.create(u.Handle, n)
+	}
+	writeJSON(w, ContentType, http.StatusOK, OrderedCollection{
+		Context:      defaultContext,
+		ID:           f.actorURL(u.Handle) + "/outbox",
+		Type:         "OrderedCollection",
+		TotalItems:   len(items),
+		OrderedItems: items,
+	})
+}
+
+// Followers only exposes the count; the members are not published
+func (f *Federation) Followers(w http.ResponseWriter, r *http.Request) {
+	u, err := f.store.LocalUserByHandle(r.Context(), r.PathValue("handle"))
+	if err != nil {
+		http.Error(w, "Actor not found", http.StatusNotFound)
+		return
+	}
+	followers, err := f.store.Followers(r.Context(), u.ID)
+	if err != nil {
+		log.Println("Error loading followers: ", err)
+		http.Error(w, "Error loading followers", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, ContentType, http.StatusOK, OrderedCollection{
+		Context:      defaultContext,
+		ID:           f.actorURL(u.Handle) + "/followers",
+		Type:         "OrderedCollection",
+		TotalItems:   len(followers),
+		OrderedItems: []any{},
+	})
+}
+
+func (f *Federation) Note(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	n, err := f.store.LocalNote(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	u, err := f.store.LocalUserByID(r.Context(), n.UserID)
+	if err != nil || u.Handle == "" {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	note := f.note(u.Handle, n)
+	note.Context = defaultContext
+	writeJSON(w, ContentType, http.StatusOK, note)
+}
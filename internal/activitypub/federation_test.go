@@ -0,0 +1,288 @@
+package activitypub_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/activitypub"
+	"github.com/Lewvy/chirpy/internal/linkpreview"
+	"github.com/google/uuid"
+)
+
+type instance struct {
+	srv   *httptest.Server
+	store *activitypub.MemoryStore
+	fed   *activitypub.Federation
+	host  string
+}
+
+func newInstance(t *testing.T, ctx context.Context) *instance {
+	t.Helper()
+	inst := &instance{store: activitypub.NewMemoryStore()}
+	mux := http.NewServeMux()
+	inst.srv = httptest.NewServer(mux)
+	t.Cleanup(inst.srv.Close)
+
+	fed, err := activitypub.New(inst.store, inst.srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fed.Backoff = func(int) time.Duration { return 10 * time.Millisecond }
+	// The instances listen on loopback, which the default client refuses
+	fed.Client = &http.Client{Timeout: 5 * time.Second}
+	fed.Register(mux)
+	go fed.Run(ctx)
+	inst.fed = fed
+	inst.host = strings.TrimPrefix(inst.srv.URL, "http://")
+	return inst
+}
+
+func (i *instance) addUser(handle string) uuid.UUID {
+	id := uuid.New()
+	i.store.AddUser(activitypub.LocalUser{ID: id, Handle: handle})
+	return id
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebFingerAndActor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a := newInstance(t, ctx)
+	a.addUser("alice")
+
+	resp, err := http.Get(a.srv.URL + "/.well-known/webfinger?resource=acct:alice@" + a.host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("webfinger status = %d", resp.StatusCode)
+	}
+	var doc struct {
+		Subject string
+		Links   []struct{ Rel, Type, Href string }
+	}
+	json.NewDecoder(resp.Body).Decode(&doc)
+	if len(doc.Links) != 1 || doc.Links[0].Href != a.srv.URL+"/ap/users/alice" {
+		t.Fatalf("links = %+v", doc.Links)
+	}
+
+	actorResp, err := http.Get(doc.Links[0].Href)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer actorResp.Body.Close()
+	var actor activitypub.Actor
+	json.NewDecoder(actorResp.Body).Decode(&actor)
+	if actor.Type != "Person" || actor.Inbox != actor.ID+"/inbox" || !strings.Contains(actor.PublicKey.PublicKeyPem, "PUBLIC KEY") {
+		t.Errorf("actor = %+v", actor)
+	}
+
+	missing, _ := http.Get(a.srv.URL + "/.well-known/webfinger?resource=acct:nobody@" + a.host)
+	if missing.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown account status = %d", missing.StatusCode)
+	}
+}
+
+func TestTwoInstancesFederate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, b := newInstance(t, ctx), newInstance(t, ctx)
+	alice := a.addUser("alice")
+	bob := b.addUser("bob")
+
+	remote, err := a.fed.Follow(ctx, alice, "bob@"+b.host)
+	if err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+	eventually(t, "Accept", func() bool { return a.store.Accepted(alice, remote.ID) })
+
+	followers, _ := b.store.Followers(ctx, bob)
+	if len(followers) != 1 || followers[0].ActorID != a.srv.URL+"/ap/users/alice" {
+		t.Fatalf("bob's followers = %+v", followers)
+	}
+
+	note := activitypub.LocalNote{ID: uuid.New(), UserID: bob, Content: "hello <fediverse>", Published: time.Now()}
+	b.store.AddNote(note)
+	if err := b.fed.PublishNote(ctx, note); err != nil {
+		t.Fatalf("PublishNote: %v", err)
+	}
+	eventually(t, "Create(Note)", func() bool { return len(a.store.RemoteNotes()) == 1 })
+	got := a.store.RemoteNotes()[0]
+	if got.ActorID != remote.ID || got.Content != "<p>hello &lt;fediverse&gt;</p>" {
+		t.Errorf("remote note = %+v", got)
+	}
+
+	if err := b.fed.DeleteNote(ctx, bob, note.ID); err != nil {
+		t.Fatalf("DeleteNote: %v", err)
+	}
+	eventually(t, "Delete", func() bool { return len(a.store.RemoteNotes()) == 0 })
+
+	if err := a.fed.Unfollow(ctx, alice, "bob@"+b.host); err != nil {
+		t.Fatalf("Unfollow: %v", err)
+	}
+	eventually(t, "Undo(Follow)", func() bool {
+		followers, _ := b.store.Followers(ctx, bob)
+		return len(followers) == 0
+	})
+}
+
+func TestInboxRejectsUnsignedAndForged(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, b := newInstance(t, ctx), newInstance(t, ctx)
+	a.addUser("alice")
+	b.addUser("bob")
+
+	follow := []byte(`{"id":"x","type":"Follow","actor":"` + a.srv.URL + `/ap/users/alice","object":"` + b.srv.URL + `/ap/users/bob"}`)
+	resp, err := http.Post(b.srv.URL+"/ap/users/bob/inbox", activitypub.ContentType, bytes.NewReader(follow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unsigned status = %d, want 401", resp.StatusCode)
+	}
+
+	// Signed by a key that isn't alice's published one
+	_, priv, _ := activitypub.GenerateKey()
+	req, _ := http.NewRequest(http.MethodPost, b.srv.URL+"/ap/users/bob/inbox", bytes.NewReader(follow))
+	activitypub.SignRequest(req, follow, a.srv.URL+"/ap/users/alice#main-key", priv)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("forged status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestInboxOnlyFetchesKeysFromActorHost(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, b := newInstance(t, ctx), newInstance(t, ctx)
+	a.addUser("alice")
+	b.addUser("bob")
+
+	var hits atomic.Int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer other.Close()
+
+	follow := []byte(`{"id":"x","type":"Follow","actor":"` + a.srv.URL + `/ap/users/alice","object":"` + b.srv.URL + `/ap/users/bob"}`)
+	_, priv, _ := activitypub.GenerateKey()
+	req, _ := http.NewRequest(http.MethodPost, b.srv.URL+"/ap/users/bob/inbox", bytes.NewReader(follow))
+	activitypub.SignRequest(req, follow, other.URL+"/keys/alice#main-key", priv)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("key id on another host was fetched %d times", n)
+	}
+}
+
+func TestDefaultClientRefusesLoopback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newInstance(t, ctx)
+	b.addUser("bob")
+
+	fed, err := activitypub.New(activitypub.NewMemoryStore(), "http://chirpy.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fed.Resolve(ctx, "bob@"+b.host); !errors.Is(err, linkpreview.ErrBlockedAddress) {
+		t.Errorf("Resolve on loopback: err = %v, want ErrBlockedAddress", err)
+	}
+}
+
+func TestInboxRejectsNotesFromOtherOrigins(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, b := newInstance(t, ctx), newInstance(t, ctx)
+	alice := a.addUser("alice")
+	b.addUser("bob")
+
+	remote, err := a.fed.Follow(ctx, alice, "bob@"+b.host)
+	if err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+	eventually(t, "Accept", func() bool { return a.store.Accepted(alice, remote.ID) })
+	bob, _ := b.store.LocalUserByHandle(ctx, "bob")
+
+	create := []byte(`{"id":"` + remote.ID + `#create","type":"Create","actor":"` + remote.ID + `",` +
+		`"object":{"id":"https://elsewhere.example/notes/1","type":"Note","attributedTo":"` + remote.ID + `","content":"x"}}`)
+	req, _ := http.NewRequest(http.MethodPost, a.srv.URL+"/ap/inbox", bytes.NewReader(create))
+	activitypub.SignRequest(req, create, remote.KeyID, bob.PrivateKeyPEM)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", resp.StatusCode)
+	}
+	if n := len(a.store.RemoteNotes()); n != 0 {
+		t.Errorf("stored %d notes, want 0", n)
+	}
+}
+
+func TestDeliveriesSurviveRestart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newInstance(t, ctx)
+	b.addUser("bob")
+
+	store := activitypub.NewMemoryStore()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	before, err := activitypub.New(store, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before.Client = &http.Client{Timeout: 5 * time.Second}
+	before.Register(mux)
+	alice := uuid.New()
+	store.AddUser(activitypub.LocalUser{ID: alice, Handle: "alice"})
+
+	// Queued while no worker runs, as if the process stopped right after
+	remote, err := before.Follow(ctx, alice, "bob@"+b.host)
+	if err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+	if n := store.PendingDeliveries(); n != 1 {
+		t.Fatalf("pending deliveries = %d, want 1", n)
+	}
+
+	after, err := activitypub.New(store, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after.Client = &http.Client{Timeout: 5 * time.Second}
+	go after.Run(ctx)
+	eventually(t, "Accept", func() bool { return store.Accepted(alice, remote.ID) })
+	eventually(t, "delivery to be cleared", func() bool { return store.PendingDeliveries() == 0 })
+}
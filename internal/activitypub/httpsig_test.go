@@ -0,0 +1,155 @@
+package activitypub_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/activitypub"
+)
+
+const testKeyID = "https://a.example/ap/users/alice#main-key"
+
+func signedRequest(t *testing.T, body []byte, priv string) *http.Request {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, "https://b.example/ap/inbox", bytes.NewReader(body))
+	if err := activitypub.SignRequest(req, body, testKeyID, priv); err != nil {
+		t.Fatalf("SignRequest: %v", err)
+	}
+	return req
+}
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	pub, priv, err := activitypub.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"type":"Follow"}`)
+	lookup := func(keyID string) (string, string, error) {
+		if keyID != testKeyID {
+			return "", "", errors.New("unknown key")
+		}
+		return pub, "https://a.example/ap/users/alice", nil
+	}
+
+	req := signedRequest(t, body, priv)
+	owner, err := activitypub.VerifyRequest(req, body, lookup, time.Now())
+	if err != nil {
+		t.Fatalf("VerifyRequest: %v", err)
+	}
+	if owner != "https://a.example/ap/users/alice" {
+		t.Errorf("owner = %q", owner)
+	}
+
+	if _, err := activitypub.VerifyRequest(req, []byte(`{"type":"Undo"}`), lookup, time.Now()); !errors.Is(err, activitypub.ErrBadSignature) {
+		t.Errorf("tampered body: err = %v, want ErrBadSignature", err)
+	}
+	if _, err := activitypub.VerifyRequest(req, body, lookup, time.Now().Add(time.Hour)); !errors.Is(err, activitypub.ErrBadSignature) {
+		t.Errorf("stale date: err = %v, want ErrBadSignature", err)
+	}
+
+	req.URL.Path = "/ap/users/bob/inbox"
+	if _, err := activitypub.VerifyRequest(req, body, lookup, time.Now()); !errors.Is(err, activitypub.ErrBadSignature) {
+		t.Errorf("changed target: err = %v, want ErrBadSignature", err)
+	}
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	_, priv, _ := activitypub.GenerateKey()
+	other, _, _ := activitypub.GenerateKey()
+	body := []byte(`{}`)
+	lookup := func(string) (string, string, error) { return other, "x", nil }
+	if _, err := activitypub.VerifyRequest(signedRequest(t, body, priv), body, lookup, time.Now()); !errors.Is(err, activitypub.ErrBadSignature) {
+		t.Errorf("err = %v, want ErrBadSignature", err)
+	}
+}
+
+func TestVerifyUnsigned(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://b.example/ap/inbox", nil)
+	_, err := activitypub.VerifyRequest(req, nil, nil, time.Now())
+	if !errors.Is(err, activitypub.ErrMissingSignature) {
+		t.Errorf("err = %v, want ErrMissingSignature", err)
+	}
+}
+
+// signCovering signs req over exactly the given headers, for checking which
+// header lists VerifyRequest accepts
+func signCovering(t *testing.T, req *http.Request, priv string, headers []string) {
+	t.Helper()
+	block, _ := pem.Decode([]byte(priv))
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		switch h {
+		case "(request-target)":
+			lines[i] = "(request-target): " + strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			lines[i] = "host: " + req.Host
+		default:
+			lines[i] = h + ": " + req.Header.Get(h)
+		}
+	}
+	hashed := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		testKeyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(sig)))
+}
+
+func TestVerifyRequiresCoveredHeaders(t *testing.T) {
+	pub, priv, err := activitypub.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookup := func(string) (string, string, error) { return pub, "https://a.example/ap/users/alice", nil }
+	body := []byte(`{"type":"Follow"}`)
+	sum := sha256.Sum256(body)
+	digest := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "https://b.example/ap/inbox", bytes.NewReader(body))
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+		req.Header.Set("Digest", digest)
+		req.Header.Set("X-Date", "whatever")
+		req.Header.Set("X-Digest", "whatever")
+		return req
+	}
+
+	req := newRequest()
+	signCovering(t, req, priv, []string{"(request-target)", "host", "date", "digest"})
+	if _, err := activitypub.VerifyRequest(req, body, lookup, time.Now()); err != nil {
+		t.Fatalf("fully covered request: %v", err)
+	}
+	for _, tc := range []struct {
+		name    string
+		headers []string
+	}{
+		{"no request target", []string{"host", "date", "digest"}},
+		{"no host", []string{"(request-target)", "date", "digest"}},
+		{"no digest", []string{"(request-target)", "host", "date"}},
+		// Names that merely contain "date" and "digest"
+		{"look-alike headers", []string{"(request-target)", "host", "x-date", "x-digest"}},
+	} {
+		req := newRequest()
+		signCovering(t, req, priv, tc.headers)
+		if _, err := activitypub.VerifyRequest(req, body, lookup, time.Now()); !errors.Is(err, activitypub.ErrBadSignature) {
+			t.Errorf("%s: err = %v, want ErrBadSignature", tc.name, err)
+		}
+	}
+}
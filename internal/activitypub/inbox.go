@@ -0,0 +1,252 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func (f *Federation) Inbox(w http.ResponseWriter, r *http.Request) {
+	if handle := r.PathValue("handle"); handle != "" {
+		if _, err := f.store.LocalUserByHandle(r.Context(), handle); err != nil {
+			http.Error(w, "Actor not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxInboxBody+1))
+	if err != nil || len(body) > maxInboxBody {
+		http.Error(w, "Couldn't read activity", http.StatusBadRequest)
+		return
+	}
+	var act Activity
+	if err := json.Unmarshal(body, &act); err != nil || act.Type == "" {
+		http.Error(w, "Malformed activity", http.StatusBadRequest)
+		return
+	}
+	signer, err := VerifyRequest(r, body, f.lookupKey(r.Context(), act.Actor), f.now())
+	if err != nil {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if act.Actor != signer {
+		http.Error(w, "Activity actor does not match signer", http.StatusForbidden)
+		return
+	}
+
+	if err := f.handleActivity(r.Context(), act); err != nil {
+		var status statusError
+		if errors.As(err, &status) {
+			http.Error(w, status.msg, status.code)
+			return
+		}
+		log.Println("Error handling activity: ", err)
+		http.Error(w, "Error handling activity", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e statusError) Error() string { return e.msg }
+
+func (f *Federation) handleActivity(ctx context.Context, act Activity) error {
+	switch act.Type {
+	case "Follow":
+		return f.onFollow(ctx, act)
+	case "Undo":
+		return f.onUndo(ctx, act)
+	case "Accept":
+		return f.onAccept(ctx, act)
+	case "Create":
+		return f.onCreate(ctx, act)
+	case "Delete":
+		return f.store.DeleteRemoteNote(ctx, objectID(act.Object), act.Actor)
+	}
+	// Unsupported activities are acknowledged and dropped
+	return nil
+}
+
+func (f *Federation) onFollow(ctx context.Context, act Activity) error {
+	handle, ok := f.localHandle(objectID(act.Object))
+	if !ok {
+		return statusError{http.StatusBadRequest, "Follow target is not a local actor"}
+	}
+	u, err := f.store.LocalUserByHandle(ctx, handle)
+	if err != nil {
+		return statusError{http.StatusNotFound, "Actor not found"}
+	}
+	remote, err := f.RemoteActor(ctx, act.Actor)
+	if err != nil {
+		return err
+	}
+	inbox := remote.Inbox
+	if remote.SharedInbox != "" {
+		inbox = remote.SharedInbox
+	}
+	if err := f.store.AddFollower(ctx, u.ID, Follower{ActorID: remote.ID, Inbox: inbox}); err != nil {
+		return err
+	}
+
+	accept := Activity{
+		Context: defaultContext,
+		ID:      act.ID + "#accept",
+		Type:    "Accept",
+		Actor:   f.actorURL(u.Handle),
+		Object:  mustRaw(Activity{ID: act.ID, Type: act.Type, Actor: act.Actor, Object: act.Object}),
+	}
+	return f.enqueue(ctx, u.ID, remote.Inbox, accept)
+}
+
+func (f *Federation) onUndo(ctx context.Context, act Activity) error {
+	var inner Activity
+	if err := json.Unmarshal(act.Object, &inner); err != nil || inner.Type != "Follow" {
+		return nil
+	}
+	if inner.Actor != act.Actor {
+		return statusError{http.StatusForbidden, "Cannot undo another actor's follow"}
+	}
+	handle, ok := f.localHandle(objectID(inner.Object))
+	if !ok {
+		return nil
+	}
+	u, err := f.store.LocalUserByHandle(ctx, handle)
+	if err != nil {
+		return nil
+	}
+	return f.store.RemoveFollower(ctx, u.ID, act.Actor)
+}
+
+// onAccept marks our follow as accepted. The accepted Follow may be embedded
+// or just its id; either way it names the local follower.
+func (f *Federation) onAccept(ctx context.Context, act Activity) error {
+	var inner Activity
+	follower := objectID(act.Object)
+	if json.Unmarshal(act.Object, &inner) == nil && inner.Actor != "" {
+		if inner.Type != "Follow" {
+			return nil
+		}
+		follower = inner.Actor
+	}
+	handle, ok := f.localHandle(follower)
+	if !ok {
+		return nil
+	}
+	u, err := f.store.LocalUserByHandle(ctx, handle)
+	if err != nil {
+		return nil
+	}
+	err = f.store.AcceptFollowing(ctx, u.ID, act.Actor)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (f *Federation) onCreate(ctx context.Context, act Activity) error {
+	var note Note
+	if err := json.Unmarshal(act.Object, &note); err != nil || note.Type != "Note" {
+		return nil
+	}
+	if note.AttributedTo != act.Actor {
+		return statusError{http.StatusForbidden, "Note is not attributed to the sender"}
+	}
+	if !sameOrigin(note.ID, act.Actor) {
+		return statusError{http.StatusForbidden, "Note is not hosted by the sender's server"}
+	}
+	followed, err := f.store.IsFollowedLocally(ctx, act.Actor)
+	if err != nil || !followed {
+		return err
+	}
+	published := note.Published
+	if published.IsZero() {
+		published = f.now()
+	}
+	return f.store.SaveRemoteNote(ctx, RemoteNote{
+		ID:        note.ID,
+		ActorID:   act.Actor,
+		Content:   SanitizeHTML(note.Content),
+		Published: published,
+	})
+}
+
+// lookupKey resolves signature key ids through the remote actor cache. Only
+// keys on the claimed actor's own server are fetched, so an unsigned request
+// can't point us at arbitrary URLs.
+func (f *Federation) lookupKey(ctx context.Context, actor string) KeyLookup {
+	return func(keyID string) (string, string, error) {
+		if !sameOrigin(keyID, actor) {
+			return "", "", ErrBadSignature
+		}
+		actorID, _, _ := strings.Cut(keyID, "#")
+		actor, err := f.RemoteActor(ctx, actorID)
+		if err == nil && actor.KeyID != keyID {
+			// The key may have rotated since we cached the actor
+			actor, err = f.refreshActor(ctx, actorID)
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if actor.KeyID != keyID {
+			return "", "", ErrBadSignature
+		}
+		return actor.PublicKeyPEM, actor.ID, nil
+	}
+}
+
+// RemoteActor returns the cached actor, fetching it when missing or stale
+func (f *Federation) RemoteActor(ctx context.Context, id string) (RemoteActor, error) {
+	a, err := f.store.RemoteActor(ctx, id)
+	if err == nil && f.now().Sub(a.FetchedAt) < actorCacheTTL {
+		return a, nil
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return a, err
+	}
+	return f.refreshActor(ctx, id)
+}
+
+func (f *Federation) refreshActor(ctx context.Context, id string) (RemoteActor, error) {
+	var doc Actor
+	if err := f.fetchJSON(ctx, id, ContentType, &doc); err != nil {
+		return RemoteActor{}, err
+	}
+	if doc.ID != id || doc.Inbox == "" || doc.PublicKey.Owner != doc.ID {
+		return RemoteActor{}, ErrNoActor
+	}
+	a := RemoteActor{
+		ID:                doc.ID,
+		PreferredUsername: doc.PreferredUsername,
+		Inbox:             doc.Inbox,
+		KeyID:             doc.PublicKey.ID,
+		PublicKeyPEM:      doc.PublicKey.PublicKeyPem,
+		FetchedAt:         f.now().UTC().Truncate(time.Second),
+	}
+	if doc.Endpoints != nil {
+		a.SharedInbox = doc.Endpoints.SharedInbox
+	}
+	return a, f.store.SaveRemoteActor(ctx, a)
+}
+
+// sameOrigin reports whether two IRIs share a scheme and host
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil || ua.Host == "" {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host)
+}
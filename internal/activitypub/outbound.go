@@ -0,0 +1,269 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (f *Federation) fetchJSON(ctx context.Context, target, accept string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", accept)
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: status %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxFetchBody)).Decode(v)
+}
+
+// Resolve finds the actor behind a "user@host" account via WebFinger
+func (f *Federation) Resolve(ctx context.Context, account string) (RemoteActor, error) {
+	account = strings.TrimPrefix(strings.TrimPrefix(account, "acct:"), "@")
+	user, host, ok := strings.Cut(account, "@")
+	if !ok || user == "" || host == "" || strings.ContainsAny(host, "/?#@") {
+		return RemoteActor{}, ErrBadAccount
+	}
+
+	q := url.Values{"resource": {"acct:" + user + "@" + host}}
+	var doc jrd
+	target := f.Scheme + "://" + host + "/.well-known/webfinger?" + q.Encode()
+	if err := f.fetchJSON(ctx, target, jrdType, &doc); err != nil {
+		return RemoteActor{}, err
+	}
+	for _, l := range doc.Links {
+		if l.Rel == "self" && (l.Type == ContentType || l.Type == ldContentType) {
+			return f.RemoteActor(ctx, l.Href)
+		}
+	}
+	return RemoteActor{}, ErrNoActor
+}
+
+func (f *Federation) federatedUser(ctx context.Context, userID uuid.UUID) (LocalUser, error) {
+	u, err := f.store.LocalUserByID(ctx, userID)
+	if err != nil {
+		return u, err
+	}
+	if u.Handle == "" {
+		return u, ErrNotFederated
+	}
+	return f.withKeys(ctx, u)
+}
+
+// Follow sends a Follow from a local user to a remote account. The follow
+// stays pending until the remote server delivers an Accept.
+func (f *Federation) Follow(ctx context.Context, userID uuid.UUID, account string) (RemoteActor, error) {
+	u, err := f.federatedUser(ctx, userID)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	remote, err := f.Resolve(ctx, account)
+	if err != nil {
+		return remote, err
+	}
+	if err := f.store.AddFollowing(ctx, u.ID, remote.ID); err != nil {
+		return remote, err
+	}
+	return remote, f.enqueue(ctx, u.ID, remote.Inbox, f.follow(u.Handle, remote.ID))
+}
+
+func (f *Federation) Unfollow(ctx context.Context, userID uuid.UUID, account string) error {
+	u, err := f.federatedUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	remote, err := f.Resolve(ctx, account)
+	if err != nil {
+		return err
+	}
+	if err := f.store.RemoveFollowing(ctx, u.ID, remote.ID); err != nil {
+		return err
+	}
+	follow := f.follow(u.Handle, remote.ID)
+	return f.enqueue(ctx, u.ID, remote.Inbox, Activity{
+		Context: defaultContext,
+		ID:      follow.ID + "/undo",
+		Type:    "Undo",
+		Actor:   follow.Actor,
+		Object:  mustRaw(follow),
+	})
+}
+
+func (f *Federation) follow(handle, target string) Activity {
+	return Activity{
+		Context: defaultContext,
+		ID:      f.followID(handle, target),
+		Type:    "Follow",
+		Actor:   f.actorURL(handle),
+		Object:  mustRaw(target),
+	}
+}
+
+// PublishNote delivers a new chirp to the author's remote followers
+func (f *Federation) PublishNote(ctx context.Context, n LocalNote) error {
+	u, err := f.federatedUser(ctx, n.UserID)
+	if err != nil {
+		return err
+	}
+	act := f.create(u.Handle, n)
+	act.Context = defaultContext
+	return f.fanOut(ctx, u, act)
+}
+
+// DeleteNote tells the author's remote followers a chirp is gone
+func (f *Federation) DeleteNote(ctx context.Context, userID, noteID uuid.UUID) error {
+	u, err := f.federatedUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	actor := f.actorURL(u.Handle)
+	return f.fanOut(ctx, u, Activity{
+		Context: defaultContext,
+		ID:      f.noteURL(noteID) + "/delete",
+		Type:    "Delete",
+		Actor:   actor,
+		Object:  mustRaw(map[string]string{"id": f.noteURL(noteID), "type": "Tombstone"}),
+		To:      []string{PublicCollection},
+		Cc:      []string{actor + "/followers"},
+	})
+}
+
+// fanOut queues one delivery per distinct follower inbox
+func (f *Federation) fanOut(ctx context.Context, u LocalUser, act Activity) error {
+	followers, err := f.store.Followers(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, fl := range followers {
+		if !seen[fl.Inbox] {
+			seen[fl.Inbox] = true
+			if err := f.enqueue(ctx, u.ID, fl.Inbox, act); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *Federation) enqueue(ctx context.Context, userID uuid.UUID, inbox string, act Activity) error {
+	body, err := json.Marshal(act)
+	if err != nil {
+		return err
+	}
+	if err := f.store.QueueDelivery(ctx, Delivery{UserID: userID, Inbox: inbox, Body: body}); err != nil {
+		return err
+	}
+	f.wake()
+	return nil
+}
+
+func (f *Federation) wake() {
+	select {
+	case f.nudge <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends queued deliveries until ctx is cancelled. Deliveries are stored,
+// so ones still pending or being retried when the process stops are picked
+// up again on the next start. Failures are retried with backoff up to
+// MaxDeliveryAttempts.
+func (f *Federation) Run(ctx context.Context) {
+	ticker := time.NewTicker(deliveryPollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			due, err := f.store.ClaimDeliveries(ctx, deliveryBatch, deliveryLease)
+			if err != nil {
+				log.Println("Error claiming ActivityPub deliveries: ", err)
+				break
+			}
+			sem := make(chan struct{}, deliveryWorkers)
+			var wg sync.WaitGroup
+			for _, d := range due {
+				sem <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer func() { <-sem; wg.Done() }()
+					f.deliver(ctx, d)
+				}()
+			}
+			wg.Wait()
+			if len(due) < deliveryBatch {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.nudge:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *Federation) deliver(ctx context.Context, d Delivery) {
+	err := f.post(ctx, d)
+	if err == nil {
+		if err := f.store.DeliverySucceeded(ctx, d.ID); err != nil {
+			log.Println("Error recording ActivityPub delivery: ", err)
+		}
+		return
+	}
+	attempt := d.Attempts + 1
+	giveUp := attempt >= MaxDeliveryAttempts
+	if giveUp {
+		log.Println("Giving up on ActivityPub delivery to ", d.Inbox, ": ", err)
+	}
+	wait := f.Backoff(attempt)
+	if err := f.store.DeliveryFailed(ctx, d.ID, f.now().Add(wait), giveUp, err.Error()); err != nil {
+		log.Println("Error recording ActivityPub delivery failure: ", err)
+		return
+	}
+	if !giveUp {
+		// The poll would find it too; this just keeps short backoffs short
+		time.AfterFunc(wait, f.wake)
+	}
+}
+
+func (f *Federation) post(ctx context.Context, d Delivery) error {
+	u, err := f.federatedUser(ctx, d.UserID)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Inbox, bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	if err := SignRequest(req, d.Body, f.keyID(u.Handle), u.PrivateKeyPEM); err != nil {
+		return err
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("inbox responded %d", resp.StatusCode)
+	}
+	return nil
+}
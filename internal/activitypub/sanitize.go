@@ -0,0 +1,105 @@
+package activitypub
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Tags kept in remote note content. Other tags are dropped but their text is
+// kept, except for the elements below whose text isn't meant to be read.
+var (
+	allowedTags = map[string]bool{
+		"p": true, "br": true, "a": true, "span": true,
+		"em": true, "strong": true, "b": true, "i": true,
+	}
+	droppedElements = map[string]bool{
+		"script": true, "style": true, "template": true, "iframe": true,
+		"object": true, "noscript": true, "textarea": true, "title": true,
+	}
+)
+
+// SanitizeHTML reduces remote HTML to a small set of formatting tags. Links
+// keep only an http(s) href; every other attribute is removed.
+func SanitizeHTML(s string) string {
+	z := html.NewTokenizer(strings.NewReader(s))
+	var b strings.Builder
+	var open []string
+	skip := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				b.WriteString("</" + open[i] + ">")
+			}
+			return b.String()
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(string(z.Text())))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if droppedElements[tok.Data] {
+				if tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 || !allowedTags[tok.Data] {
+				continue
+			}
+			if tok.Data == "br" {
+				b.WriteString("<br>")
+				continue
+			}
+			b.WriteString(openTag(tok))
+			if tt == html.SelfClosingTagToken {
+				b.WriteString("</" + tok.Data + ">")
+				continue
+			}
+			open = append(open, tok.Data)
+		case html.EndTagToken:
+			tok := z.Token()
+			if droppedElements[tok.Data] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			// Close back to the matching tag so the output stays balanced;
+			// a stray end tag is dropped
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != tok.Data {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+}
+
+func openTag(tok html.Token) string {
+	if tok.Data != "a" {
+		return "<" + tok.Data + ">"
+	}
+	for _, attr := range tok.Attr {
+		if attr.Key != "href" || attr.Namespace != "" {
+			continue
+		}
+		u, err := url.Parse(attr.Val)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			break
+		}
+		return `<a href="` + html.EscapeString(u.String()) + `" rel="nofollow noopener noreferrer">`
+	}
+	return "<a>"
+}
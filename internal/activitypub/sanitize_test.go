@@ -0,0 +1,26 @@
+package activitypub_test
+
+import (
+	"testing"
+
+	"github.com/Lewvy/chirpy/internal/activitypub"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{`<p>hello &lt;fediverse&gt;</p>`, `<p>hello &lt;fediverse&gt;</p>`},
+		{`<p>hi<script>alert(1)</script></p>`, `<p>hi</p>`},
+		{`<p onclick="x()">a<br/>b</p>`, `<p>a<br>b</p>`},
+		{`<a href="https://x.example/@bob" class="u-url">@bob</a>`, `<a href="https://x.example/@bob" rel="nofollow noopener noreferrer">@bob</a>`},
+		{`<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{`<div><img src=x onerror=y>text</div>`, `text`},
+		{`<p><em>open`, `<p><em>open</em></p>`},
+		{`<p>a</strong>b</p>`, `<p>ab</p>`},
+		{`<style>p{}</style><iframe src="x">t</iframe>ok`, `ok`},
+	}
+	for _, c := range cases {
+		if got := activitypub.SanitizeHTML(c.in); got != c.want {
+			t.Errorf("SanitizeHTML(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
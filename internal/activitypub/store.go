@@ -0,0 +1,384 @@
+package activitypub
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// LocalUser is a Chirpy account as federation sees it. Keys are empty until
+// the account is first needed for federation.
+type LocalUser struct {
+	ID            uuid.UUID
+	Handle        string
+	PublicKeyPEM  string
+	PrivateKeyPEM string
+}
+
+type LocalNote struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Content   string
+	Published time.Time
+}
+
+type Follower struct {
+	ActorID string
+	Inbox   string
+}
+
+type RemoteActor struct {
+	ID                string
+	PreferredUsername string
+	Inbox             string
+	SharedInbox       string
+	KeyID             string
+	PublicKeyPEM      string
+	FetchedAt         time.Time
+}
+
+// Delivery is an outgoing activity queued for one inbox
+type Delivery struct {
+	ID       uuid.UUID
+	UserID   uuid.UUID
+	Inbox    string
+	Body     []byte
+	Attempts int
+}
+
+type RemoteNote struct {
+	ID        string
+	ActorID   string
+	Content   string
+	Published time.Time
+}
+
+// Store is the persistence federation needs. The app implements it over
+// Postgres; MemoryStore backs tests.
+type Store interface {
+	LocalUserByHandle(ctx context.Context, handle string) (LocalUser, error)
+	LocalUserByID(ctx context.Context, id uuid.UUID) (LocalUser, error)
+	// SaveKeys stores a key pair unless the user already has one, and
+	// returns whichever pair is stored
+	SaveKeys(ctx context.Context, userID uuid.UUID, publicPEM, privatePEM string) (string, string, error)
+	LocalNote(ctx context.Context, id uuid.UUID) (LocalNote, error)
+	LocalNotes(ctx context.Context, userID uuid.UUID, limit int) ([]LocalNote, error)
+
+	AddFollower(ctx context.Context, userID uuid.UUID, f Follower) error
+	RemoveFollower(ctx context.Context, userID uuid.UUID, actorID string) error
+	Followers(ctx context.Context, userID uuid.UUID) ([]Follower, error)
+
+	AddFollowing(ctx context.Context, userID uuid.UUID, actorID string) error
+	AcceptFollowing(ctx context.Context, userID uuid.UUID, actorID string) error
+	RemoveFollowing(ctx context.Context, userID uuid.UUID, actorID string) error
+	IsFollowedLocally(ctx context.Context, actorID string) (bool, error)
+
+	RemoteActor(ctx context.Context, id string) (RemoteActor, error)
+	SaveRemoteActor(ctx context.Context, a RemoteActor) error
+	SaveRemoteNote(ctx context.Context, n RemoteNote) error
+	DeleteRemoteNote(ctx context.Context, id, actorID string) error
+
+	QueueDelivery(ctx context.Context, d Delivery) error
+	// ClaimDeliveries leases up to limit due deliveries so no other worker
+	// sends them; a delivery whose lease runs out is due again
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error)
+	DeliverySucceeded(ctx context.Context, id uuid.UUID) error
+	// DeliveryFailed records a failed attempt and schedules the next one,
+	// or drops the delivery for good when giveUp is set
+	DeliveryFailed(ctx context.Context, id uuid.UUID, next time.Time, giveUp bool, reason string) error
+}
+
+type following struct {
+	actorID  string
+	accepted bool
+}
+
+type MemoryStore struct {
+	mu          sync.Mutex
+	users       map[uuid.UUID]LocalUser
+	notes       map[uuid.UUID]LocalNote
+	followers   map[uuid.UUID]map[string]Follower
+	following   map[uuid.UUID]map[string]*following
+	actors      map[string]RemoteActor
+	remoteNotes map[string]RemoteNote
+	deliveries  map[uuid.UUID]*queuedDelivery
+}
+
+type queuedDelivery struct {
+	Delivery
+	due    time.Time
+	failed bool
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:       map[uuid.UUID]LocalUser{},
+		notes:       map[uuid.UUID]LocalNote{},
+		followers:   map[uuid.UUID]map[string]Follower{},
+		following:   map[uuid.UUID]map[string]*following{},
+		actors:      map[string]RemoteActor{},
+		remoteNotes: map[string]RemoteNote{},
+		deliveries:  map[uuid.UUID]*queuedDelivery{},
+	}
+}
+
+func (m *MemoryStore) AddUser(u LocalUser) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[u.ID] = u
+}
+
+func (m *MemoryStore) AddNote(n LocalNote) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notes[n.ID] = n
+}
+
+func (m *MemoryStore) LocalUserByHandle(_ context.Context, handle string) (LocalUser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if strings.EqualFold(u.Handle, handle) {
+			return u, nil
+		}
+	}
+	return LocalUser{}, ErrNotFound
+}
+
+func (m *MemoryStore) LocalUserByID(_ context.Context, id uuid.UUID) (LocalUser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return LocalUser{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (m *MemoryStore) SaveKeys(_ context.Context, userID uuid.UUID, publicPEM, privatePEM string) (string, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return "", "", ErrNotFound
+	}
+	if u.PrivateKeyPEM == "" {
+		u.PublicKeyPEM, u.PrivateKeyPEM = publicPEM, privatePEM
+		m.users[userID] = u
+	}
+	return u.PublicKeyPEM, u.PrivateKeyPEM, nil
+}
+
+func (m *MemoryStore) LocalNote(_ context.Context, id uuid.UUID) (LocalNote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notes[id]
+	if !ok {
+		return LocalNote{}, ErrNotFound
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) LocalNotes(_ context.Context, userID uuid.UUID, limit int) ([]LocalNote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var notes []LocalNote
+	for _, n := range m.notes {
+		if n.UserID == userID {
+			notes = append(notes, n)
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Published.After(notes[j].Published) })
+	if len(notes) > limit {
+		notes = notes[:limit]
+	}
+	return notes, nil
+}
+
+func (m *MemoryStore) AddFollower(_ context.Context, userID uuid.UUID, f Follower) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.followers[userID] == nil {
+		m.followers[userID] = map[string]Follower{}
+	}
+	m.followers[userID][f.ActorID] = f
+	return nil
+}
+
+func (m *MemoryStore) RemoveFollower(_ context.Context, userID uuid.UUID, actorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.followers[userID], actorID)
+	return nil
+}
+
+func (m *MemoryStore) Followers(_ context.Context, userID uuid.UUID) ([]Follower, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Follower
+	for _, f := range m.followers[userID] {
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) AddFollowing(_ context.Context, userID uuid.UUID, actorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.following[userID] == nil {
+		m.following[userID] = map[string]*following{}
+	}
+	if _, ok := m.following[userID][actorID]; !ok {
+		m.following[userID][actorID] = &following{actorID: actorID}
+	}
+	return nil
+}
+
+func (m *MemoryStore) AcceptFollowing(_ context.Context, userID uuid.UUID, actorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.following[userID][actorID]
+	if !ok {
+		return ErrNotFound
+	}
+	f.accepted = true
+	return nil
+}
+
+func (m *MemoryStore) RemoveFollowing(_ context.Context, userID uuid.UUID, actorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.following[userID], actorID)
+	return nil
+}
+
+// Accepted reports whether userID's follow of actorID has been accepted
+func (m *MemoryStore) Accepted(userID uuid.UUID, actorID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.following[userID][actorID]
+	return ok && f.accepted
+}
+
+func (m *MemoryStore) IsFollowedLocally(_ context.Context, actorID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, fs := range m.following {
+		if _, ok := fs[actorID]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) RemoteActor(_ context.Context, id string) (RemoteActor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.actors[id]
+	if !ok {
+		return RemoteActor{}, ErrNotFound
+	}
+	return a, nil
+}
+
+func (m *MemoryStore) SaveRemoteActor(_ context.Context, a RemoteActor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.actors[a.ID] = a
+	return nil
+}
+
+func (m *MemoryStore) SaveRemoteNote(_ context.Context, n RemoteNote) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remoteNotes[n.ID] = n
+	return nil
+}
+
+func (m *MemoryStore) DeleteRemoteNote(_ context.Context, id, actorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n, ok := m.remoteNotes[id]; ok && n.ActorID == actorID {
+		delete(m.remoteNotes, id)
+	}
+	return nil
+}
+
+func (m *MemoryStore) QueueDelivery(_ context.Context, d Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d.ID = uuid.New()
+	m.deliveries[d.ID] = &queuedDelivery{Delivery: d, due: time.Now()}
+	return nil
+}
+
+func (m *MemoryStore) ClaimDeliveries(_ context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var due []*queuedDelivery
+	for _, d := range m.deliveries {
+		if !d.failed && !d.due.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].due.Before(due[j].due) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	out := make([]Delivery, len(due))
+	for i, d := range due {
+		d.due = now.Add(lease)
+		out[i] = d.Delivery
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) DeliverySucceeded(_ context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.deliveries, id)
+	return nil
+}
+
+func (m *MemoryStore) DeliveryFailed(_ context.Context, id uuid.UUID, next time.Time, giveUp bool, _ string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d, ok := m.deliveries[id]; ok {
+		d.Attempts++
+		d.due = next
+		d.failed = giveUp
+	}
+	return nil
+}
+
+// PendingDeliveries counts deliveries still waiting to be sent
+func (m *MemoryStore) PendingDeliveries() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, d := range m.deliveries {
+		if !d.failed {
+			n++
+		}
+	}
+	return n
+}
+
+// RemoteNotes returns the stored notes received from other servers
+func (m *MemoryStore) RemoteNotes() []RemoteNote {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]RemoteNote, 0, len(m.remoteNotes))
+	for _, n := range m.remoteNotes {
+		out = append(out, n)
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ContentType   = "application/activity+json"
+	ldContentType = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+	jrdType       = "application/jrd+json"
+
+	PublicCollection = "https://www.w3.org/ns/activitystreams#Public"
+)
+
+var defaultContext = []any{
+	"https://www.w3.org/ns/activitystreams",
+	"https://w3id.org/security/v1",
+}
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Name              string     `json:"name,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox"`
+	Followers         string     `json:"followers,omitempty"`
+	Following         string     `json:"following,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+}
+
+type Note struct {
+	Context      any       `json:"@context,omitempty"`
+	ID           string    `json:"id"`
+	Type         string    `json:"type"`
+	AttributedTo string    `json:"attributedTo"`
+	Content      string    `json:"content"`
+	Published    time.Time `json:"published"`
+	To           []string  `json:"to,omitempty"`
+	Cc           []string  `json:"cc,omitempty"`
+	URL          string    `json:"url,omitempty"`
+}
+
+// Activity is used for both directions. Object stays raw on the way in since
+// it may be a bare IRI or an embedded object.
+type Activity struct {
+	Context   any             `json:"@context,omitempty"`
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Object    json.RawMessage `json:"object"`
+	To        []string        `json:"to,omitempty"`
+	Cc        []string        `json:"cc,omitempty"`
+	Published *time.Time      `json:"published,omitempty"`
+}
+
+type OrderedCollection struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	TotalItems   int    `json:"totalItems"`
+	OrderedItems []any  `json:"orderedItems"`
+}
+
+type jrdLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href,omitempty"`
+}
+
+type jrd struct {
+	Subject string    `json:"subject"`
+	Aliases []string  `json:"aliases,omitempty"`
+	Links   []jrdLink `json:"links"`
+}
+
+// objectID returns the id of an object that may be an IRI or embedded
+func objectID(raw json.RawMessage) string {
+	var iri string
+	if json.Unmarshal(raw, &iri) == nil {
+		return iri
+	}
+	var obj struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(raw, &obj)
+	return obj.ID
+}
+
+func mustRaw(v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
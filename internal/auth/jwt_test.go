@@ -0,0 +1,58 @@
+package auth_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+func TestJWTRoundTrip(t *testing.T) {
+	userID := uuid.New()
+	token, err := auth.MakeJWT(userID, "secret", time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	got, err := auth.ValidateJWT(token, "secret")
+	if err != nil {
+		t.Fatalf("ValidateJWT failed: %v", err)
+	}
+	if got != userID {
+		t.Errorf("expected subject %s, got %s", userID, got)
+	}
+
+	if _, err := auth.ValidateJWT(token, "other-secret"); err == nil {
+		t.Error("token signed with a different secret was accepted")
+	}
+}
+
+func TestJWTExpired(t *testing.T) {
+	token, err := auth.MakeJWT(uuid.New(), "secret", -time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	if _, err := auth.ValidateJWT(token, "secret"); err == nil {
+		t.Error("expired token was accepted")
+	}
+}
+
+func TestGetBearerToken(t *testing.T) {
+	headers := http.Header{}
+	if _, err := auth.GetBearerToken(headers); err == nil {
+		t.Error("missing header was accepted")
+	}
+
+	headers.Set("Authorization", "Bearer abc.def.ghi")
+	token, err := auth.GetBearerToken(headers)
+	if err != nil || token != "abc.def.ghi" {
+		t.Errorf("expected abc.def.ghi, got %q (%v)", token, err)
+	}
+
+	headers.Set("Authorization", "Basic abc")
+	if _, err := auth.GetBearerToken(headers); err == nil {
+		t.Error("non-bearer scheme was accepted")
+	}
+}
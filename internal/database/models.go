@@ -5,23 +5,356 @@
 package database
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ApDelivery struct {
+	ID            uuid.UUID      `json:"id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UserID        uuid.UUID      `json:"user_id"`
+	Inbox         string         `json:"inbox"`
+	Body          []byte         `json:"body"`
+	Status        string         `json:"status"`
+	Attempts      int32          `json:"attempts"`
+	NextAttemptAt time.Time      `json:"next_attempt_at"`
+	LastError     sql.NullString `json:"last_error"`
+}
+
+type ApFollower struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ActorID   string    `json:"actor_id"`
+	Inbox     string    `json:"inbox"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ApFollowing struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ActorID   string    `json:"actor_id"`
+	Accepted  bool      `json:"accepted"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ApKey struct {
+	UserID        uuid.UUID `json:"user_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	PublicKeyPem  string    `json:"public_key_pem"`
+	PrivateKeyPem string    `json:"private_key_pem"`
+}
+
+type ApRemoteActor struct {
+	ID                string         `json:"id"`
+	PreferredUsername string         `json:"preferred_username"`
+	Inbox             string         `json:"inbox"`
+	SharedInbox       sql.NullString `json:"shared_inbox"`
+	KeyID             string         `json:"key_id"`
+	PublicKeyPem      string         `json:"public_key_pem"`
+	FetchedAt         time.Time      `json:"fetched_at"`
+}
+
+type ApRemoteNote struct {
+	ID         string    `json:"id"`
+	ActorID    string    `json:"actor_id"`
+	Content    string    `json:"content"`
+	Published  time.Time `json:"published"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+type Block struct {
+	BlockerID uuid.UUID `json:"blocker_id"`
+	BlockedID uuid.UUID `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type Chirp struct {
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Body         string        `json:"body"`
+	UserID       uuid.UUID     `json:"user_id"`
+	LikeCount    int32         `json:"like_count"`
+	RechirpCount int32         `json:"rechirp_count"`
+	QuoteOfID    uuid.NullUUID `json:"quote_of_id"`
+	ReplyToID    uuid.NullUUID `json:"reply_to_id"`
+	HiddenAt     sql.NullTime  `json:"hidden_at"`
+	Visibility   string        `json:"visibility"`
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	HashtagID uuid.UUID `json:"hashtag_id"`
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Collection struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
 	UserID    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
 }
 
-type User struct {
+type CollectionChirp struct {
+	CollectionID uuid.UUID `json:"collection_id"`
+	UserID       uuid.UUID `json:"user_id"`
+	ChirpID      uuid.UUID `json:"chirp_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Conversation struct {
+	ID        uuid.UUID      `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DirectKey sql.NullString `json:"direct_key"`
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID    `json:"conversation_id"`
+	UserID         uuid.UUID    `json:"user_id"`
+	JoinedAt       time.Time    `json:"joined_at"`
+	LastReadAt     sql.NullTime `json:"last_read_at"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Hashtag struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Tag       string    `json:"tag"`
+}
+
+type LinkPreview struct {
+	Url         string         `json:"url"`
+	FetchedAt   time.Time      `json:"fetched_at"`
+	Status      string         `json:"status"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	ImageUrl    string         `json:"image_url"`
+	SiteName    string         `json:"site_name"`
+	Error       sql.NullString `json:"error"`
+}
+
+type List struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	UserID      uuid.UUID `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Private     bool      `json:"private"`
+}
+
+type ListMember struct {
+	ListID    uuid.UUID `json:"list_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MediaVariant struct {
+	MediaID     uuid.UUID `json:"media_id"`
+	Variant     string    `json:"variant"`
+	StorageKey  string    `json:"storage_key"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	Width       int32     `json:"width"`
+	Height      int32     `json:"height"`
+}
+
+type Medium struct {
+	ID            uuid.UUID     `json:"id"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UserID        uuid.UUID     `json:"user_id"`
+	ChirpID       uuid.NullUUID `json:"chirp_id"`
+	Position      int32         `json:"position"`
+	StorageKey    string        `json:"storage_key"`
+	ContentType   string        `json:"content_type"`
+	SizeBytes     int64         `json:"size_bytes"`
+	Width         int32         `json:"width"`
+	Height        int32         `json:"height"`
+	Blurhash      string        `json:"blurhash"`
+	AltText       string        `json:"alt_text"`
+	Status        string        `json:"status"`
+	Attempts      int32         `json:"attempts"`
+	NextAttemptAt time.Time     `json:"next_attempt_at"`
+}
+
+type Mention struct {
+	ChirpID     uuid.UUID `json:"chirp_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	StartOffset int32     `json:"start_offset"`
+	EndOffset   int32     `json:"end_offset"`
+}
+
+type Message struct {
 	ID             uuid.UUID `json:"id"`
 	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+}
+
+type ModerationLog struct {
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	ModeratorID  uuid.UUID     `json:"moderator_id"`
+	Action       string        `json:"action"`
+	ChirpID      uuid.NullUUID `json:"chirp_id"`
+	TargetUserID uuid.NullUUID `json:"target_user_id"`
+	Reason       string        `json:"reason"`
+	ReportCount  int32         `json:"report_count"`
+}
+
+type Mute struct {
+	MuterID   uuid.UUID `json:"muter_id"`
+	MutedID   uuid.UUID `json:"muted_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Notification struct {
+	ID        uuid.UUID      `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UserID    uuid.UUID      `json:"user_id"`
+	ActorID   uuid.UUID      `json:"actor_id"`
+	Type      string         `json:"type"`
+	ChirpID   uuid.NullUUID  `json:"chirp_id"`
+	ReadAt    sql.NullTime   `json:"read_at"`
+	System    bool           `json:"system"`
+	Detail    sql.NullString `json:"detail"`
+}
+
+type PinnedChirp struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Slot      int16     `json:"slot"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Poll struct {
+	ChirpID          uuid.UUID    `json:"chirp_id"`
+	CreatedAt        time.Time    `json:"created_at"`
+	ClosesAt         time.Time    `json:"closes_at"`
+	ClosedNotifiedAt sql.NullTime `json:"closed_notified_at"`
+}
+
+type PollOption struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Position  int32     `json:"position"`
+	Text      string    `json:"text"`
+	VoteCount int32     `json:"vote_count"`
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Position  int32     `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Rechirp struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Report struct {
+	ID         uuid.UUID      `json:"id"`
+	CreatedAt  time.Time      `json:"created_at"`
+	ChirpID    uuid.UUID      `json:"chirp_id"`
+	ReporterID uuid.UUID      `json:"reporter_id"`
+	Category   string         `json:"category"`
+	Details    sql.NullString `json:"details"`
+	Status     string         `json:"status"`
+	ResolvedAt sql.NullTime   `json:"resolved_at"`
+	Resolution sql.NullString `json:"resolution"`
+}
+
+type ScheduledChirp struct {
+	ID                  uuid.UUID      `json:"id"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	UserID              uuid.UUID      `json:"user_id"`
+	Body                string         `json:"body"`
+	QuoteOfID           uuid.NullUUID  `json:"quote_of_id"`
+	ReplyToID           uuid.NullUUID  `json:"reply_to_id"`
+	MediaIds            []uuid.UUID    `json:"media_ids"`
+	PollOptions         []string       `json:"poll_options"`
+	PollDurationMinutes int32          `json:"poll_duration_minutes"`
+	PublishAt           sql.NullTime   `json:"publish_at"`
+	Status              string         `json:"status"`
+	LeaseUntil          sql.NullTime   `json:"lease_until"`
+	Attempts            int32          `json:"attempts"`
+	ChirpID             uuid.NullUUID  `json:"chirp_id"`
+	LastError           sql.NullString `json:"last_error"`
+	Visibility          string         `json:"visibility"`
+}
+
+type SpamHold struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Score     float64   `json:"score"`
+	Reasons   []string  `json:"reasons"`
+}
+
+type User struct {
+	ID               uuid.UUID      `json:"id"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	Email            string         `json:"email"`
+	HashedPassword   string         `json:"hashed_password"`
+	Handle           sql.NullString `json:"handle"`
+	Role             string         `json:"role"`
+	SuspendedUntil   sql.NullTime   `json:"suspended_until"`
+	SuspensionReason sql.NullString `json:"suspension_reason"`
+	ShadowBannedAt   sql.NullTime   `json:"shadow_banned_at"`
+	DisplayName      string         `json:"display_name"`
+	Bio              string         `json:"bio"`
+	Location         string         `json:"location"`
+	Website          string         `json:"website"`
+	AvatarMediaID    uuid.NullUUID  `json:"avatar_media_id"`
+	BannerMediaID    uuid.NullUUID  `json:"banner_media_id"`
+}
+
+type Webhook struct {
+	ID           uuid.UUID    `json:"id"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UserID       uuid.UUID    `json:"user_id"`
+	Url          string       `json:"url"`
+	Secret       string       `json:"secret"`
+	Events       []string     `json:"events"`
+	Active       bool         `json:"active"`
+	FailureCount int32        `json:"failure_count"`
+	DisabledAt   sql.NullTime `json:"disabled_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	WebhookID      uuid.UUID       `json:"webhook_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode sql.NullInt32   `json:"last_status_code"`
+	LastError      sql.NullString  `json:"last_error"`
+	DeliveredAt    sql.NullTime    `json:"delivered_at"`
 }
@@ -7,9 +7,11 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const clearTable = `-- name: ClearTable :exec
@@ -22,19 +24,22 @@ func (q *Queries) ClearTable(ctx context.Context) error {
 }
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at,body,  user_id)
+INSERT INTO chirps (id, created_at, updated_at,body,  user_id, quote_of_id, reply_to_id, visibility)
 VALUES (
-    $1, $2, $3, $4, $5
+    $1, $2, $3, $4, $5, $6, $7, $8
     )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, like_count, rechirp_count, quote_of_id, reply_to_id, hidden_at, visibility
 `
 
 type CreateChirpParams struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID         uuid.UUID     `json:"id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+	Body       string        `json:"body"`
+	UserID     uuid.UUID     `json:"user_id"`
+	QuoteOfID  uuid.NullUUID `json:"quote_of_id"`
+	ReplyToID  uuid.NullUUID `json:"reply_to_id"`
+	Visibility string        `json:"visibility"`
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
@@ -44,6 +49,9 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		arg.UpdatedAt,
 		arg.Body,
 		arg.UserID,
+		arg.QuoteOfID,
+		arg.ReplyToID,
+		arg.Visibility,
 	)
 	var i Chirp
 	err := row.Scan(
@@ -52,24 +60,31 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.QuoteOfID,
+		&i.ReplyToID,
+		&i.HiddenAt,
+		&i.Visibility,
 	)
 	return i, err
 }
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
-  $1, $2, $3, $4, $5
+  $1, $2, $3, $4, $5, $6
   )
-RETURNING id, created_at, updated_at, email, hashed_password
+RETURNING id, created_at, updated_at, email, hashed_password, handle, role, suspended_until, suspension_reason, shadow_banned_at, display_name, bio, location, website, avatar_media_id, banner_media_id
 `
 
 type CreateUserParams struct {
-	ID             uuid.UUID `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
+	ID             uuid.UUID      `json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	Email          string         `json:"email"`
+	HashedPassword string         `json:"hashed_password"`
+	Handle         sql.NullString `json:"handle"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -79,6 +94,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.UpdatedAt,
 		arg.Email,
 		arg.HashedPassword,
+		arg.Handle,
 	)
 	var i User
 	err := row.Scan(
@@ -87,16 +103,63 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarMediaID,
+		&i.BannerMediaID,
+	)
+	return i, err
+}
+
+const deleteChirp = `-- name: DeleteChirp :one
+DELETE FROM chirps WHERE id = $1 AND user_id = $2
+RETURNING id, created_at, updated_at, body, user_id, like_count, rechirp_count, quote_of_id, reply_to_id, hidden_at, visibility
+`
+
+type DeleteChirpParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, deleteChirp, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.QuoteOfID,
+		&i.ReplyToID,
+		&i.HiddenAt,
+		&i.Visibility,
 	)
 	return i, err
 }
 
 const getAllChirps = `-- name: GetAllChirps :many
-Select id, created_at, updated_at, body, user_id from chirps order by created_at
+Select id, created_at, updated_at, body, user_id, like_count, rechirp_count, quote_of_id, reply_to_id, hidden_at, visibility from chirps
+WHERE visibility <> 'unlisted' AND chirp_visible_to(chirps, $1)
+  AND NOT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = chirps.user_id)
+  AND NOT EXISTS (SELECT 1 FROM mutes WHERE muter_id = $1 AND muted_id = chirps.user_id)
+order by created_at
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+// Leaves out hidden and unlisted chirps, chirps the viewer may not see,
+// authors the viewer has blocked or muted, and shadow-banned authors other
+// than the viewer.
+func (q *Queries) GetAllChirps(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -110,6 +173,12 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.QuoteOfID,
+			&i.ReplyToID,
+			&i.HiddenAt,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -125,7 +194,7 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-Select id, created_at, updated_at, body, user_id from chirps where id = $1
+Select id, created_at, updated_at, body, user_id, like_count, rechirp_count, quote_of_id, reply_to_id, hidden_at, visibility from chirps where id = $1
 `
 
 func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -137,20 +206,113 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.QuoteOfID,
+		&i.ReplyToID,
+		&i.HiddenAt,
+		&i.Visibility,
 	)
 	return i, err
 }
 
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+Select id, created_at, updated_at, body, user_id, like_count, rechirp_count, quote_of_id, reply_to_id, hidden_at, visibility from chirps where id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.QuoteOfID,
+			&i.ReplyToID,
+			&i.HiddenAt,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByUser = `-- name: GetChirpsByUser :many
+Select id, created_at, updated_at, body, user_id, like_count, rechirp_count, quote_of_id, reply_to_id, hidden_at, visibility from chirps
+where user_id = $1 and visibility = 'public' and chirp_visible_to(chirps, NULL)
+order by created_at desc limit $2
+`
+
+type GetChirpsByUserParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Limit  int32     `json:"limit"`
+}
+
+func (q *Queries) GetChirpsByUser(ctx context.Context, arg GetChirpsByUserParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUser, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.QuoteOfID,
+			&i.ReplyToID,
+			&i.HiddenAt,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-Select id, hashed_password, email, created_at, updated_at from users where email = $1
+Select id, hashed_password, email, created_at, updated_at, suspended_until, suspension_reason from users where email = $1
 `
 
 type GetUserByEmailRow struct {
-	ID             uuid.UUID `json:"id"`
-	HashedPassword string    `json:"hashed_password"`
-	Email          string    `json:"email"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID               uuid.UUID      `json:"id"`
+	HashedPassword   string         `json:"hashed_password"`
+	Email            string         `json:"email"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	SuspendedUntil   sql.NullTime   `json:"suspended_until"`
+	SuspensionReason sql.NullString `json:"suspension_reason"`
 }
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
@@ -162,10 +324,109 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEm
 		&i.Email,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
 	)
 	return i, err
 }
 
+const getUsersByHandles = `-- name: GetUsersByHandles :many
+Select id, handle from users where lower(handle) = ANY($1::text[])
+`
+
+type GetUsersByHandlesRow struct {
+	ID     uuid.UUID      `json:"id"`
+	Handle sql.NullString `json:"handle"`
+}
+
+func (q *Queries) GetUsersByHandles(ctx context.Context, handles []string) ([]GetUsersByHandlesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByHandles, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersByHandlesRow
+	for rows.Next() {
+		var i GetUsersByHandlesRow
+		if err := rows.Scan(&i.ID, &i.Handle); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUsersByIDs = `-- name: GetUsersByIDs :many
+Select id, handle from users where id = ANY($1::uuid[])
+`
+
+type GetUsersByIDsRow struct {
+	ID     uuid.UUID      `json:"id"`
+	Handle sql.NullString `json:"handle"`
+}
+
+func (q *Queries) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]GetUsersByIDsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersByIDsRow
+	for rows.Next() {
+		var i GetUsersByIDsRow
+		if err := rows.Scan(&i.ID, &i.Handle); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getVisibleChirpIDs = `-- name: GetVisibleChirpIDs :many
+Select id from chirps
+where id = ANY($1::uuid[]) and chirp_visible_to(chirps, $2)
+`
+
+type GetVisibleChirpIDsParams struct {
+	Ids      []uuid.UUID   `json:"ids"`
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+}
+
+func (q *Queries) GetVisibleChirpIDs(ctx context.Context, arg GetVisibleChirpIDsParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getVisibleChirpIDs, pq.Array(arg.Ids), arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateUserPw = `-- name: UpdateUserPw :exec
 Update users
 set hashed_password = $1
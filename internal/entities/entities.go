@@ -0,0 +1,181 @@
+// Package entities finds structured references (hashtags, mentions, links)
+// inside chirp bodies. Offsets are in runes so clients can slice the body
+// without caring about UTF-8.
+package entities
+
+import (
+	"strings"
+	"unicode"
+)
+
+type Kind string
+
+const (
+	Hashtag Kind = "hashtag"
+	Mention Kind = "mention"
+	Link    Kind = "link"
+)
+
+const (
+	maxTagLen    = 100
+	MaxHandleLen = 30
+)
+
+type Entity struct {
+	Kind  Kind   `json:"type"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r) || r == '_'
+}
+
+// Hashtags returns every #tag in body. Text holds the lowercased tag without
+// the leading '#'. Tags must contain at least one letter so "#1" is ignored.
+func Hashtags(body string) []Entity {
+	runes := []rune(body)
+	var found []Entity
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '#' && runes[i] != '＃' {
+			continue
+		}
+		if i > 0 && (isTagRune(runes[i-1]) || runes[i-1] == '&') {
+			continue
+		}
+		j := i + 1
+		hasLetter := false
+		for j < len(runes) && isTagRune(runes[j]) {
+			if unicode.IsLetter(runes[j]) {
+				hasLetter = true
+			}
+			j++
+		}
+		if !hasLetter || j-i-1 > maxTagLen {
+			i = j - 1
+			continue
+		}
+		found = append(found, Entity{
+			Kind:  Hashtag,
+			Text:  NormalizeTag(string(runes[i+1 : j])),
+			Start: i,
+			End:   j,
+		})
+		i = j - 1
+	}
+	return found
+}
+
+func isHandleRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
+
+// ValidHandle reports whether handle could be written as an @mention
+func ValidHandle(handle string) bool {
+	if handle == "" || len(handle) > MaxHandleLen {
+		return false
+	}
+	for _, r := range handle {
+		if !isHandleRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Mentions returns every @handle in body with Text lowercased and without the
+// '@'. Email addresses and over-long handles are not mentions.
+func Mentions(body string) []Entity {
+	runes := []rune(body)
+	var found []Entity
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' {
+			continue
+		}
+		if i > 0 && (isTagRune(runes[i-1]) || runes[i-1] == '@') {
+			continue
+		}
+		j := i + 1
+		for j < len(runes) && isHandleRune(runes[j]) {
+			j++
+		}
+		// "@alice.com" style domains and "@bob@host" addresses are not handles
+		followedByAddr := j < len(runes) && (runes[j] == '@' || isTagRune(runes[j]))
+		if j == i+1 || j-i-1 > MaxHandleLen || followedByAddr {
+			i = j - 1
+			continue
+		}
+		found = append(found, Entity{
+			Kind:  Mention,
+			Text:  strings.ToLower(string(runes[i+1 : j])),
+			Start: i,
+			End:   j,
+		})
+		i = j - 1
+	}
+	return found
+}
+
+// Links returns every http(s) URL in body, with Text holding the URL as
+// written. Punctuation ending a sentence, and a closing bracket with no
+// opening one inside the URL, are not part of it.
+func Links(body string) []Entity {
+	runes := []rune(body)
+	var found []Entity
+	for i := 0; i < len(runes); i++ {
+		if i > 0 && isTagRune(runes[i-1]) {
+			continue
+		}
+		rest := strings.ToLower(string(runes[i:min(i+8, len(runes))]))
+		if !strings.HasPrefix(rest, "http://") && !strings.HasPrefix(rest, "https://") {
+			continue
+		}
+		j := i
+		for j < len(runes) && !unicode.IsSpace(runes[j]) {
+			j++
+		}
+		for j > i {
+			last := runes[j-1]
+			if strings.ContainsRune(".,;:!?'\"", last) {
+				j--
+				continue
+			}
+			if last == ')' && strings.Count(string(runes[i:j]), "(") < strings.Count(string(runes[i:j]), ")") {
+				j--
+				continue
+			}
+			break
+		}
+		if host := strings.SplitN(string(runes[i:j]), "://", 2)[1]; host == "" {
+			i = j
+			continue
+		}
+		found = append(found, Entity{
+			Kind:  Link,
+			Text:  string(runes[i:j]),
+			Start: i,
+			End:   j,
+		})
+		i = j - 1
+	}
+	return found
+}
+
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimLeft(tag, "#＃"))
+}
+
+// Unique returns the distinct Text values of ents in first-seen order
+func Unique(ents []Entity) []string {
+	seen := make(map[string]struct{}, len(ents))
+	var out []string
+	for _, e := range ents {
+		if _, ok := seen[e.Text]; ok {
+			continue
+		}
+		seen[e.Text] = struct{}{}
+		out = append(out, e.Text)
+	}
+	return out
+}
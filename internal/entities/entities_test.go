@@ -0,0 +1,119 @@
+package entities_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Lewvy/chirpy/internal/entities"
+)
+
+func TestHashtags(t *testing.T) {
+	cases := []struct {
+		body string
+		want []string
+	}{
+		{"no tags here", nil},
+		{"#Go is #fun", []string{"go", "fun"}},
+		{"trailing punctuation #done.", []string{"done"}},
+		{"numbers only #2024 but #go2024 counts", []string{"go2024"}},
+		{"mid#word and &#39; are not tags", nil},
+		{"unicode #café #日本語 #Ünïcödé", []string{"café", "日本語", "ünïcödé"}},
+		{"fullwidth ＃タグ works", []string{"タグ"}},
+		{"dupes #a #A #a", []string{"a", "a", "a"}},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, e := range entities.Hashtags(c.body) {
+			got = append(got, e.Text)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Hashtags(%q) = %v, want %v", c.body, got, c.want)
+		}
+	}
+}
+
+func TestHashtagOffsetsAreRunes(t *testing.T) {
+	body := "héllo #wörld"
+	tags := entities.Hashtags(body)
+	if len(tags) != 1 {
+		t.Fatalf("expected one tag, got %v", tags)
+	}
+	if got := string([]rune(body)[tags[0].Start:tags[0].End]); got != "#wörld" {
+		t.Errorf("offsets point at %q", got)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	got := entities.Unique(entities.Hashtags("#a #b #A #c #b"))
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unique = %v, want %v", got, want)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	cases := []struct {
+		body string
+		want []string
+	}{
+		{"hello @Alice and @bob_2!", []string{"alice", "bob_2"}},
+		{"mail me at someone@example.com", nil},
+		{"@ alone and @@double", nil},
+		{"(@paren) works", []string{"paren"}},
+		{"@abcdefghijklmnopqrstuvwxyz012345 is too long", nil},
+		{"@café is not a handle", nil},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, e := range entities.Mentions(c.body) {
+			got = append(got, e.Text)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Mentions(%q) = %v, want %v", c.body, got, c.want)
+		}
+	}
+}
+
+func TestValidHandle(t *testing.T) {
+	for handle, want := range map[string]bool{
+		"alice":                           true,
+		"Bob_99":                          true,
+		"":                                false,
+		"has space":                       false,
+		"émile":                           false,
+		"abcdefghijklmnopqrstuvwxyz01234": false,
+	} {
+		if got := entities.ValidHandle(handle); got != want {
+			t.Errorf("ValidHandle(%q) = %v, want %v", handle, got, want)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	cases := []struct {
+		body string
+		want []string
+	}{
+		{"no links here", nil},
+		{"see https://example.com/a?b=c.", []string{"https://example.com/a?b=c"}},
+		{"(docs at http://go.dev/doc)", []string{"http://go.dev/doc"}},
+		{"wiki https://en.wikipedia.org/wiki/Go_(game) ok", []string{"https://en.wikipedia.org/wiki/Go_(game)"}},
+		{"two: HTTPS://A.example, https://b.example!", []string{"HTTPS://A.example", "https://b.example"}},
+		{"not a link: xhttps://evil.example or https://", nil},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, e := range entities.Links(c.body) {
+			got = append(got, e.Text)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Links(%q) = %v, want %v", c.body, got, c.want)
+		}
+	}
+
+	body := "café https://x.example"
+	links := entities.Links(body)
+	if len(links) != 1 || links[0].Start != 5 || links[0].End != 22 {
+		t.Errorf("offsets are not in runes: %+v", links)
+	}
+}
@@ -0,0 +1,46 @@
+package events
+
+import (
+	"net/url"
+	"sort"
+)
+
+// Cursor remembers the last event delivered per topic. It is sent to SSE
+// clients as the event ID so a single Last-Event-ID can resume every topic.
+type Cursor map[string]string
+
+func ParseCursor(s string) Cursor {
+	c := Cursor{}
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return c
+	}
+	for topic, ids := range values {
+		if len(ids) > 0 && ids[0] != "" {
+			c[topic] = ids[0]
+		}
+	}
+	return c
+}
+
+func (c Cursor) String() string {
+	topics := make([]string, 0, len(c))
+	for t := range c {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	values := url.Values{}
+	for _, t := range topics {
+		values.Set(t, c[t])
+	}
+	return values.Encode()
+}
+
+// Advance records ev and reports whether it is newer than what was delivered
+func (c Cursor) Advance(ev Event) bool {
+	if last, ok := c[ev.Topic]; ok && CompareIDs(ev.ID, last) <= 0 {
+		return false
+	}
+	c[ev.Topic] = ev.ID
+	return true
+}
@@ -0,0 +1,51 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/Lewvy/chirpy/internal/events"
+)
+
+func TestCompareIDs(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1-0", "1-0", 0},
+		{"1-0", "1-1", -1},
+		{"2-0", "1-9", 1},
+		{"1700000000000-5", "1700000000001-0", -1},
+	}
+	for _, c := range cases {
+		if got := events.CompareIDs(c.a, c.b); got != c.want {
+			t.Errorf("CompareIDs(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := events.Cursor{}
+	c.Advance(events.Event{ID: "5-0", Topic: "chirps"})
+	c.Advance(events.Event{ID: "7-1", Topic: "notifications:abc"})
+
+	parsed := events.ParseCursor(c.String())
+	if parsed["chirps"] != "5-0" || parsed["notifications:abc"] != "7-1" {
+		t.Errorf("cursor did not round trip: %v", parsed)
+	}
+}
+
+func TestCursorSkipsDelivered(t *testing.T) {
+	c := events.Cursor{"chirps": "5-0"}
+	if c.Advance(events.Event{ID: "4-0", Topic: "chirps"}) {
+		t.Error("older event should not advance the cursor")
+	}
+	if c.Advance(events.Event{ID: "5-0", Topic: "chirps"}) {
+		t.Error("duplicate event should not advance the cursor")
+	}
+	if !c.Advance(events.Event{ID: "5-1", Topic: "chirps"}) {
+		t.Error("newer event should advance the cursor")
+	}
+	if !c.Advance(events.Event{ID: "1-0", Topic: "other"}) {
+		t.Error("first event on a topic should advance the cursor")
+	}
+}
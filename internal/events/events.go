@@ -0,0 +1,245 @@
+// Package events fans chirpy events out to live connections on every server
+// instance. Each topic is backed by a capped Valkey stream, which gives events
+// stable IDs for resuming, and a pub/sub channel that carries them live.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	keyPrefix = "events:"
+	// Streams keep enough history for a reconnecting client to catch up
+	streamMaxLen = "10000"
+	replayLimit  = 500
+	subBuffer    = 64
+)
+
+const (
+	TopicChirps = "chirps"
+)
+
+func NotificationsTopic(userID string) string {
+	return "notifications:" + userID
+}
+
+type Event struct {
+	ID    string          `json:"id"`
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+}
+
+type Broker struct {
+	cache valkey.Client
+
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	topics map[string]struct{}
+	broker *Broker
+	once   sync.Once
+	// Set when the subscriber could not keep up and events were dropped
+	Lagged chan struct{}
+}
+
+func NewBroker(cache valkey.Client) *Broker {
+	return &Broker{cache: cache, subs: make(map[*Subscription]struct{})}
+}
+
+// Run relays pub/sub messages to local subscribers until ctx is cancelled,
+// resubscribing if the connection drops.
+func (b *Broker) Run(ctx context.Context) {
+	for {
+		err := b.cache.Receive(ctx, b.cache.B().Psubscribe().Pattern(keyPrefix+"*").Build(), func(msg valkey.PubSubMessage) {
+			var ev Event
+			if err := json.Unmarshal([]byte(msg.Message), &ev); err != nil {
+				log.Println("Dropping malformed event: ", err)
+				return
+			}
+			b.dispatch(ev)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Println("Event subscription lost, retrying: ", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (b *Broker) dispatch(ev Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if _, ok := sub.topics[ev.Topic]; !ok {
+			continue
+		}
+		select {
+		case sub.c <- ev:
+		default:
+			select {
+			case sub.Lagged <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Publish appends the event to the topic's stream and broadcasts it
+func (b *Broker) Publish(ctx context.Context, topic, typ string, data any) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	key := keyPrefix + topic
+	id, err := b.cache.Do(ctx, b.cache.B().Xadd().Key(key).Maxlen().Almost().Threshold(streamMaxLen).
+		Id("*").FieldValue().FieldValue("type", typ).FieldValue("data", string(payload)).Build()).ToString()
+	if err != nil {
+		return Event{}, fmt.Errorf("appending to %s: %w", key, err)
+	}
+
+	ev := Event{ID: id, Topic: topic, Type: typ, Data: payload}
+	msg, err := json.Marshal(ev)
+	if err != nil {
+		return Event{}, err
+	}
+	if err := b.cache.Do(ctx, b.cache.B().Publish().Channel(key).Message(string(msg)).Build()).Error(); err != nil {
+		return Event{}, fmt.Errorf("publishing to %s: %w", key, err)
+	}
+	return ev, nil
+}
+
+// Returned by Replay when the stream has been trimmed past the cursor, so
+// some of the events the client missed no longer exist
+var ErrTrimmed = errors.New("events after the cursor have been trimmed")
+
+// Replay returns every event on topic newer than afterID, oldest first,
+// reading the stream replayLimit entries at a time
+func (b *Broker) Replay(ctx context.Context, topic, afterID string) ([]Event, error) {
+	key := keyPrefix + topic
+	if err := b.checkNotTrimmed(ctx, key, afterID); err != nil {
+		return nil, err
+	}
+	var evs []Event
+	for {
+		entries, err := b.cache.Do(ctx, b.cache.B().Xrange().Key(key).
+			Start("("+afterID).End("+").Count(replayLimit).Build()).AsXRange()
+		if err != nil {
+			return nil, fmt.Errorf("replaying %s: %w", topic, err)
+		}
+		for _, e := range entries {
+			evs = append(evs, Event{ID: e.ID, Topic: topic, Type: e.FieldValues["type"], Data: json.RawMessage(e.FieldValues["data"])})
+		}
+		if len(entries) < replayLimit {
+			return evs, nil
+		}
+		afterID = entries[len(entries)-1].ID
+	}
+}
+
+// checkNotTrimmed returns ErrTrimmed when capping the stream has removed
+// events newer than afterID
+func (b *Broker) checkNotTrimmed(ctx context.Context, key, afterID string) error {
+	info, err := b.cache.Do(ctx, b.cache.B().XinfoStream().Key(key).Build()).AsMap()
+	if err != nil {
+		if strings.Contains(err.Error(), "no such key") {
+			return nil
+		}
+		return fmt.Errorf("inspecting %s: %w", key, err)
+	}
+	msg, ok := info["max-deleted-entry-id"]
+	if !ok {
+		// Servers older than Redis 7 don't track it
+		return nil
+	}
+	deleted, err := msg.ToString()
+	if err != nil {
+		return fmt.Errorf("inspecting %s: %w", key, err)
+	}
+	if CompareIDs(afterID, deleted) < 0 {
+		return ErrTrimmed
+	}
+	return nil
+}
+
+// LastID returns the ID of the newest event on topic, or "0-0" when there
+// is none. Replaying from it yields only events published afterwards.
+func (b *Broker) LastID(ctx context.Context, topic string) (string, error) {
+	entries, err := b.cache.Do(ctx, b.cache.B().Xrevrange().Key(keyPrefix+topic).
+		End("+").Start("-").Count(1).Build()).AsXRange()
+	if err != nil {
+		return "", fmt.Errorf("reading the end of %s: %w", topic, err)
+	}
+	if len(entries) == 0 {
+		return "0-0", nil
+	}
+	return entries[0].ID, nil
+}
+
+func (b *Broker) Subscribe(topics ...string) *Subscription {
+	c := make(chan Event, subBuffer)
+	sub := &Subscription{
+		C:      c,
+		c:      c,
+		topics: make(map[string]struct{}, len(topics)),
+		broker: b,
+		Lagged: make(chan struct{}, 1),
+	}
+	for _, t := range topics {
+		sub.topics[t] = struct{}{}
+	}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.broker.mu.Lock()
+		delete(s.broker.subs, s)
+		s.broker.mu.Unlock()
+	})
+}
+
+// CompareIDs orders two stream IDs ("<ms>-<seq>"), returning -1, 0 or 1
+func CompareIDs(a, b string) int {
+	am, as := splitID(a)
+	bm, bs := splitID(b)
+	switch {
+	case am < bm:
+		return -1
+	case am > bm:
+		return 1
+	case as < bs:
+		return -1
+	case as > bs:
+		return 1
+	}
+	return 0
+}
+
+func splitID(id string) (uint64, uint64) {
+	var ms, seq uint64
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	fmt.Sscan(msPart, &ms)
+	fmt.Sscan(seqPart, &seq)
+	return ms, seq
+}
@@ -0,0 +1,83 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/Lewvy/chirpy/internal/events"
+	"github.com/google/uuid"
+	"github.com/valkey-io/valkey-go"
+)
+
+// Like the handler tests, these need CHIRPY_TEST_VALKEY_ADDR. Each test
+// works on its own topic.
+func newTestBroker(t *testing.T) (*events.Broker, valkey.Client, string) {
+	t.Helper()
+	addr := os.Getenv("CHIRPY_TEST_VALKEY_ADDR")
+	if addr == "" {
+		t.Skip("CHIRPY_TEST_VALKEY_ADDR is not set")
+	}
+	cache, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{addr}})
+	if err != nil {
+		t.Fatalf("connecting to valkey: %v", err)
+	}
+	topic := "test:" + uuid.NewString()
+	t.Cleanup(func() {
+		cache.Do(context.Background(), cache.B().Del().Key("events:"+topic).Build())
+		cache.Close()
+	})
+	return events.NewBroker(cache), cache, topic
+}
+
+func TestReplayReadsPastOneBatch(t *testing.T) {
+	b, _, topic := newTestBroker(t)
+	ctx := t.Context()
+	first, err := b.Publish(ctx, topic, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 1200
+	for i := 1; i <= n; i++ {
+		if _, err := b.Publish(ctx, topic, "test", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	evs, err := b.Replay(ctx, topic, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != n {
+		t.Fatalf("replayed %d events, want %d", len(evs), n)
+	}
+	for i := 1; i < len(evs); i++ {
+		if events.CompareIDs(evs[i-1].ID, evs[i].ID) >= 0 {
+			t.Fatalf("events %d and %d are out of order", i-1, i)
+		}
+	}
+}
+
+func TestReplayReportsTrimmedEvents(t *testing.T) {
+	b, cache, topic := newTestBroker(t)
+	ctx := t.Context()
+	var published []events.Event
+	for i := range 3 {
+		ev, err := b.Publish(ctx, topic, "test", i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		published = append(published, ev)
+	}
+	if err := cache.Do(ctx, cache.B().Xtrim().Key("events:"+topic).Maxlen().Threshold("1").Build()).Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Replay(ctx, topic, published[0].ID); !errors.Is(err, events.ErrTrimmed) {
+		t.Errorf("replaying from before the trim: err = %v, want ErrTrimmed", err)
+	}
+	evs, err := b.Replay(ctx, topic, published[1].ID)
+	if err != nil || len(evs) != 1 || evs[0].ID != published[2].ID {
+		t.Errorf("replaying from the last trimmed event = %+v, %v; want only the newest", evs, err)
+	}
+}
@@ -0,0 +1,225 @@
+// Package feeds renders a list of items as Atom 1.0, RSS 2.0 or JSON Feed 1.1.
+package feeds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Item struct {
+	ID        uuid.UUID
+	URL       string
+	Title     string
+	Content   string
+	Author    string
+	Published time.Time
+	Updated   time.Time
+}
+
+type Feed struct {
+	Title       string
+	Description string
+	// HTML page the feed describes and the feed's own URL
+	HomeURL string
+	FeedURL string
+	Items   []Item
+}
+
+// Updated is the newest item timestamp, or the zero time for an empty feed
+func (f Feed) Updated() time.Time {
+	var latest time.Time
+	for _, it := range f.Items {
+		if it.Updated.After(latest) {
+			latest = it.Updated
+		}
+	}
+	return latest.UTC()
+}
+
+// GUIDs stay stable across renames and formats because they are built from
+// the chirp's UUID alone
+func guid(id uuid.UUID) string {
+	return id.URN()
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Link      atomLink   `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Author    atomPerson `xml:"author"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+func (f Feed) Atom() ([]byte, error) {
+	updated := f.Updated()
+	if updated.IsZero() {
+		updated = time.Unix(0, 0).UTC()
+	}
+	feed := atomFeed{
+		ID:       f.FeedURL,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.FeedURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.HomeURL, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, it := range f.Items {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        guid(it.ID),
+			Title:     it.Title,
+			Link:      atomLink{Href: it.URL, Rel: "alternate"},
+			Published: it.Published.UTC().Format(time.RFC3339),
+			Updated:   it.Updated.UTC().Format(time.RFC3339),
+			Author:    atomPerson{Name: it.Author},
+			Content:   atomText{Type: "text", Body: it.Content},
+		})
+	}
+	return marshalXML(feed)
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	GUID        rssGUID `xml:"guid"`
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	Author      string  `xml:"dc:creator,omitempty"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+func (f Feed) RSS() ([]byte, error) {
+	feed := rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.HomeURL,
+			Description: f.Description,
+			AtomLink:    atomLink{Href: f.FeedURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if updated := f.Updated(); !updated.IsZero() {
+		feed.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+	for _, it := range f.Items {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			GUID:        rssGUID{IsPermaLink: false, Value: guid(it.ID)},
+			Title:       it.Title,
+			Link:        it.URL,
+			Description: it.Content,
+			Author:      it.Author,
+			PubDate:     it.Published.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return marshalXML(feed)
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title,omitempty"`
+	ContentText   string           `json:"content_text"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+func (f Feed) JSON() ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.HomeURL,
+		FeedURL:     f.FeedURL,
+		Description: f.Description,
+		Items:       []jsonFeedItem{},
+	}
+	for _, it := range f.Items {
+		item := jsonFeedItem{
+			ID:            guid(it.ID),
+			URL:           it.URL,
+			ContentText:   it.Content,
+			DatePublished: it.Published.UTC().Format(time.RFC3339),
+			DateModified:  it.Updated.UTC().Format(time.RFC3339),
+		}
+		if it.Author != "" {
+			item.Authors = []jsonFeedAuthor{{Name: it.Author}}
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return json.MarshalIndent(feed, "", "  ")
+}
+
+func marshalXML(v any) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
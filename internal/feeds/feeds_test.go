@@ -0,0 +1,97 @@
+package feeds_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/feeds"
+	"github.com/google/uuid"
+)
+
+func sampleFeed() feeds.Feed {
+	id := uuid.MustParse("0b7c2f8e-6f0e-4e57-9d1b-3c1e4f0a9a11")
+	published := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	return feeds.Feed{
+		Title:   "@alice on Chirpy",
+		HomeURL: "https://chirpy.example/users/alice",
+		FeedURL: "https://chirpy.example/users/alice/feed.atom",
+		Items: []feeds.Item{{
+			ID:        id,
+			URL:       "https://chirpy.example/api/chirps/" + id.String(),
+			Title:     "hello <world> & friends",
+			Content:   "hello <world> & friends",
+			Author:    "alice",
+			Published: published,
+			Updated:   published.Add(time.Hour),
+		}},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	body, err := sampleFeed().Atom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		Updated string `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Content string `xml:"content"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(body, &parsed); err != nil {
+		t.Fatalf("atom is not valid XML: %v\n%s", err, body)
+	}
+	if parsed.Updated != "2025-03-01T11:00:00Z" {
+		t.Errorf("feed updated = %q, want newest item update", parsed.Updated)
+	}
+	if len(parsed.Entries) != 1 || parsed.Entries[0].ID != "urn:uuid:0b7c2f8e-6f0e-4e57-9d1b-3c1e4f0a9a11" {
+		t.Errorf("unexpected entries %+v", parsed.Entries)
+	}
+	if parsed.Entries[0].Content != "hello <world> & friends" {
+		t.Errorf("content not escaped/unescaped correctly: %q", parsed.Entries[0].Content)
+	}
+}
+
+func TestRSS(t *testing.T) {
+	body, err := sampleFeed().RSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `<guid isPermaLink="false">urn:uuid:0b7c2f8e-6f0e-4e57-9d1b-3c1e4f0a9a11</guid>`) {
+		t.Errorf("missing stable guid:\n%s", body)
+	}
+	if !strings.Contains(string(body), "<pubDate>Sat, 01 Mar 2025 10:00:00 +0000</pubDate>") {
+		t.Errorf("missing RFC 1123 pubDate:\n%s", body)
+	}
+}
+
+func TestJSONFeed(t *testing.T) {
+	body, err := sampleFeed().JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed map[string]any
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed["version"] != "https://jsonfeed.org/version/1.1" {
+		t.Errorf("unexpected version %v", parsed["version"])
+	}
+	items := parsed["items"].([]any)
+	if len(items) != 1 || items[0].(map[string]any)["id"] != "urn:uuid:0b7c2f8e-6f0e-4e57-9d1b-3c1e4f0a9a11" {
+		t.Errorf("unexpected items %v", items)
+	}
+}
+
+func TestEmptyFeeds(t *testing.T) {
+	f := feeds.Feed{Title: "empty", HomeURL: "https://x", FeedURL: "https://x/feed"}
+	for name, render := range map[string]func() ([]byte, error){"atom": f.Atom, "rss": f.RSS, "json": f.JSON} {
+		if _, err := render(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
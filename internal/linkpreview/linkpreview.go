@@ -0,0 +1,192 @@
+// Package linkpreview fetches the OpenGraph and Twitter card metadata of a
+// web page for display under a chirp that links to it.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	DefaultMaxBytes = 512 << 10
+	DefaultTimeout  = 5 * time.Second
+	maxRedirects    = 3
+
+	maxTitle       = 200
+	maxDescription = 500
+	maxSiteName    = 100
+)
+
+var (
+	ErrNotHTML     = errors.New("not an HTML page")
+	ErrNoMetadata  = errors.New("page has no title or description")
+	ErrBadScheme   = errors.New("only http and https links are previewed")
+	errTooManyHops = errors.New("too many redirects")
+)
+
+type Preview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url"`
+	SiteName    string `json:"site_name"`
+}
+
+type Fetcher struct {
+	Client    *http.Client
+	MaxBytes  int64
+	UserAgent string
+}
+
+// New returns a Fetcher whose client refuses to reach private addresses,
+// follows at most a few redirects and gives up after DefaultTimeout
+func New() *Fetcher {
+	return &Fetcher{
+		Client: &http.Client{
+			Transport:     NewTransport(),
+			Timeout:       DefaultTimeout,
+			CheckRedirect: checkRedirect,
+		},
+		MaxBytes:  DefaultMaxBytes,
+		UserAgent: "ChirpyBot/1.0 (link preview)",
+	}
+}
+
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxRedirects {
+		return errTooManyHops
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return ErrBadScheme
+	}
+	return nil
+}
+
+// Fetch downloads at most MaxBytes of the page at rawURL and extracts its
+// preview. Relative image URLs are resolved against the final page URL.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Preview{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Preview{}, ErrBadScheme
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Preview{}, fmt.Errorf("fetching %s: status %d", rawURL, resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, fmt.Errorf("%w: %s", ErrNotHTML, mediaType)
+	}
+	max := f.MaxBytes
+	if max <= 0 {
+		max = DefaultMaxBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, max))
+	if err != nil {
+		return Preview{}, err
+	}
+	p := Parse(string(body), resp.Request.URL)
+	p.URL = rawURL
+	if p.Title == "" && p.Description == "" {
+		return Preview{}, ErrNoMetadata
+	}
+	return p, nil
+}
+
+var (
+	metaTag   = regexp.MustCompile(`(?is)<meta\s((?:[^>"']|"[^"]*"|'[^']*')*)>`)
+	attribute = regexp.MustCompile(`(?s)([a-zA-Z_:.-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	titleTag  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	headEnd   = regexp.MustCompile(`(?i)</head\s*>`)
+)
+
+// Parse pulls preview fields out of an HTML document, preferring OpenGraph
+// tags, then Twitter card tags, then the plain title and description
+func Parse(doc string, base *url.URL) Preview {
+	if loc := headEnd.FindStringIndex(doc); loc != nil {
+		doc = doc[:loc[0]]
+	}
+	meta := make(map[string]string)
+	for _, m := range metaTag.FindAllStringSubmatch(doc, -1) {
+		attrs := make(map[string]string)
+		for _, a := range attribute.FindAllStringSubmatch(m[1], -1) {
+			attrs[strings.ToLower(a[1])] = a[2] + a[3] + a[4]
+		}
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		key = strings.ToLower(key)
+		if _, seen := meta[key]; key != "" && !seen {
+			meta[key] = attrs["content"]
+		}
+	}
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v := clean(meta[k]); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	p := Preview{
+		Title:       first("og:title", "twitter:title"),
+		Description: first("og:description", "twitter:description", "description"),
+		SiteName:    first("og:site_name"),
+	}
+	if p.Title == "" {
+		if m := titleTag.FindStringSubmatch(doc); m != nil {
+			p.Title = clean(m[1])
+		}
+	}
+	if img := first("og:image:secure_url", "og:image", "twitter:image", "twitter:image:src"); img != "" {
+		if ref, err := url.Parse(img); err == nil && base != nil {
+			ref = base.ResolveReference(ref)
+			if ref.Scheme == "http" || ref.Scheme == "https" {
+				p.ImageURL = ref.String()
+			}
+		}
+	}
+	p.Title = truncate(p.Title, maxTitle)
+	p.Description = truncate(p.Description, maxDescription)
+	p.SiteName = truncate(p.SiteName, maxSiteName)
+	return p
+}
+
+func clean(s string) string {
+	s = html.UnescapeString(strings.ToValidUTF8(s, ""))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-1]) + "…"
+}
@@ -0,0 +1,146 @@
+package linkpreview_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/linkpreview"
+)
+
+const page = `<!doctype html>
+<html><head>
+<title>Fallback title</title>
+<meta name="description" content="Plain description">
+<meta property="og:title" content="Gophers &amp; friends">
+<meta name="twitter:title" content="Twitter title">
+<meta property='og:description' content="Says a > b, then
+  wraps">
+<meta property="og:image" content="/img/card.png">
+<meta property="og:site_name" content="Example">
+</head><body><meta property="og:title" content="not in head"></body></html>`
+
+// A fetcher that may reach the test server, which listens on loopback
+func testFetcher(srv *httptest.Server) *linkpreview.Fetcher {
+	return &linkpreview.Fetcher{Client: srv.Client(), MaxBytes: linkpreview.DefaultMaxBytes}
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(page))
+		case "/old":
+			http.Redirect(w, r, "/article", http.StatusMovedPermanently)
+		case "/data.json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"title":"no"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	f := testFetcher(srv)
+
+	p, err := f.Fetch(context.Background(), srv.URL+"/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := linkpreview.Preview{
+		URL:         srv.URL + "/old",
+		Title:       "Gophers & friends",
+		Description: "Says a > b, then wraps",
+		ImageURL:    srv.URL + "/img/card.png",
+		SiteName:    "Example",
+	}
+	if p != want {
+		t.Errorf("got %+v\nwant %+v", p, want)
+	}
+
+	if _, err := f.Fetch(context.Background(), srv.URL+"/data.json"); !errors.Is(err, linkpreview.ErrNotHTML) {
+		t.Errorf("json: got %v", err)
+	}
+	if _, err := f.Fetch(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Error("404 was previewed")
+	}
+	if _, err := f.Fetch(context.Background(), "file:///etc/passwd"); !errors.Is(err, linkpreview.ErrBadScheme) {
+		t.Errorf("file url: got %v", err)
+	}
+}
+
+func TestFetchLimits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/huge":
+			// The title only arrives after the size limit
+			w.Write([]byte("<html><head>" + strings.Repeat(" ", 4096) + "<title>late</title>"))
+		}
+	}))
+	defer srv.Close()
+
+	f := testFetcher(srv)
+	f.MaxBytes = 1024
+	if _, err := f.Fetch(context.Background(), srv.URL+"/huge"); !errors.Is(err, linkpreview.ErrNoMetadata) {
+		t.Errorf("huge: got %v", err)
+	}
+
+	f.Client.Timeout = 50 * time.Millisecond
+	if _, err := f.Fetch(context.Background(), srv.URL+"/slow"); err == nil {
+		t.Error("slow server did not time out")
+	}
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	f := linkpreview.New()
+	for _, u := range []string{
+		srv.URL,
+		strings.Replace(srv.URL, "127.0.0.1", "localhost", 1),
+	} {
+		if _, err := f.Fetch(context.Background(), u); !errors.Is(err, linkpreview.ErrBlockedAddress) {
+			t.Errorf("%s: got %v, want ErrBlockedAddress", u, err)
+		}
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	for _, addr := range []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:10.0.0.1", "224.0.0.1",
+	} {
+		if !linkpreview.Blocked(netip.MustParseAddr(addr)) {
+			t.Errorf("%s is not blocked", addr)
+		}
+	}
+	for _, addr := range []string{"93.184.216.34", "2606:4700::1111", "8.8.8.8"} {
+		if linkpreview.Blocked(netip.MustParseAddr(addr)) {
+			t.Errorf("%s is blocked", addr)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	ctx := context.Background()
+	for _, host := range []string{"127.0.0.1", "localhost", "::1", "169.254.169.254", "10.0.0.5"} {
+		if err := linkpreview.CheckHost(ctx, host); !errors.Is(err, linkpreview.ErrBlockedAddress) {
+			t.Errorf("%s: got %v, want ErrBlockedAddress", host, err)
+		}
+	}
+	if err := linkpreview.CheckHost(ctx, "93.184.216.34"); err != nil {
+		t.Errorf("public address refused: %v", err)
+	}
+}
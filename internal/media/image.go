@@ -0,0 +1,76 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+const (
+	MaxUploadBytes = 5 << 20
+	// Caps decoded size so a small, highly compressed file cannot expand
+	// into gigabytes of pixels
+	MaxPixels = 40_000_000
+)
+
+var ErrUnsupportedType = errors.New("unsupported media type")
+
+// Sniffed content type to file extension for the formats we can decode
+var Extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+type Info struct {
+	ContentType string
+	Width       int
+	Height      int
+	Blurhash    string
+}
+
+// Inspect checks that data really is an image we accept, whatever the
+// client claimed, and measures it the right way up.
+func Inspect(data []byte) (Info, image.Image, error) {
+	if len(data) > MaxUploadBytes {
+		return Info{}, nil, fmt.Errorf("file is larger than %d bytes", MaxUploadBytes)
+	}
+	contentType := http.DetectContentType(data)
+	if _, ok := Extensions[contentType]; !ok {
+		return Info{}, nil, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Info{}, nil, fmt.Errorf("reading image header: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return Info{}, nil, fmt.Errorf("image is %dx%d, more than %d pixels", cfg.Width, cfg.Height, MaxPixels)
+	}
+	img, err := decode(data)
+	if err != nil {
+		return Info{}, nil, err
+	}
+	return Info{
+		ContentType: contentType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Blurhash:    Blurhash(img, 4, 3),
+	}, img, nil
+}
+
+// Decodes an image and turns it upright if its EXIF data says it is rotated
+func decode(data []byte) (image.Image, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+	return img, nil
+}
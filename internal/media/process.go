@@ -0,0 +1,233 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+type Variant struct {
+	Name string
+	// Longest side in pixels; smaller images are never scaled up
+	MaxSize int
+}
+
+var Variants = []Variant{
+	{Name: "thumb", MaxSize: 320},
+	{Name: "medium", MaxSize: 1280},
+	{Name: "full", MaxSize: 4096},
+}
+
+const (
+	jpegQuality = 82
+
+	// An animation over either limit keeps only its first frame. Every
+	// frame is held in memory at once, so the pixels are capped across all
+	// of them rather than per frame.
+	MaxAnimationFrames = 500
+	MaxAnimationPixels = 20_000_000
+)
+
+// Rendition is one encoded variant of an image
+type Rendition struct {
+	Variant     string
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Process re-encodes an uploaded image into every variant. Nothing from the
+// original file but its pixels survives, so EXIF and other metadata are
+// stripped. Opaque images become JPEG and the rest PNG, except that an
+// animated GIF keeps its animation in the full variant.
+func Process(data []byte) ([]Rendition, error) {
+	img, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	var anim *gif.GIF
+	if http.DetectContentType(data) == "image/gif" && animationFits(data) {
+		if g, err := gif.DecodeAll(bytes.NewReader(data)); err == nil && len(g.Image) > 1 {
+			anim = g
+		}
+	}
+
+	out := make([]Rendition, 0, len(Variants))
+	for _, v := range Variants {
+		var r Rendition
+		if anim != nil && v.Name == "full" && fits(anim.Config.Width, anim.Config.Height, v.MaxSize) {
+			r, err = encodeGIF(anim)
+		} else {
+			r, err = encode(Resize(img, v.MaxSize))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("encoding %s variant: %w", v.Name, err)
+		}
+		r.Variant = v.Name
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// animationFits walks the GIF's blocks without decoding any pixels and
+// reports whether its frames stay within the animation limits
+func animationFits(data []byte) bool {
+	// Header and logical screen descriptor
+	if len(data) < 13 {
+		return false
+	}
+	i := 13 + colorTableSize(data[10])
+	frames, pixels := 0, 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // extension: label, then sub-blocks
+			i = skipSubBlocks(data, i+2)
+		case 0x2C: // image descriptor
+			if i+10 > len(data) {
+				return false
+			}
+			w := int(data[i+5]) | int(data[i+6])<<8
+			h := int(data[i+7]) | int(data[i+8])<<8
+			frames++
+			pixels += w * h
+			if frames > MaxAnimationFrames || pixels > MaxAnimationPixels {
+				return false
+			}
+			// Local color table and LZW minimum code size precede the data
+			i = skipSubBlocks(data, i+10+colorTableSize(data[i+9])+1)
+		case 0x3B: // trailer
+			return true
+		default:
+			return false
+		}
+		if i < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func colorTableSize(flags byte) int {
+	if flags&0x80 == 0 {
+		return 0
+	}
+	return 3 << (flags&0x07 + 1)
+}
+
+// skipSubBlocks returns the offset just past the sub-blocks starting at i,
+// or -1 if they run off the end of data
+func skipSubBlocks(data []byte, i int) int {
+	for i < len(data) {
+		n := int(data[i])
+		i++
+		if n == 0 {
+			return i
+		}
+		i += n
+	}
+	return -1
+}
+
+func fits(w, h, max int) bool {
+	return w <= max && h <= max
+}
+
+func encode(img image.Image) (Rendition, error) {
+	var buf bytes.Buffer
+	r := Rendition{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if opaque(img) {
+		r.ContentType = "image/jpeg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return r, err
+		}
+	} else {
+		r.ContentType = "image/png"
+		enc := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := enc.Encode(&buf, img); err != nil {
+			return r, err
+		}
+	}
+	r.Data = buf.Bytes()
+	return r, nil
+}
+
+// Re-encodes only the frames, timing and palette; comment and application
+// extensions are left behind
+func encodeGIF(g *gif.GIF) (Rendition, error) {
+	var buf bytes.Buffer
+	clean := &gif.GIF{
+		Image:     g.Image,
+		Delay:     g.Delay,
+		LoopCount: g.LoopCount,
+		Disposal:  g.Disposal,
+		Config:    g.Config,
+	}
+	if err := gif.EncodeAll(&buf, clean); err != nil {
+		return Rendition{}, err
+	}
+	return Rendition{
+		Data:        buf.Bytes(),
+		ContentType: "image/gif",
+		Width:       g.Config.Width,
+		Height:      g.Config.Height,
+	}, nil
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// Resize scales img down so its longest side is at most max pixels,
+// averaging every source pixel that falls under each destination pixel
+func Resize(img image.Image, max int) image.Image {
+	src := toRGBA(img)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if fits(w, h, max) {
+		return src
+	}
+	dw, dh := max, h*max/w
+	if h > w {
+		dw, dh = w*max/h, max
+	}
+	dw, dh = clampMin(dw, 1), clampMin(dh, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := range dh {
+		y0, y1 := dy*h/dh, clampMin((dy+1)*h/dh, dy*h/dh+1)
+		for dx := range dw {
+			x0, x1 := dx*w/dw, clampMin((dx+1)*w/dw, dx*w/dw+1)
+			var sum [4]int
+			for y := y0; y < y1; y++ {
+				i := src.PixOffset(x0, y)
+				for x := x0; x < x1; x++ {
+					sum[0] += int(src.Pix[i])
+					sum[1] += int(src.Pix[i+1])
+					sum[2] += int(src.Pix[i+2])
+					sum[3] += int(src.Pix[i+3])
+					i += 4
+				}
+			}
+			n := (x1 - x0) * (y1 - y0)
+			di := dst.PixOffset(dx, dy)
+			for c := range 4 {
+				dst.Pix[di+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+func clampMin(v, min int) int {
+	if v < min {
+		return min
+	}
+	return v
+}
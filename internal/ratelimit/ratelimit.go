@@ -0,0 +1,149 @@
+// Package ratelimit counts requests in a sliding window per key. Limits are
+// kept in Valkey so every instance shares them, with an in-memory limiter to
+// fall back on when Valkey is unreachable.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule allows Limit requests in any Window
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// ParseRule reads a rule written as "<limit>/<window>", e.g. "10/1m"
+func ParseRule(s string) (Rule, error) {
+	limit, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("rate limit %q: want <limit>/<window>", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(limit))
+	if err != nil || n < 1 {
+		return Rule{}, fmt.Errorf("rate limit %q: limit must be a positive integer", s)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d <= 0 {
+		return Rule{}, fmt.Errorf("rate limit %q: window must be a positive duration", s)
+	}
+	return Rule{Limit: n, Window: d}, nil
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Time until the oldest counted request leaves the window and frees a slot
+	Reset time.Duration
+}
+
+// SetHeaders writes the RateLimit-* headers, plus Retry-After when the
+// request was refused
+func (r Result) SetHeaders(h http.Header) {
+	reset := strconv.Itoa(ceilSeconds(r.Reset))
+	h.Set("RateLimit-Limit", strconv.Itoa(r.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+	h.Set("RateLimit-Reset", reset)
+	if !r.Allowed {
+		h.Set("Retry-After", reset)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
+type Limiter interface {
+	// Allow counts a request against key if the rule leaves room for it
+	Allow(ctx context.Context, key string, rule Rule, now time.Time) (Result, error)
+}
+
+type window struct {
+	hits   []time.Time
+	window time.Duration
+}
+
+// Memory keeps windows in process. Limits are per instance, so it is meant
+// as a fallback and for tests.
+type Memory struct {
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastSweep time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{windows: make(map[string]*window)}
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, rule Rule, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+
+	w, ok := m.windows[key]
+	if !ok {
+		w = &window{}
+		m.windows[key] = w
+	}
+	w.window = rule.Window
+	start := now.Add(-rule.Window)
+	i := 0
+	for i < len(w.hits) && !w.hits[i].After(start) {
+		i++
+	}
+	w.hits = w.hits[i:]
+
+	res := Result{Limit: rule.Limit}
+	if len(w.hits) < rule.Limit {
+		w.hits = append(w.hits, now)
+		res.Allowed = true
+	}
+	res.Remaining = rule.Limit - len(w.hits)
+	res.Reset = w.hits[0].Add(rule.Window).Sub(now)
+	return res, nil
+}
+
+// Drops idle windows at most once a minute so the map does not grow with
+// every client ever seen
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for key, w := range m.windows {
+		if len(w.hits) == 0 || !w.hits[len(w.hits)-1].Add(w.window).After(now) {
+			delete(m.windows, key)
+		}
+	}
+}
+
+// Fallback uses Primary and switches to Secondary for any request Primary
+// fails to answer
+type Fallback struct {
+	Primary   Limiter
+	Secondary Limiter
+	// OnError, if set, is told about each Primary failure
+	OnError func(error)
+}
+
+func (f *Fallback) Allow(ctx context.Context, key string, rule Rule, now time.Time) (Result, error) {
+	res, err := f.Primary.Allow(ctx, key, rule, now)
+	if err == nil {
+		return res, nil
+	}
+	if f.OnError != nil {
+		f.OnError(err)
+	}
+	return f.Secondary.Allow(ctx, key, rule, now)
+}
@@ -0,0 +1,101 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/ratelimit"
+)
+
+func TestParseRule(t *testing.T) {
+	rule, err := ratelimit.ParseRule("10/1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Limit != 10 || rule.Window != time.Minute {
+		t.Errorf("got %+v", rule)
+	}
+	for _, bad := range []string{"", "10", "0/1m", "x/1m", "10/soon", "10/-1s"} {
+		if _, err := ratelimit.ParseRule(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestMemorySlidingWindow(t *testing.T) {
+	m := ratelimit.NewMemory()
+	rule := ratelimit.Rule{Limit: 3, Window: time.Minute}
+	ctx := context.Background()
+	start := time.Unix(1_700_000_000, 0)
+
+	for i := range 3 {
+		res, _ := m.Allow(ctx, "ip:1", rule, start.Add(time.Duration(i)*10*time.Second))
+		if !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("request %d: got %+v", i, res)
+		}
+	}
+	res, _ := m.Allow(ctx, "ip:1", rule, start.Add(30*time.Second))
+	if res.Allowed || res.Remaining != 0 {
+		t.Fatalf("fourth request: got %+v", res)
+	}
+	if res.Reset != 30*time.Second {
+		t.Errorf("reset: got %v, want 30s", res.Reset)
+	}
+	if res, _ := m.Allow(ctx, "ip:2", rule, start.Add(30*time.Second)); !res.Allowed {
+		t.Error("keys must be counted separately")
+	}
+	// The first request has left the window, freeing exactly one slot
+	if res, _ := m.Allow(ctx, "ip:1", rule, start.Add(61*time.Second)); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("after the window slid: got %+v", res)
+	}
+	if res, _ := m.Allow(ctx, "ip:1", rule, start.Add(62*time.Second)); res.Allowed {
+		t.Error("only one slot should have been freed")
+	}
+}
+
+type failing struct{}
+
+func (failing) Allow(context.Context, string, ratelimit.Rule, time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestFallback(t *testing.T) {
+	var reported error
+	f := &ratelimit.Fallback{
+		Primary:   failing{},
+		Secondary: ratelimit.NewMemory(),
+		OnError:   func(err error) { reported = err },
+	}
+	res, err := f.Allow(context.Background(), "k", ratelimit.Rule{Limit: 1, Window: time.Second}, time.Now())
+	if err != nil || !res.Allowed {
+		t.Fatalf("got %+v, %v", res, err)
+	}
+	if reported == nil {
+		t.Error("primary failure was not reported")
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	h := http.Header{}
+	ratelimit.Result{Allowed: false, Limit: 5, Remaining: 0, Reset: 1500 * time.Millisecond}.SetHeaders(h)
+	want := map[string]string{
+		"RateLimit-Limit":     "5",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "2",
+		"Retry-After":         "2",
+	}
+	for k, v := range want {
+		if got := h.Get(k); got != v {
+			t.Errorf("%s: got %q, want %q", k, got, v)
+		}
+	}
+
+	h = http.Header{}
+	ratelimit.Result{Allowed: true, Limit: 5, Remaining: 4, Reset: time.Minute}.SetHeaders(h)
+	if h.Get("Retry-After") != "" {
+		t.Error("Retry-After set on an allowed request")
+	}
+}
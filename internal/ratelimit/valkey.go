@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// Sliding-window log: every allowed request is a member of a sorted set
+// scored by its time in milliseconds. Returns {allowed, remaining, reset_ms}.
+var slidingWindow = valkey.NewLuaScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+  redis.call('ZADD', KEYS[1], now, ARGV[4])
+  redis.call('PEXPIRE', KEYS[1], window)
+  count = count + 1
+  allowed = 1
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return {allowed, limit - count, tonumber(oldest[2]) + window - now}
+`)
+
+const keyPrefix = "ratelimit:"
+
+type Valkey struct {
+	cache valkey.Client
+	seq   atomic.Uint64
+}
+
+func NewValkey(cache valkey.Client) *Valkey {
+	return &Valkey{cache: cache}
+}
+
+func (v *Valkey) Allow(ctx context.Context, key string, rule Rule, now time.Time) (Result, error) {
+	// Members must be unique or two requests in the same instant count once
+	member := strconv.FormatInt(now.UnixNano(), 36) + "-" + strconv.FormatUint(v.seq.Add(1), 36)
+	vals, err := slidingWindow.Exec(ctx, v.cache, []string{keyPrefix + key}, []string{
+		strconv.FormatInt(now.UnixMilli(), 10),
+		strconv.FormatInt(rule.Window.Milliseconds(), 10),
+		strconv.Itoa(rule.Limit),
+		member,
+	}).AsIntSlice()
+	if err != nil {
+		return Result{}, fmt.Errorf("checking rate limit: %w", err)
+	}
+	if len(vals) != 3 {
+		return Result{}, fmt.Errorf("checking rate limit: unexpected reply %v", vals)
+	}
+	return Result{
+		Allowed:   vals[0] == 1,
+		Limit:     rule.Limit,
+		Remaining: int(vals[1]),
+		Reset:     time.Duration(vals[2]) * time.Millisecond,
+	}, nil
+}
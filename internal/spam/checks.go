@@ -0,0 +1,137 @@
+package spam
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Duplicate flags chirps that repeat one of the author's recent chirps
+// word for word or nearly so
+type Duplicate struct {
+	// Fingerprints at most this many bits apart count as near-duplicates
+	MaxDistance int
+	// Chirps shorter than this many words are too generic to compare
+	MinWords int
+	Window   time.Duration
+}
+
+func (d Duplicate) Check(in Input) []Signal {
+	if len(words(in.Body)) < d.MinWords {
+		return nil
+	}
+	hash := Simhash(in.Body)
+	best := -1
+	for _, p := range in.Recent {
+		if in.Now.Sub(p.CreatedAt) > d.Window || len(words(p.Body)) < d.MinWords {
+			continue
+		}
+		dist := Distance(hash, Simhash(p.Body))
+		if best == -1 || dist < best {
+			best = dist
+		}
+	}
+	switch {
+	case best == 0:
+		return []Signal{{Check: "duplicate", Score: 1, Reason: "duplicates a recent chirp by the same author"}}
+	case best > 0 && best <= d.MaxDistance:
+		return []Signal{{Check: "duplicate", Score: 0.7, Reason: "nearly duplicates a recent chirp by the same author"}}
+	}
+	return nil
+}
+
+// LinkDensity flags chirps that are mostly links
+type LinkDensity struct {
+	MaxLinks int
+	// Largest share of the body, by length, that may be links
+	MaxRatio float64
+}
+
+func (l LinkDensity) Check(in Input) []Signal {
+	links := Links(in.Body)
+	if len(links) == 0 {
+		return nil
+	}
+	var out []Signal
+	if len(links) > l.MaxLinks {
+		out = append(out, Signal{Check: "link_density", Score: 0.6, Reason: fmt.Sprintf("contains %d links", len(links))})
+	}
+	n := 0
+	for _, link := range links {
+		n += len(link)
+	}
+	if ratio := float64(n) / float64(len(in.Body)); ratio > l.MaxRatio {
+		out = append(out, Signal{Check: "link_density", Score: 0.4, Reason: fmt.Sprintf("%.0f%% of the chirp is links", ratio*100)})
+	}
+	return out
+}
+
+// Velocity flags new accounts that post in bursts
+type Velocity struct {
+	NewAccountAge time.Duration
+	MaxPosts      int
+	Window        time.Duration
+}
+
+func (v Velocity) Check(in Input) []Signal {
+	if in.Now.Sub(in.AccountCreated) > v.NewAccountAge {
+		return nil
+	}
+	n := 0
+	for _, p := range in.Recent {
+		if in.Now.Sub(p.CreatedAt) <= v.Window {
+			n++
+		}
+	}
+	if n < v.MaxPosts {
+		return nil
+	}
+	return []Signal{{
+		Check:  "velocity",
+		Score:  0.6,
+		Reason: fmt.Sprintf("new account posted %d chirps in %s", n+1, v.Window),
+	}}
+}
+
+// DomainBlocklist flags links to blocked domains and their subdomains
+type DomainBlocklist struct {
+	Domains map[string]bool
+}
+
+// NewDomainBlocklist builds a blocklist from domain names, ignoring case and
+// blank entries
+func NewDomainBlocklist(domains []string) DomainBlocklist {
+	b := DomainBlocklist{Domains: make(map[string]bool)}
+	for _, d := range domains {
+		if d = strings.TrimSpace(strings.ToLower(d)); d != "" {
+			b.Domains[strings.TrimPrefix(d, ".")] = true
+		}
+	}
+	return b
+}
+
+func (b DomainBlocklist) Check(in Input) []Signal {
+	var out []Signal
+	seen := make(map[string]bool)
+	for _, link := range Links(in.Body) {
+		u, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+		for d := host; d != ""; {
+			if b.Domains[d] && !seen[d] {
+				seen[d] = true
+				out = append(out, Signal{Check: "blocklist", Score: 1, Reason: "links to blocked domain " + d})
+				break
+			}
+			_, rest, ok := strings.Cut(d, ".")
+			if !ok {
+				break
+			}
+			d = rest
+		}
+	}
+	return out
+}
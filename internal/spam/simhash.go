@@ -0,0 +1,48 @@
+package spam
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+)
+
+// Simhash fingerprints text so that similar texts get fingerprints a small
+// Hamming distance apart. Features are overlapping word 3-grams, or the
+// words themselves for very short texts.
+func Simhash(text string) uint64 {
+	ws := words(text)
+	var features []string
+	if len(ws) < 3 {
+		features = ws
+	} else {
+		for i := 0; i+3 <= len(ws); i++ {
+			features = append(features, strings.Join(ws[i:i+3], " "))
+		}
+	}
+
+	var weights [64]int
+	for _, f := range features {
+		h := fnv.New64a()
+		h.Write([]byte(f))
+		sum := h.Sum64()
+		for bit := range 64 {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	var hash uint64
+	for bit, w := range weights {
+		if w > 0 {
+			hash |= 1 << bit
+		}
+	}
+	return hash
+}
+
+// Distance is the number of bits in which two fingerprints differ
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
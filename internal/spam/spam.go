@@ -0,0 +1,82 @@
+// Package spam scores a new chirp before it is stored. Each Check looks at
+// one kind of abuse and reports signals; the Pipeline adds their scores and
+// holds the chirp for review once the total reaches its threshold.
+package spam
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Post is one of the author's recent chirps
+type Post struct {
+	Body      string
+	CreatedAt time.Time
+}
+
+type Input struct {
+	Body           string
+	AccountCreated time.Time
+	// The author's chirps from roughly the last day, newest first
+	Recent []Post
+	Now    time.Time
+}
+
+type Signal struct {
+	Check  string  `json:"check"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+type Check interface {
+	Check(in Input) []Signal
+}
+
+type Verdict struct {
+	Score   float64
+	Signals []Signal
+	Held    bool
+}
+
+// Reasons lists the signals as human-readable text for moderators
+func (v Verdict) Reasons() []string {
+	reasons := make([]string, len(v.Signals))
+	for i, s := range v.Signals {
+		reasons[i] = s.Reason
+	}
+	return reasons
+}
+
+type Pipeline struct {
+	Checks    []Check
+	Threshold float64
+}
+
+func (p *Pipeline) Evaluate(in Input) Verdict {
+	var v Verdict
+	for _, c := range p.Checks {
+		for _, s := range c.Check(in) {
+			v.Score += s.Score
+			v.Signals = append(v.Signals, s)
+		}
+	}
+	v.Held = p.Threshold > 0 && v.Score >= p.Threshold
+	return v
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s]+`)
+
+// Links returns the URLs in text
+func Links(text string) []string {
+	return linkPattern.FindAllString(text, -1)
+}
+
+// Lower-cased runs of letters and digits, so that padding a chirp with
+// punctuation or emoji does not make it look new
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
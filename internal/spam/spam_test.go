@@ -0,0 +1,97 @@
+package spam_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/spam"
+)
+
+var now = time.Unix(1_700_000_000, 0)
+
+func TestSimhashDistance(t *testing.T) {
+	a := spam.Simhash("win a free phone today by clicking the link in my bio right now")
+	b := spam.Simhash("win a free phone today by clicking the link in my bio right now!!")
+	c := spam.Simhash("the hearing on the new transit budget was postponed until next week")
+
+	if d := spam.Distance(a, a); d != 0 {
+		t.Errorf("identical text: distance %d", d)
+	}
+	if near, far := spam.Distance(a, b), spam.Distance(a, c); near >= far {
+		t.Errorf("near-duplicate (%d) should be closer than unrelated text (%d)", near, far)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	check := spam.Duplicate{MaxDistance: 3, MinWords: 4, Window: 24 * time.Hour}
+	body := "check out my amazing new crypto project"
+	recent := []spam.Post{{Body: body, CreatedAt: now.Add(-time.Hour)}}
+
+	if got := check.Check(spam.Input{Body: body, Recent: recent, Now: now}); len(got) != 1 || got[0].Score != 1 {
+		t.Errorf("exact duplicate: got %+v", got)
+	}
+	old := []spam.Post{{Body: body, CreatedAt: now.Add(-48 * time.Hour)}}
+	if got := check.Check(spam.Input{Body: body, Recent: old, Now: now}); len(got) != 0 {
+		t.Errorf("duplicate outside the window: got %+v", got)
+	}
+	short := []spam.Post{{Body: "good morning", CreatedAt: now.Add(-time.Hour)}}
+	if got := check.Check(spam.Input{Body: "good morning", Recent: short, Now: now}); len(got) != 0 {
+		t.Errorf("short chirps should not be compared: got %+v", got)
+	}
+}
+
+func TestLinkDensity(t *testing.T) {
+	check := spam.LinkDensity{MaxLinks: 2, MaxRatio: 0.6}
+	if got := check.Check(spam.Input{Body: "read this https://example.com/a it is good"}); len(got) != 0 {
+		t.Errorf("one link in prose: got %+v", got)
+	}
+	body := "https://a.example/x https://b.example/y https://c.example/z"
+	if got := check.Check(spam.Input{Body: body}); len(got) != 2 {
+		t.Errorf("three bare links: got %+v", got)
+	}
+}
+
+func TestVelocity(t *testing.T) {
+	check := spam.Velocity{NewAccountAge: 24 * time.Hour, MaxPosts: 3, Window: time.Hour}
+	var recent []spam.Post
+	for i := range 3 {
+		recent = append(recent, spam.Post{Body: "x", CreatedAt: now.Add(-time.Duration(i) * time.Minute)})
+	}
+	in := spam.Input{AccountCreated: now.Add(-time.Hour), Recent: recent, Now: now}
+	if got := check.Check(in); len(got) != 1 {
+		t.Errorf("new account bursting: got %+v", got)
+	}
+	in.AccountCreated = now.Add(-30 * 24 * time.Hour)
+	if got := check.Check(in); len(got) != 0 {
+		t.Errorf("established account: got %+v", got)
+	}
+}
+
+func TestDomainBlocklist(t *testing.T) {
+	check := spam.NewDomainBlocklist([]string{"Spam.example", " ", ".scam.test"})
+	got := check.Check(spam.Input{Body: "go to https://www.spam.example/win and http://scam.test and https://fine.example"})
+	if len(got) != 2 {
+		t.Fatalf("got %+v", got)
+	}
+	if got := check.Check(spam.Input{Body: "https://notspam.example/"}); len(got) != 0 {
+		t.Errorf("suffix without a dot boundary matched: %+v", got)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	p := spam.Pipeline{
+		Checks: []spam.Check{
+			spam.NewDomainBlocklist([]string{"spam.example"}),
+			spam.LinkDensity{MaxLinks: 2, MaxRatio: 0.6},
+		},
+		Threshold: 1,
+	}
+	v := p.Evaluate(spam.Input{Body: "hello there, how is everyone doing", Now: now})
+	if v.Held || v.Score != 0 {
+		t.Errorf("clean chirp: got %+v", v)
+	}
+	v = p.Evaluate(spam.Input{Body: "https://spam.example/win", Now: now})
+	if !v.Held || len(v.Reasons()) != 2 {
+		t.Errorf("blocked link: got %+v", v)
+	}
+}
@@ -0,0 +1,141 @@
+// Package trending ranks hashtags by recent use. Every use is counted in an
+// hourly Valkey sorted set; a window is ranked by summing the buckets it
+// covers, each weighted by an exponential decay on its age.
+package trending
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	keyPrefix = "trending:"
+	// Members read per bucket; the long tail cannot trend anyway.
+	bucketDepth = 200
+	bucketTTL   = 8 * 24 * time.Hour
+)
+
+type Window struct {
+	Span     time.Duration
+	HalfLife time.Duration
+}
+
+var Windows = map[string]Window{
+	"1h":  {Span: time.Hour, HalfLife: 15 * time.Minute},
+	"24h": {Span: 24 * time.Hour, HalfLife: 4 * time.Hour},
+	"7d":  {Span: 7 * 24 * time.Hour, HalfLife: 24 * time.Hour},
+}
+
+type Topic struct {
+	Tag   string  `json:"tag"`
+	Score float64 `json:"score"`
+}
+
+type Bucket struct {
+	Start  time.Time
+	Counts map[string]float64
+}
+
+type Tracker struct {
+	cache valkey.Client
+}
+
+func New(cache valkey.Client) *Tracker {
+	return &Tracker{cache: cache}
+}
+
+func bucketStart(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Hour)
+}
+
+func bucketKey(start time.Time) string {
+	return keyPrefix + strconv.FormatInt(start.Unix(), 10)
+}
+
+func (t *Tracker) Record(ctx context.Context, tags []string, at time.Time) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	key := bucketKey(bucketStart(at))
+	cmds := make(valkey.Commands, 0, len(tags)+1)
+	for _, tag := range tags {
+		cmds = append(cmds, t.cache.B().Zincrby().Key(key).Increment(1).Member(tag).Build())
+	}
+	cmds = append(cmds, t.cache.B().Expire().Key(key).Seconds(int64(bucketTTL.Seconds())).Build())
+	for _, resp := range t.cache.DoMulti(ctx, cmds...) {
+		if err := resp.Error(); err != nil {
+			return fmt.Errorf("recording trending tags: %w", err)
+		}
+	}
+	return nil
+}
+
+func (t *Tracker) Top(ctx context.Context, w Window, n int, now time.Time) ([]Topic, error) {
+	from := now.Add(-w.Span)
+	var starts []time.Time
+	for s := bucketStart(now); s.Add(time.Hour).After(from); s = s.Add(-time.Hour) {
+		starts = append(starts, s)
+	}
+
+	cmds := make(valkey.Commands, len(starts))
+	for i, s := range starts {
+		cmds[i] = t.cache.B().Zrange().Key(bucketKey(s)).Min("0").Max(strconv.Itoa(bucketDepth - 1)).Rev().Withscores().Build()
+	}
+	buckets := make([]Bucket, 0, len(starts))
+	for i, resp := range t.cache.DoMulti(ctx, cmds...) {
+		scores, err := resp.AsZScores()
+		if err != nil {
+			if valkey.IsValkeyNil(err) {
+				continue
+			}
+			return nil, fmt.Errorf("reading trending bucket: %w", err)
+		}
+		b := Bucket{Start: starts[i], Counts: make(map[string]float64, len(scores))}
+		for _, s := range scores {
+			b.Counts[s.Member] = s.Score
+		}
+		buckets = append(buckets, b)
+	}
+	return Rank(buckets, w, n, now), nil
+}
+
+// Rank combines bucket counts into decayed scores and returns the top n tags.
+// A bucket's weight is 0.5^(age/halfLife), measured from the bucket midpoint.
+func Rank(buckets []Bucket, w Window, n int, now time.Time) []Topic {
+	scores := make(map[string]float64)
+	for _, b := range buckets {
+		mid := b.Start.Add(30 * time.Minute)
+		if mid.After(now) {
+			mid = now
+		}
+		age := now.Sub(mid)
+		if age > w.Span {
+			continue
+		}
+		weight := math.Pow(0.5, age.Seconds()/w.HalfLife.Seconds())
+		for tag, count := range b.Counts {
+			scores[tag] += count * weight
+		}
+	}
+
+	topics := make([]Topic, 0, len(scores))
+	for tag, score := range scores {
+		topics = append(topics, Topic{Tag: tag, Score: score})
+	}
+	sort.Slice(topics, func(i, j int) bool {
+		if topics[i].Score != topics[j].Score {
+			return topics[i].Score > topics[j].Score
+		}
+		return topics[i].Tag < topics[j].Tag
+	})
+	if len(topics) > n {
+		topics = topics[:n]
+	}
+	return topics
+}
@@ -0,0 +1,47 @@
+package trending_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/trending"
+)
+
+func TestRankPrefersRecentUse(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+	w := trending.Windows["24h"]
+	buckets := []trending.Bucket{
+		{Start: now.Truncate(time.Hour), Counts: map[string]float64{"fresh": 10}},
+		{Start: now.Add(-10 * time.Hour).Truncate(time.Hour), Counts: map[string]float64{"stale": 15}},
+	}
+
+	topics := trending.Rank(buckets, w, 10, now)
+	if len(topics) != 2 {
+		t.Fatalf("expected 2 topics, got %v", topics)
+	}
+	if topics[0].Tag != "fresh" {
+		t.Errorf("expected recent tag to outrank older, bigger one: %v", topics)
+	}
+}
+
+func TestRankDropsBucketsOutsideWindow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+	buckets := []trending.Bucket{
+		{Start: now.Add(-3 * time.Hour).Truncate(time.Hour), Counts: map[string]float64{"old": 100}},
+		{Start: now.Truncate(time.Hour), Counts: map[string]float64{"new": 1}},
+	}
+
+	topics := trending.Rank(buckets, trending.Windows["1h"], 10, now)
+	if len(topics) != 1 || topics[0].Tag != "new" {
+		t.Errorf("expected only the in-window tag, got %v", topics)
+	}
+}
+
+func TestRankLimit(t *testing.T) {
+	now := time.Now()
+	buckets := []trending.Bucket{{Start: now.Truncate(time.Hour), Counts: map[string]float64{"a": 3, "b": 2, "c": 1}}}
+	topics := trending.Rank(buckets, trending.Windows["1h"], 2, now)
+	if len(topics) != 2 || topics[0].Tag != "a" || topics[1].Tag != "b" {
+		t.Errorf("unexpected ranking %v", topics)
+	}
+}
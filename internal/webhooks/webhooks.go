@@ -0,0 +1,105 @@
+// Package webhooks holds the delivery-independent parts of outgoing webhooks:
+// secrets, request signing and the retry schedule.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "Chirpy-Signature"
+	EventHeader     = "Chirpy-Event"
+	DeliveryHeader  = "Chirpy-Delivery"
+
+	secretPrefix = "whsec_"
+)
+
+var (
+	ErrBadSignatureHeader = errors.New("malformed signature header")
+	ErrSignatureMismatch  = errors.New("signature does not match payload")
+	ErrSignatureExpired   = errors.New("signature timestamp outside tolerance")
+)
+
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("secret generation failed: %w", err)
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
+
+func mac(secret string, ts int64, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%d.", ts)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Sign produces the SignatureHeader value "t=<unix>,v1=<hex hmac>". The MAC
+// covers "<unix>.<body>" so a captured request cannot be replayed later with
+// a fresh timestamp.
+func Sign(secret string, at time.Time, body []byte) string {
+	ts := at.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", ts, mac(secret, ts, body))
+}
+
+// Verify checks a SignatureHeader value the way a receiver should
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts int64
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrBadSignatureHeader
+		}
+		switch k {
+		case "t":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return ErrBadSignatureHeader
+			}
+			ts = n
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	if ts == 0 || len(sigs) == 0 {
+		return ErrBadSignatureHeader
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return ErrSignatureExpired
+	}
+	want := mac(secret, ts, body)
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(want)) {
+			return nil
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+const (
+	MaxAttempts   = 8
+	backoffBase   = 30 * time.Second
+	backoffCap    = 6 * time.Hour
+	DisableAfter  = 20
+	backoffJitter = 0.2
+)
+
+// Backoff returns the wait before retry number attempt (1-based): 30s, 1m,
+// 2m, ... capped at 6h, spread by ±20% using jitter in [0, 1).
+func Backoff(attempt int, jitter float64) time.Duration {
+	d := float64(backoffBase) * math.Pow(2, float64(attempt-1))
+	d = math.Min(d, float64(backoffCap))
+	d *= 1 + backoffJitter*(2*jitter-1)
+	return time.Duration(d)
+}
@@ -0,0 +1,55 @@
+package webhooks_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/webhooks"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"type":"chirp.created"}`)
+	header := webhooks.Sign(secret, now, body)
+
+	if !strings.HasPrefix(header, "t=1700000000,v1=") {
+		t.Errorf("unexpected header format %q", header)
+	}
+	if err := webhooks.Verify(secret, header, body, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := webhooks.Verify(secret, header, []byte(`{"type":"tampered"}`), now, 5*time.Minute); !errors.Is(err, webhooks.ErrSignatureMismatch) {
+		t.Errorf("tampered body: got %v", err)
+	}
+	if err := webhooks.Verify("whsec_other", header, body, now, 5*time.Minute); !errors.Is(err, webhooks.ErrSignatureMismatch) {
+		t.Errorf("wrong secret: got %v", err)
+	}
+	if err := webhooks.Verify(secret, header, body, now.Add(time.Hour), 5*time.Minute); !errors.Is(err, webhooks.ErrSignatureExpired) {
+		t.Errorf("stale timestamp: got %v", err)
+	}
+	if err := webhooks.Verify(secret, "garbage", body, now, 5*time.Minute); !errors.Is(err, webhooks.ErrBadSignatureHeader) {
+		t.Errorf("garbage header: got %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if got := webhooks.Backoff(1, 0.5); got != 30*time.Second {
+		t.Errorf("first retry = %v, want 30s", got)
+	}
+	if got := webhooks.Backoff(3, 0.5); got != 2*time.Minute {
+		t.Errorf("third retry = %v, want 2m", got)
+	}
+	if got := webhooks.Backoff(30, 0.5); got != 6*time.Hour {
+		t.Errorf("late retry = %v, want the 6h cap", got)
+	}
+	lo, hi := webhooks.Backoff(2, 0), webhooks.Backoff(2, 0.999)
+	if lo >= time.Minute || hi <= time.Minute {
+		t.Errorf("jitter should spread around 1m, got %v..%v", lo, hi)
+	}
+}
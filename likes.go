@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type chirpAction struct {
+	do            func(ctx context.Context, userID, chirpID uuid.UUID) (int64, error)
+	changedStatus int
+	// Notification sent to the chirp's author when the action takes effect,
+	// or withdrawn from them when retract is set. Private actions have none.
+	notification string
+	retract      bool
+	// Also allowed on chirps the user can no longer see, such as removing a
+	// bookmark of a chirp since hidden. The response then has no body.
+	ignoreVisibility bool
+}
+
+func (cfg *apiConfig) LikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.applyChirpAction(w, r, chirpAction{
+		do: func(ctx context.Context, userID, chirpID uuid.UUID) (int64, error) {
+			return cfg.dbQueries.LikeChirp(ctx, database.LikeChirpParams{UserID: userID, ChirpID: chirpID})
+		},
+		changedStatus: http.StatusCreated,
+		notification:  notificationLike,
+	})
+}
+
+func (cfg *apiConfig) UnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.applyChirpAction(w, r, chirpAction{
+		do: func(ctx context.Context, userID, chirpID uuid.UUID) (int64, error) {
+			return cfg.dbQueries.UnlikeChirp(ctx, database.UnlikeChirpParams{UserID: userID, ChirpID: chirpID})
+		},
+		changedStatus: http.StatusOK,
+		notification:  notificationLike,
+		retract:       true,
+	})
+}
+
+func (cfg *apiConfig) RechirpChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.applyChirpAction(w, r, chirpAction{
+		do: func(ctx context.Context, userID, chirpID uuid.UUID) (int64, error) {
+			return cfg.dbQueries.Rechirp(ctx, database.RechirpParams{UserID: userID, ChirpID: chirpID})
+		},
+		changedStatus: http.StatusCreated,
+		notification:  notificationRechirp,
+	})
+}
+
+func (cfg *apiConfig) UndoRechirp(w http.ResponseWriter, r *http.Request) {
+	cfg.applyChirpAction(w, r, chirpAction{
+		do: func(ctx context.Context, userID, chirpID uuid.UUID) (int64, error) {
+			return cfg.dbQueries.UndoRechirp(ctx, database.UndoRechirpParams{UserID: userID, ChirpID: chirpID})
+		},
+		changedStatus: http.StatusOK,
+		notification:  notificationRechirp,
+		retract:       true,
+	})
+}
+
+// Runs a like/rechirp style toggle for the authenticated user and responds
+// with the chirp's fresh counters. Repeating an action is a no-op (200).
+func (cfg *apiConfig) applyChirpAction(w http.ResponseWriter, r *http.Request, action chirpAction) {
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+
+	target, err := cfg.dbQueries.GetChirpByID(ctx, chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	visible, err := cfg.canViewChirp(ctx, uuid.NullUUID{UUID: userID, Valid: true}, target)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !visible && !action.ignoreVisibility {
+		api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	// Users the author has blocked may not interact with the chirp where the
+	// author would see it, though they can still undo earlier likes and
+	// rechirps
+	if action.notification != "" && !action.retract {
+		blocked, err := hasBlocked(ctx, cfg.dbQueries, target.UserID, userID)
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if blocked {
+			api.RespondWithError(w, "You cannot interact with this chirp", http.StatusForbidden)
+			return
+		}
+	}
+
+	changed, err := action.do(ctx, userID, chirpID)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		// Only confirm that the chirp exists to a user who had acted on it
+		if changed == 0 {
+			api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if changed > 0 && action.notification != "" {
+		subject := uuid.NullUUID{UUID: chirpID, Valid: true}
+		if action.retract {
+			cfg.retractNotification(target.UserID, userID, action.notification, subject)
+		} else {
+			cfg.recordNotification(target.UserID, userID, action.notification, subject)
+		}
+	}
+
+	chirp, err := cfg.dbQueries.GetChirpByID(ctx, chirpID)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp, err := cfg.buildChirpResponse(ctx, uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if changed > 0 {
+		status = action.changedStatus
+	}
+	api.RespondWithJSON(w, resp, status)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/Lewvy/chirpy/internal/entities"
+	"github.com/Lewvy/chirpy/internal/linkpreview"
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	linkPreviewWorkers = 4
+	// Pages are fetched again once their preview is this old
+	linkPreviewRefresh  = 7 * 24 * time.Hour
+	linkPreviewCacheTTL = 24 * time.Hour
+	linkPreviewKey      = "linkpreview:"
+)
+
+// URLs waiting to be fetched. Posting never blocks on it; when it is full
+// the link simply goes without a preview.
+var linkPreviewQueue = make(chan string, 100)
+
+// Only the first link in a chirp gets a preview card
+func firstLink(body string) string {
+	if links := entities.Links(body); len(links) > 0 {
+		return links[0].Text
+	}
+	return ""
+}
+
+func queueLinkPreview(body string) {
+	u := firstLink(body)
+	if u == "" {
+		return
+	}
+	select {
+	case linkPreviewQueue <- u:
+	default:
+		log.Println("Link preview queue full, skipping: ", u)
+	}
+}
+
+// Fetches queued links; several run side by side so one slow site does not
+// hold up the rest
+func (cfg *apiConfig) LinkPreviewWorker() {
+	fetcher := linkpreview.New()
+	for u := range linkPreviewQueue {
+		cfg.refreshLinkPreview(fetcher, u)
+	}
+}
+
+func (cfg *apiConfig) refreshLinkPreview(fetcher *linkpreview.Fetcher, u string) {
+	ctx := context.Background()
+	fetchedAt, err := cfg.dbQueries.GetLinkPreviewFetchedAt(ctx, u)
+	if err == nil && time.Since(fetchedAt) < linkPreviewRefresh {
+		return
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Println("Error looking up link preview: ", err)
+		return
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, linkpreview.DefaultTimeout)
+	p, err := fetcher.Fetch(fetchCtx, u)
+	cancel()
+	params := database.UpsertLinkPreviewParams{
+		Url:         u,
+		Status:      "ok",
+		Title:       p.Title,
+		Description: p.Description,
+		ImageUrl:    p.ImageURL,
+		SiteName:    p.SiteName,
+	}
+	if err != nil {
+		params.Status = "failed"
+		params.Error = sql.NullString{String: err.Error(), Valid: true}
+	}
+	if err := cfg.dbQueries.UpsertLinkPreview(ctx, params); err != nil {
+		log.Println("Error saving link preview: ", err)
+		return
+	}
+	if params.Status == "ok" {
+		cfg.cacheLinkPreview(ctx, p)
+	}
+}
+
+func linkPreviewCacheKey(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return linkPreviewKey + hex.EncodeToString(sum[:])
+}
+
+func (cfg *apiConfig) cacheLinkPreview(ctx context.Context, p linkpreview.Preview) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+	cmd := cfg.cache.B().Set().Key(linkPreviewCacheKey(p.URL)).Value(string(data)).
+		Ex(linkPreviewCacheTTL).Build()
+	if err := cfg.cache.Do(ctx, cmd).Error(); err != nil {
+		log.Println("Error caching link preview: ", err)
+	}
+}
+
+// Looks previews up in Valkey first and falls back to the database for the
+// rest, caching what it finds there. Links with no successful preview are
+// absent from the result.
+func (cfg *apiConfig) lookupLinkPreviews(ctx context.Context, urls []string) (map[string]linkpreview.Preview, error) {
+	found := make(map[string]linkpreview.Preview, len(urls))
+	cmds := make(valkey.Commands, len(urls))
+	for i, u := range urls {
+		cmds[i] = cfg.cache.B().Get().Key(linkPreviewCacheKey(u)).Build()
+	}
+	var missing []string
+	for i, resp := range cfg.cache.DoMulti(ctx, cmds...) {
+		var p linkpreview.Preview
+		data, err := resp.AsBytes()
+		if err != nil || json.Unmarshal(data, &p) != nil {
+			if err != nil && !valkey.IsValkeyNil(err) {
+				log.Println("Error reading cached link preview: ", err)
+			}
+			missing = append(missing, urls[i])
+			continue
+		}
+		found[urls[i]] = p
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	rows, err := cfg.dbQueries.GetLinkPreviews(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		p := linkpreview.Preview{
+			URL:         row.Url,
+			Title:       row.Title,
+			Description: row.Description,
+			ImageURL:    row.ImageUrl,
+			SiteName:    row.SiteName,
+		}
+		found[row.Url] = p
+		cfg.cacheLinkPreview(ctx, p)
+	}
+	return found, nil
+}
+
+func (cfg *apiConfig) attachLinkPreviews(ctx context.Context, resp []chirpResponse) error {
+	links := make([]string, len(resp))
+	seen := make(map[string]struct{}, len(resp))
+	var urls []string
+	for i := range resp {
+		links[i] = firstLink(resp[i].Body)
+		if _, dup := seen[links[i]]; links[i] == "" || dup {
+			continue
+		}
+		seen[links[i]] = struct{}{}
+		urls = append(urls, links[i])
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+	previews, err := cfg.lookupLinkPreviews(ctx, urls)
+	if err != nil {
+		return err
+	}
+	for i, u := range links {
+		if p, ok := previews[u]; ok {
+			resp[i].LinkPreview = &p
+		}
+	}
+	return nil
+}
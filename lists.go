@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxListName        = 50
+	maxListDescription = 160
+	maxListMembers     = 500
+)
+
+// Public lists can be read by anyone, signed in or not. Private ones exist
+// only for their owner.
+type listResponse struct {
+	ID          uuid.UUID `json:"id"`
+	OwnerID     uuid.UUID `json:"owner_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Private     bool      `json:"private"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	MemberCount int64     `json:"member_count"`
+}
+
+func toListResponse(l database.List, count int64) listResponse {
+	return listResponse{
+		ID:          l.ID,
+		OwnerID:     l.UserID,
+		Name:        l.Name,
+		Description: l.Description,
+		Private:     l.Private,
+		CreatedAt:   l.CreatedAt,
+		UpdatedAt:   l.UpdatedAt,
+		MemberCount: count,
+	}
+}
+
+type listRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Private     *bool   `json:"private"`
+}
+
+// Applies the fields present in a create or update request to l
+func (req listRequest) apply(l *database.List) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxListName {
+			return fmt.Errorf("Name must be 1-%d characters", maxListName)
+		}
+		l.Name = name
+	}
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		if utf8.RuneCountInString(description) > maxListDescription {
+			return fmt.Errorf("Description must be at most %d characters", maxListDescription)
+		}
+		l.Description = description
+	}
+	if req.Private != nil {
+		l.Private = *req.Private
+	}
+	return nil
+}
+
+func (cfg *apiConfig) CreateList(w http.ResponseWriter, r *http.Request) {
+	var reqBody listRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if reqBody.Name == nil {
+		api.RespondWithError(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	var l database.List
+	if err := reqBody.apply(&l); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	l, err := cfg.dbQueries.CreateList(context.Background(), database.CreateListParams{
+		UserID:      userIDFromContext(r.Context()),
+		Name:        l.Name,
+		Description: l.Description,
+		Private:     l.Private,
+	})
+	if isUniqueViolation(err) {
+		api.RespondWithError(w, "You already have a list with that name", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, toListResponse(l, 0), http.StatusCreated)
+}
+
+// The caller's own lists, public and private
+func (cfg *apiConfig) ListMyLists(w http.ResponseWriter, r *http.Request) {
+	rows, err := cfg.dbQueries.GetUserLists(context.Background(), userIDFromContext(r.Context()))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]listResponse, len(rows))
+	for i, row := range rows {
+		resp[i] = toListResponse(row.List, row.MemberCount)
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+// listFor parses the path id and loads the list if viewer may see it, or
+// change it when ownerOnly is set. Anyone else gets the same 404 as a
+// missing list.
+func (cfg *apiConfig) listFor(w http.ResponseWriter, r *http.Request, viewer uuid.NullUUID, ownerOnly bool) (database.GetListRow, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return database.GetListRow{}, false
+	}
+	row, err := cfg.dbQueries.GetList(context.Background(), id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return row, false
+	}
+	owner := viewer.Valid && viewer.UUID == row.List.UserID
+	if err != nil || (!owner && (ownerOnly || row.List.Private)) {
+		api.RespondWithError(w, "List not found", http.StatusNotFound)
+		return row, false
+	}
+	return row, true
+}
+
+func (cfg *apiConfig) GetList(w http.ResponseWriter, r *http.Request) {
+	row, ok := cfg.listFor(w, r, cfg.viewerID(r), false)
+	if !ok {
+		return
+	}
+	api.RespondWithJSON(w, toListResponse(row.List, row.MemberCount), http.StatusOK)
+}
+
+// Updates only the fields present in the body
+func (cfg *apiConfig) UpdateList(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r.Context())
+	row, ok := cfg.listFor(w, r, uuid.NullUUID{UUID: userID, Valid: true}, true)
+	if !ok {
+		return
+	}
+	var reqBody listRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	l := row.List
+	if err := reqBody.apply(&l); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	n, err := cfg.dbQueries.UpdateList(ctx, database.UpdateListParams{
+		ID:          l.ID,
+		UserID:      userID,
+		Name:        l.Name,
+		Description: l.Description,
+		Private:     l.Private,
+	})
+	if isUniqueViolation(err) {
+		api.RespondWithError(w, "You already have a list with that name", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		api.RespondWithError(w, "List not found", http.StatusNotFound)
+		return
+	}
+	cfg.respondWithList(ctx, w, l.ID, http.StatusOK)
+}
+
+func (cfg *apiConfig) respondWithList(ctx context.Context, w http.ResponseWriter, id uuid.UUID, status int) {
+	row, err := cfg.dbQueries.GetList(ctx, id)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, toListResponse(row.List, row.MemberCount), status)
+}
+
+func (cfg *apiConfig) DeleteList(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := cfg.dbQueries.DeleteList(context.Background(), database.DeleteListParams{
+		ID:     id,
+		UserID: userIDFromContext(r.Context()),
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		api.RespondWithError(w, "List not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Members of a list, most recently added first
+func (cfg *apiConfig) GetListMembers(w http.ResponseWriter, r *http.Request) {
+	row, ok := cfg.listFor(w, r, cfg.viewerID(r), false)
+	if !ok {
+		return
+	}
+	members, err := cfg.dbQueries.GetListMembers(context.Background(), row.List.ID)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]relatedUser, len(members))
+	for i, m := range members {
+		resp[i] = relatedUser{UserID: m.UserID, CreatedAt: m.CreatedAt}
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+// Adds an account to one of the caller's lists. Adding it again is a no-op
+// (200). Accounts that block the owner, or that the owner blocks, cannot be
+// added.
+func (cfg *apiConfig) AddListMember(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r.Context())
+	row, ok := cfg.listFor(w, r, uuid.NullUUID{UUID: userID, Valid: true}, true)
+	if !ok {
+		return
+	}
+	memberID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+
+	users, err := cfg.dbQueries.GetUsersByIDs(ctx, []uuid.UUID{memberID})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(users) == 0 {
+		api.RespondWithError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	blocked, err := cfg.dbQueries.IsBlockedEitherWay(ctx, database.IsBlockedEitherWayParams{
+		A: userID,
+		B: memberID,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		api.RespondWithError(w, "You cannot add this user", http.StatusForbidden)
+		return
+	}
+	if row.MemberCount >= maxListMembers {
+		api.RespondWithError(w, fmt.Sprintf("Lists can have at most %d members", maxListMembers), http.StatusConflict)
+		return
+	}
+
+	n, err := cfg.dbQueries.AddListMember(ctx, database.AddListMemberParams{
+		ListID: row.List.ID,
+		UserID: memberID,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if n > 0 {
+		status = http.StatusCreated
+	}
+	cfg.respondWithList(ctx, w, row.List.ID, status)
+}
+
+func (cfg *apiConfig) RemoveListMember(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r.Context())
+	row, ok := cfg.listFor(w, r, uuid.NullUUID{UUID: userID, Valid: true}, true)
+	if !ok {
+		return
+	}
+	memberID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := cfg.dbQueries.RemoveListMember(context.Background(), database.RemoveListMemberParams{
+		ListID: row.List.ID,
+		UserID: memberID,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		api.RespondWithError(w, "User is not in this list", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Chirps and rechirps by a list's members, built like the home timeline.
+// Members who have blocked the viewer are left out.
+func (cfg *apiConfig) GetListTimeline(w http.ResponseWriter, r *http.Request) {
+	viewer := cfg.viewerID(r)
+	row, ok := cfg.listFor(w, r, viewer, false)
+	if !ok {
+		return
+	}
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+
+	authorIDs, err := cfg.dbQueries.GetListMemberIDs(ctx, row.List.ID)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if viewer.Valid && len(authorIDs) > 0 {
+		blockers, err := cfg.dbQueries.GetBlockersAmong(ctx, database.GetBlockersAmongParams{
+			BlockedID: viewer.UUID,
+			UserIds:   authorIDs,
+		})
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		blocked := toSet(blockers)
+		authorIDs = slices.DeleteFunc(authorIDs, func(id uuid.UUID) bool {
+			_, ok := blocked[id]
+			return ok
+		})
+	}
+	cfg.respondWithTimeline(ctx, w, viewer, authorIDs, before, limit)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPrivateListsExistOnlyForTheirOwner(t *testing.T) {
+	ts := newTestServer(t)
+	member, _ := ts.createUser("member")
+	_, ownerToken := ts.createUser("owner")
+	_, otherToken := ts.createUser("other")
+	var list listResponse
+	ts.must(http.StatusCreated, http.MethodPost, "/api/lists", ownerToken,
+		map[string]any{"name": "secret", "private": true}, &list)
+	ts.must(http.StatusCreated, http.MethodPut, "/api/lists/"+list.ID.String()+"/members/"+member.String(), ownerToken, nil, nil)
+
+	base := "/api/lists/" + list.ID.String()
+	for _, path := range []string{base, base + "/members", base + "/timeline"} {
+		ts.must(http.StatusOK, http.MethodGet, path, ownerToken, nil, nil)
+		for who, token := range map[string]string{"other": otherToken, "anonymous": ""} {
+			if status, body := ts.do(http.MethodGet, path, token, nil); status != http.StatusNotFound {
+				t.Errorf("%s GET %s: status %d, want 404: %s", who, path, status, body)
+			}
+		}
+	}
+	if status, body := ts.do(http.MethodPut, base+"/members/"+member.String(), otherToken, nil); status != http.StatusNotFound {
+		t.Errorf("adding to someone else's list: status %d, want 404: %s", status, body)
+	}
+}
+
+func TestBlockedUsersCannotBeListed(t *testing.T) {
+	ts := newTestServer(t)
+	blocker, blockerToken := ts.createUser("blocker")
+	owner, ownerToken := ts.createUser("owner")
+	ts.must(http.StatusOK, http.MethodPost, "/api/users/"+owner.String()+"/block", blockerToken, nil, nil)
+
+	var list listResponse
+	ts.must(http.StatusCreated, http.MethodPost, "/api/lists", ownerToken, map[string]any{"name": "people"}, &list)
+	status, body := ts.do(http.MethodPut, "/api/lists/"+list.ID.String()+"/members/"+blocker.String(), ownerToken, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("listing a user who blocked the owner: status %d, want 403: %s", status, body)
+	}
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/Lewvy/chirpy/internal/media"
+	"github.com/google/uuid"
+)
+
+const (
+	maxChirpMedia = 4
+	maxAltText    = 1000
+
+	// Uploads no chirp, profile or pending scheduled chirp has claimed by then, or whose chirp was
+	// deleted, are removed along with their blobs
+	mediaOrphanAge       = 24 * time.Hour
+	mediaJanitorInterval = time.Hour
+
+	mediaProcessing = "processing"
+	mediaFailed     = "failed"
+
+	mediaWorkers      = 4
+	mediaPollInterval = 10 * time.Second
+	mediaBatchSize    = 20
+	// Long enough to process one large image; a crashed worker's claims are
+	// retried once it lapses
+	mediaLeaseSeconds = 120
+	mediaMaxAttempts  = 3
+
+	// Variant keys never change their content, so they can be cached forever
+	mediaCacheControl = "public, max-age=31536000, immutable"
+	// Media of chirps not everyone may read is revalidated on every use, so
+	// the visibility check runs again before a 304
+	restrictedMediaCacheControl = "private, no-cache"
+)
+
+// Nudges the processing dispatcher when an upload arrives, as webhookQueue
+// does for deliveries
+var mediaQueue = make(chan struct{}, 1)
+
+var errUnknownMedia = errors.New("unknown or already attached media")
+
+type mediaResponse struct {
+	ID          uuid.UUID `json:"id"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	Width       int32     `json:"width"`
+	Height      int32     `json:"height"`
+	Blurhash    string    `json:"blurhash"`
+	AltText     string    `json:"alt_text"`
+	Status      string    `json:"status"`
+	// Keyed by variant name; empty until processing has finished
+	Variants map[string]mediaVariantResponse `json:"variants"`
+}
+
+type mediaVariantResponse struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Width       int32  `json:"width"`
+	Height      int32  `json:"height"`
+}
+
+// Width, height and content type describe the full variant once there is
+// one, and the upload until then
+func (cfg *apiConfig) newMediaResponse(m database.Medium, variants []database.MediaVariant) mediaResponse {
+	resp := mediaResponse{
+		ID:          m.ID,
+		URL:         fmt.Sprintf("%s/media/%s", cfg.baseURL, m.ID),
+		ContentType: m.ContentType,
+		Width:       m.Width,
+		Height:      m.Height,
+		Blurhash:    m.Blurhash,
+		AltText:     m.AltText,
+		Status:      m.Status,
+		Variants:    map[string]mediaVariantResponse{},
+	}
+	for _, v := range variants {
+		resp.Variants[v.Variant] = mediaVariantResponse{
+			URL:         fmt.Sprintf("%s/media/%s/%s", cfg.baseURL, m.ID, v.Variant),
+			ContentType: v.ContentType,
+			Width:       v.Width,
+			Height:      v.Height,
+		}
+		if v.Variant == "full" {
+			resp.ContentType, resp.Width, resp.Height = v.ContentType, v.Width, v.Height
+		}
+	}
+	return resp
+}
+
+// MEDIA_STORE picks the blob store: "local" (the default) writes under
+// MEDIA_DIR, "s3" uses the bucket described by the S3_* variables
+func newBlobStore() (media.BlobStore, error) {
+	switch store := os.Getenv("MEDIA_STORE"); store {
+	case "", "local":
+		dir := os.Getenv("MEDIA_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return media.NewFileStore(dir)
+	case "s3":
+		region := os.Getenv("S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		return media.NewS3Store(os.Getenv("S3_ENDPOINT"), os.Getenv("S3_BUCKET"), region, media.Credentials{
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown MEDIA_STORE %q", store)
+	}
+}
+
+// Accepts one image as multipart form field "file", with optional
+// "alt_text". The type is decided by sniffing the bytes, not by what the
+// client declared. The original is kept out of public reach until the
+// workers have turned it into variants.
+func (cfg *apiConfig) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadBytes+64<<10)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		api.RespondWithError(w, "A file is required: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxUploadBytes+1))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) > media.MaxUploadBytes {
+		api.RespondWithError(w, "File is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	altText := r.FormValue("alt_text")
+	if len(altText) > maxAltText {
+		api.RespondWithError(w, "Alt text is too long", http.StatusBadRequest)
+		return
+	}
+	info, _, err := media.Inspect(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		api.RespondWithError(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	key := "uploads/" + uuid.NewString() + media.Extensions[info.ContentType]
+	if err := cfg.blobs.Put(ctx, key, data, info.ContentType); err != nil {
+		api.RespondWithError(w, "Error storing file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	m, err := cfg.dbQueries.CreateMedia(ctx, database.CreateMediaParams{
+		UserID:      userIDFromContext(r.Context()),
+		StorageKey:  key,
+		ContentType: info.ContentType,
+		SizeBytes:   int64(len(data)),
+		Width:       int32(info.Width),
+		Height:      int32(info.Height),
+		Blurhash:    info.Blurhash,
+		AltText:     altText,
+	})
+	if err != nil {
+		if err := cfg.blobs.Delete(ctx, key); err != nil {
+			log.Println("Error removing unrecorded upload: ", err)
+		}
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	nudgeMediaProcessor()
+	api.RespondWithJSON(w, cfg.newMediaResponse(m, nil), http.StatusCreated)
+}
+
+// Checks that the viewer may read the chirp the media is attached to, if
+// any, and returns how the response may be cached. Media the viewer may not
+// see reports sql.ErrNoRows, as if it didn't exist.
+func (cfg *apiConfig) mediaAccess(ctx context.Context, viewer uuid.NullUUID, id uuid.UUID) (string, error) {
+	m, err := cfg.dbQueries.GetMediaByID(ctx, id)
+	if err != nil || !m.ChirpID.Valid {
+		return mediaCacheControl, err
+	}
+	chirp, err := cfg.dbQueries.GetChirpByID(ctx, m.ChirpID.UUID)
+	if err != nil {
+		return "", err
+	}
+	ok, err := cfg.canViewChirp(ctx, viewer, chirp)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	if chirp.Visibility == visibilityPublic || chirp.Visibility == visibilityUnlisted {
+		return mediaCacheControl, nil
+	}
+	return restrictedMediaCacheControl, nil
+}
+
+// Serves one variant of processed media, the full one when the route names
+// none. Media still processing, or that failed to, has nothing to serve.
+func (cfg *apiConfig) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	variant := r.PathValue("variant")
+	if variant == "" {
+		variant = "full"
+	}
+	cacheControl, err := cfg.mediaAccess(r.Context(), cfg.viewerID(r), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "Media not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	v, err := cfg.dbQueries.GetMediaVariant(context.Background(), database.GetMediaVariantParams{
+		MediaID: id,
+		Variant: variant,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "Media not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	etag := fmt.Sprintf("%q", v.MediaID.String()+"-"+v.Variant)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	blob, err := cfg.blobs.Open(r.Context(), v.StorageKey)
+	if errors.Is(err, media.ErrNotFound) {
+		api.RespondWithError(w, "Media not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+	w.Header().Set("Content-Type", v.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(v.SizeBytes, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.Copy(w, blob)
+}
+
+func nudgeMediaProcessor() {
+	select {
+	case mediaQueue <- struct{}{}:
+	default:
+	}
+}
+
+// Claims uploads awaiting processing and hands them to a pool of
+// mediaWorkers goroutines
+func (cfg *apiConfig) MediaWorker() {
+	jobs := make(chan database.Medium)
+	for range mediaWorkers {
+		go func() {
+			for m := range jobs {
+				cfg.processMedia(m)
+			}
+		}()
+	}
+	ticker := time.NewTicker(mediaPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-mediaQueue:
+		case <-ticker.C:
+		}
+		for {
+			pending, err := cfg.dbQueries.ClaimPendingMedia(context.Background(), database.ClaimPendingMediaParams{
+				LeaseSeconds: mediaLeaseSeconds,
+				Batch:        mediaBatchSize,
+			})
+			if err != nil {
+				log.Println("Error claiming media for processing: ", err)
+				break
+			}
+			for _, m := range pending {
+				jobs <- m
+			}
+			if len(pending) < mediaBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// Stores every variant of an upload, then drops the original. A failure
+// leaves the upload to be retried when its lease lapses, until it has used
+// up mediaMaxAttempts.
+func (cfg *apiConfig) processMedia(m database.Medium) {
+	ctx := context.Background()
+	if err := cfg.storeMediaVariants(ctx, m); err != nil {
+		log.Printf("Error processing media %s (attempt %d): %v", m.ID, m.Attempts, err)
+		if m.Attempts >= mediaMaxAttempts {
+			if err := cfg.dbQueries.MarkMediaFailed(ctx, m.ID); err != nil {
+				log.Println("Error marking media failed: ", err)
+			}
+		}
+		return
+	}
+	if err := cfg.dbQueries.MarkMediaReady(ctx, m.ID); err != nil {
+		log.Println("Error marking media ready: ", err)
+		return
+	}
+	if err := cfg.blobs.Delete(ctx, m.StorageKey); err != nil {
+		log.Println("Error deleting processed original: ", err)
+	}
+}
+
+func (cfg *apiConfig) storeMediaVariants(ctx context.Context, m database.Medium) error {
+	blob, err := cfg.blobs.Open(ctx, m.StorageKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		return err
+	}
+	renditions, err := media.Process(data)
+	if err != nil {
+		return err
+	}
+	for _, r := range renditions {
+		key := fmt.Sprintf("media/%s/%s%s", m.ID, r.Variant, media.Extensions[r.ContentType])
+		if err := cfg.blobs.Put(ctx, key, r.Data, r.ContentType); err != nil {
+			return err
+		}
+		err := cfg.dbQueries.CreateMediaVariant(ctx, database.CreateMediaVariantParams{
+			MediaID:     m.ID,
+			Variant:     r.Variant,
+			StorageKey:  key,
+			ContentType: r.ContentType,
+			SizeBytes:   int64(len(r.Data)),
+			Width:       int32(r.Width),
+			Height:      int32(r.Height),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Claims uploaded media for a new chirp, in the order given, inside the
+// caller's transaction
+func attachChirpMedia(ctx context.Context, q *database.Queries, chirp database.Chirp, ids []uuid.UUID) error {
+	for i, id := range ids {
+		n, err := q.AttachMedia(ctx, database.AttachMediaParams{
+			ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Position: int32(i),
+			ID:       id,
+			UserID:   chirp.UserID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: %s", errUnknownMedia, id)
+		}
+	}
+	return nil
+}
+
+// Adds each chirp's media, flagging chirps whose media is not processed yet
+func (cfg *apiConfig) attachMedia(ctx context.Context, resp []chirpResponse) error {
+	ids := make([]uuid.UUID, len(resp))
+	index := make(map[uuid.UUID]int, len(resp))
+	for i := range resp {
+		ids[i] = resp[i].ID
+		index[resp[i].ID] = i
+		resp[i].Media = []mediaResponse{}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	rows, err := cfg.dbQueries.GetMediaForChirps(ctx, ids)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+	mediaIDs := make([]uuid.UUID, len(rows))
+	for i, m := range rows {
+		mediaIDs[i] = m.ID
+	}
+	variants, err := cfg.dbQueries.GetVariantsForMedia(ctx, mediaIDs)
+	if err != nil {
+		return err
+	}
+	byMedia := make(map[uuid.UUID][]database.MediaVariant, len(rows))
+	for _, v := range variants {
+		byMedia[v.MediaID] = append(byMedia[v.MediaID], v)
+	}
+	for _, m := range rows {
+		i := index[m.ChirpID.UUID]
+		resp[i].Media = append(resp[i].Media, cfg.newMediaResponse(m, byMedia[m.ID]))
+		if m.Status == mediaProcessing {
+			resp[i].Processing = true
+		}
+	}
+	return nil
+}
+
+// Deletes orphaned uploads and their blobs once an hour
+func (cfg *apiConfig) MediaJanitor() {
+	ticker := time.NewTicker(mediaJanitorInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx := context.Background()
+		keys, err := cfg.dbQueries.DeleteOrphanedMedia(ctx, time.Now().Add(-mediaOrphanAge))
+		if err != nil {
+			log.Println("Error deleting orphaned media: ", err)
+			continue
+		}
+		for _, key := range keys {
+			if err := cfg.blobs.Delete(ctx, key); err != nil {
+				log.Println("Error deleting media blob: ", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/Lewvy/chirpy/internal/entities"
+	"github.com/google/uuid"
+)
+
+type mentionEntity struct {
+	entities.Entity
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type chirpEntities struct {
+	Hashtags []entities.Entity `json:"hashtags"`
+	Mentions []mentionEntity   `json:"mentions"`
+	Links    []entities.Entity `json:"links"`
+}
+
+// Resolves @handles in the chirp to users and stores them, returning each
+// mentioned user once. Unknown handles, and users who have blocked the
+// author, are left as plain text.
+func saveChirpMentions(ctx context.Context, q *database.Queries, chirp database.Chirp) ([]uuid.UUID, error) {
+	found := entities.Mentions(chirp.Body)
+	if len(found) == 0 {
+		return nil, nil
+	}
+	users, err := q.GetUsersByHandles(ctx, entities.Unique(found))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	blockers, err := q.GetBlockersAmong(ctx, database.GetBlockersAmongParams{
+		BlockedID: chirp.UserID,
+		UserIds:   ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	blockedBy := toSet(blockers)
+	byHandle := make(map[string]uuid.UUID, len(users))
+	for _, u := range users {
+		if _, ok := blockedBy[u.ID]; !ok {
+			byHandle[strings.ToLower(u.Handle.String)] = u.ID
+		}
+	}
+
+	var mentioned []uuid.UUID
+	seen := make(map[uuid.UUID]struct{})
+	for _, m := range found {
+		userID, ok := byHandle[m.Text]
+		if !ok {
+			continue
+		}
+		err := q.CreateMention(ctx, database.CreateMentionParams{
+			ChirpID:     chirp.ID,
+			UserID:      userID,
+			StartOffset: int32(m.Start),
+			EndOffset:   int32(m.End),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[userID]; ok {
+			continue
+		}
+		seen[userID] = struct{}{}
+		mentioned = append(mentioned, userID)
+	}
+	return mentioned, nil
+}
+
+// Notifies mentioned users of the chirp, returning who was notified
+func notifyMentioned(ctx context.Context, q *database.Queries, chirp database.Chirp, mentioned []uuid.UUID) ([]uuid.UUID, error) {
+	var notified []uuid.UUID
+	for _, userID := range mentioned {
+		created, err := notify(ctx, q, userID, chirp.UserID, notificationMention, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			notified = append(notified, userID)
+		}
+	}
+	return notified, nil
+}
+
+func (cfg *apiConfig) attachEntities(ctx context.Context, resp []chirpResponse) error {
+	ids := make([]uuid.UUID, len(resp))
+	for i := range resp {
+		ids[i] = resp[i].ID
+		resp[i].Entities = chirpEntities{
+			Hashtags: entities.Hashtags(resp[i].Body),
+			Mentions: []mentionEntity{},
+			Links:    entities.Links(resp[i].Body),
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := cfg.dbQueries.GetMentionsForChirps(ctx, ids)
+	if err != nil {
+		return err
+	}
+	index := make(map[uuid.UUID]int, len(resp))
+	for i := range resp {
+		index[resp[i].ID] = i
+	}
+	for _, row := range rows {
+		i := index[row.ChirpID]
+		resp[i].Entities.Mentions = append(resp[i].Entities.Mentions, mentionEntity{
+			Entity: entities.Entity{
+				Kind:  entities.Mention,
+				Text:  strings.ToLower(row.Handle.String),
+				Start: int(row.StartOffset),
+				End:   int(row.EndOffset),
+			},
+			UserID: row.UserID,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxMessageLen = 2000
+	// Including the creator
+	maxConversationMembers = 10
+)
+
+type conversationMember struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	Handle     string     `json:"handle,omitempty"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type conversationResponse struct {
+	ID          uuid.UUID            `json:"id"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	Direct      bool                 `json:"direct"`
+	Members     []conversationMember `json:"members"`
+	LastMessage *database.Message    `json:"last_message"`
+	UnreadCount int64                `json:"unread_count"`
+}
+
+type messageResponse struct {
+	database.Message
+	// Members other than the sender whose read receipt covers this message
+	ReadBy []uuid.UUID `json:"read_by"`
+}
+
+// directKey identifies the single one-to-one thread between two users
+func directKey(a, b uuid.UUID) string {
+	ids := []string{a.String(), b.String()}
+	slices.Sort(ids)
+	return strings.Join(ids, ":")
+}
+
+func validMessage(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", errors.New("Message body is required")
+	}
+	if len(body) > maxMessageLen {
+		return "", errors.New("Message is too long")
+	}
+	return body, nil
+}
+
+func (cfg *apiConfig) conversationResponses(ctx context.Context, rows []database.ListConversationsRow) ([]conversationResponse, error) {
+	resp := make([]conversationResponse, len(rows))
+	if len(rows) == 0 {
+		return resp, nil
+	}
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+
+	members, err := cfg.dbQueries.GetConversationMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := cfg.dbQueries.GetLatestMessages(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	var userIDs []uuid.UUID
+	for _, m := range members {
+		userIDs = append(userIDs, m.UserID)
+	}
+	users, err := cfg.dbQueries.GetUsersByIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	handles := make(map[uuid.UUID]string, len(users))
+	for _, u := range users {
+		handles[u.ID] = u.Handle.String
+	}
+
+	byConversation := make(map[uuid.UUID][]conversationMember)
+	for _, m := range members {
+		member := conversationMember{UserID: m.UserID, Handle: handles[m.UserID]}
+		if m.LastReadAt.Valid {
+			member.LastReadAt = &m.LastReadAt.Time
+		}
+		byConversation[m.ConversationID] = append(byConversation[m.ConversationID], member)
+	}
+	lastMessage := make(map[uuid.UUID]database.Message, len(latest))
+	for _, m := range latest {
+		lastMessage[m.ConversationID] = m
+	}
+
+	for i, row := range rows {
+		resp[i] = conversationResponse{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			Direct:      row.DirectKey.Valid,
+			Members:     byConversation[row.ID],
+			UnreadCount: row.UnreadCount,
+		}
+		if m, ok := lastMessage[row.ID]; ok {
+			resp[i].LastMessage = &m
+		}
+	}
+	return resp, nil
+}
+
+// Starts a conversation with one or more users, optionally with a first
+// message. A one-to-one conversation with the same user is reused.
+func (cfg *apiConfig) CreateConversation(w http.ResponseWriter, r *http.Request) {
+	reqBody := struct {
+		MemberIDs []uuid.UUID `json:"member_ids"`
+		Body      string      `json:"body"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+
+	others := make([]uuid.UUID, 0, len(reqBody.MemberIDs))
+	for _, id := range reqBody.MemberIDs {
+		if id != userID && !slices.Contains(others, id) {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		api.RespondWithError(w, "A conversation needs at least one other member", http.StatusBadRequest)
+		return
+	}
+	if len(others)+1 > maxConversationMembers {
+		api.RespondWithError(w, "Too many conversation members", http.StatusBadRequest)
+		return
+	}
+	var body string
+	if reqBody.Body != "" {
+		var err error
+		if body, err = validMessage(reqBody.Body); err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	users, err := cfg.dbQueries.GetUsersByIDs(ctx, others)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(users) != len(others) {
+		api.RespondWithError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if ok := cfg.checkNotBlocked(w, userID, others); !ok {
+		return
+	}
+
+	var key sql.NullString
+	if len(others) == 1 {
+		key = sql.NullString{String: directKey(userID, others[0]), Valid: true}
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	conv, err := qtx.CreateConversation(ctx, key)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, id := range append(others, userID) {
+		if err := qtx.AddConversationMember(ctx, database.AddConversationMemberParams{
+			ConversationID: conv.ID,
+			UserID:         id,
+		}); err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if body != "" {
+		if _, err := sendMessage(ctx, qtx, conv.ID, userID, body); err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := cfg.conversationResponses(ctx, []database.ListConversationsRow{{
+		ID:        conv.ID,
+		CreatedAt: conv.CreatedAt,
+		UpdatedAt: conv.UpdatedAt,
+		DirectKey: conv.DirectKey,
+	}})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, resp[0], http.StatusCreated)
+}
+
+// Conversations the caller belongs to, most recently active first
+func (cfg *apiConfig) ListConversations(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	rows, err := cfg.dbQueries.ListConversations(ctx, database.ListConversationsParams{
+		UserID: userIDFromContext(r.Context()),
+		Before: before,
+		Lim:    limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp, err := cfg.conversationResponses(ctx, rows)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+// conversationID parses the path id and checks the caller is a member.
+// Non-members get the same 404 as a missing conversation.
+func (cfg *apiConfig) conversationID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return id, false
+	}
+	_, err = cfg.dbQueries.GetConversationMember(context.Background(), database.GetConversationMemberParams{
+		ConversationID: id,
+		UserID:         userIDFromContext(r.Context()),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "Conversation not found", http.StatusNotFound)
+		return id, false
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return id, false
+	}
+	return id, true
+}
+
+// checkNotBlocked rejects the request when any recipient has blocked the sender
+func (cfg *apiConfig) checkNotBlocked(w http.ResponseWriter, senderID uuid.UUID, recipients []uuid.UUID) bool {
+	blockers, err := cfg.dbQueries.GetBlockersAmong(context.Background(), database.GetBlockersAmongParams{
+		BlockedID: senderID,
+		UserIds:   recipients,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if len(blockers) > 0 {
+		api.RespondWithError(w, "You cannot message this user", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// sendMessage stores a message, bumps the conversation and moves the
+// sender's read receipt past it
+func sendMessage(ctx context.Context, q *database.Queries, conversationID, senderID uuid.UUID, body string) (database.Message, error) {
+	msg, err := q.CreateMessage(ctx, database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Body:           body,
+	})
+	if err != nil {
+		return msg, err
+	}
+	if err := q.TouchConversation(ctx, database.TouchConversationParams{
+		ID:        conversationID,
+		UpdatedAt: msg.CreatedAt,
+	}); err != nil {
+		return msg, err
+	}
+	err = q.MarkConversationRead(ctx, database.MarkConversationReadParams{
+		ReadAt:         msg.CreatedAt,
+		ConversationID: conversationID,
+		UserID:         senderID,
+	})
+	return msg, err
+}
+
+func (cfg *apiConfig) SendMessage(w http.ResponseWriter, r *http.Request) {
+	conversationID, ok := cfg.conversationID(w, r)
+	if !ok {
+		return
+	}
+	reqBody := struct {
+		Body string `json:"body"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body, err := validMessage(reqBody.Body)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+
+	members, err := cfg.dbQueries.GetConversationMembers(ctx, []uuid.UUID{conversationID})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recipients := make([]uuid.UUID, 0, len(members))
+	for _, m := range members {
+		if m.UserID != userID {
+			recipients = append(recipients, m.UserID)
+		}
+	}
+	if ok := cfg.checkNotBlocked(w, userID, recipients); !ok {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	msg, err := sendMessage(ctx, cfg.dbQueries.WithTx(tx), conversationID, userID, body)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, messageResponse{Message: msg, ReadBy: []uuid.UUID{}}, http.StatusCreated)
+}
+
+// Messages in a conversation, newest first, each with its read receipts
+func (cfg *apiConfig) GetMessages(w http.ResponseWriter, r *http.Request) {
+	conversationID, ok := cfg.conversationID(w, r)
+	if !ok {
+		return
+	}
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+
+	msgs, err := cfg.dbQueries.GetMessages(ctx, database.GetMessagesParams{
+		ConversationID: conversationID,
+		Before:         before,
+		Lim:            limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	members, err := cfg.dbQueries.GetConversationMembers(ctx, []uuid.UUID{conversationID})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]messageResponse, len(msgs))
+	for i, msg := range msgs {
+		readBy := []uuid.UUID{}
+		for _, m := range members {
+			if m.UserID != msg.SenderID && m.LastReadAt.Valid && !m.LastReadAt.Time.Before(msg.CreatedAt) {
+				readBy = append(readBy, m.UserID)
+			}
+		}
+		resp[i] = messageResponse{Message: msg, ReadBy: readBy}
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+// Moves the caller's read receipt up to the `up_to` message, or to now if
+// no message is given
+func (cfg *apiConfig) MarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	conversationID, ok := cfg.conversationID(w, r)
+	if !ok {
+		return
+	}
+	reqBody := struct {
+		UpTo *uuid.UUID `json:"up_to"`
+	}{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	ctx := context.Background()
+
+	readAt := time.Now()
+	if reqBody.UpTo != nil {
+		createdAt, err := cfg.dbQueries.GetMessageCreatedAt(ctx, database.GetMessageCreatedAtParams{
+			ID:             *reqBody.UpTo,
+			ConversationID: conversationID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			api.RespondWithError(w, "Message not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		readAt = createdAt
+	}
+
+	if err := cfg.dbQueries.MarkConversationRead(ctx, database.MarkConversationReadParams{
+		ReadAt:         readAt,
+		ConversationID: conversationID,
+		UserID:         userIDFromContext(r.Context()),
+	}); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, "Marked as read", http.StatusOK)
+}
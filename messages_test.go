@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDirectConversationsAreSharedByTheirMembersOnly(t *testing.T) {
+	ts := newTestServer(t)
+	alice, aliceToken := ts.createUser("alice")
+	bob, bobToken := ts.createUser("bob")
+	_, eveToken := ts.createUser("eve")
+
+	var first, second conversationResponse
+	ts.must(http.StatusCreated, http.MethodPost, "/api/conversations", aliceToken,
+		map[string]any{"member_ids": []any{bob}, "body": "hi bob"}, &first)
+	ts.must(http.StatusCreated, http.MethodPost, "/api/conversations", bobToken,
+		map[string]any{"member_ids": []any{alice}}, &second)
+	if !first.Direct || second.ID != first.ID {
+		t.Fatalf("bob got conversation %s, want alice's direct one %s", second.ID, first.ID)
+	}
+
+	path := "/api/conversations/" + first.ID.String()
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   any
+	}{
+		{http.MethodGet, path + "/messages", nil},
+		{http.MethodPost, path + "/messages", map[string]string{"body": "let me in"}},
+		{http.MethodPost, path + "/read", nil},
+	} {
+		if status, body := ts.do(tc.method, tc.path, eveToken, tc.body); status != http.StatusNotFound {
+			t.Errorf("outsider %s %s: status %d, want 404: %s", tc.method, tc.path, status, body)
+		}
+	}
+
+	var inbox []conversationResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/conversations", bobToken, nil, &inbox)
+	if len(inbox) != 1 || inbox[0].UnreadCount != 1 {
+		t.Fatalf("bob's conversations = %+v, want one with an unread message", inbox)
+	}
+	ts.must(http.StatusOK, http.MethodPost, path+"/read", bobToken, nil, nil)
+	var msgs []messageResponse
+	ts.must(http.StatusOK, http.MethodGet, path+"/messages", aliceToken, nil, &msgs)
+	if len(msgs) != 1 || len(msgs[0].ReadBy) != 1 || msgs[0].ReadBy[0] != bob {
+		t.Errorf("messages = %+v, want one read by bob", msgs)
+	}
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"os"
 
 	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/auth"
+	"github.com/google/uuid"
 )
 
+type contextKey string
+
+const userIDKey contextKey = "userID"
+
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cfg.fileserverHits.Add(1)
@@ -24,3 +31,42 @@ func (cfg *apiConfig) middlewareCheckPlatform(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (cfg *apiConfig) middlewareAuth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		// Suspended accounts can still read, but every write is refused
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && !cfg.allowWrite(w, userID) {
+			return
+		}
+		ctx := context.WithValue(r.Context(), userIDKey, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func userIDFromContext(ctx context.Context) uuid.UUID {
+	userID, _ := ctx.Value(userIDKey).(uuid.UUID)
+	return userID
+}
+
+// Public routes still personalise responses when a valid token is sent
+func (cfg *apiConfig) viewerID(r *http.Request) uuid.NullUUID {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
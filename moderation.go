@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+
+	moderationDismiss = "dismiss"
+	moderationHide    = "hide"
+	moderationDelete  = "delete"
+	moderationSuspend = "suspend"
+
+	defaultSuspension = 7 * 24 * time.Hour
+	maxReportDetails  = 500
+	maxQueueSize      = 100
+)
+
+var reportCategories = map[string]bool{
+	"spam":           true,
+	"harassment":     true,
+	"hate":           true,
+	"violence":       true,
+	"misinformation": true,
+	"other":          true,
+}
+
+// How a reporter is told their report was resolved
+var reportResolutions = map[string]string{
+	moderationDismiss: "no action was needed",
+	moderationHide:    "the chirp was hidden",
+	moderationDelete:  "the chirp was removed",
+	moderationSuspend: "its author was suspended",
+}
+
+// Moderators can only suspend users ranked below them; regular users rank 0
+var roleRanks = map[string]int{
+	roleModerator: 1,
+	roleAdmin:     2,
+}
+
+var moderationActions = map[string]bool{
+	moderationDismiss: true,
+	moderationHide:    true,
+	moderationDelete:  true,
+	moderationSuspend: true,
+}
+
+// Lets through authenticated users with the moderator or admin role
+func (cfg *apiConfig) middlewareModerator(next http.HandlerFunc) http.Handler {
+	return cfg.middlewareRole(next, roleModerator, roleAdmin)
+}
+
+func (cfg *apiConfig) middlewareAdmin(next http.HandlerFunc) http.Handler {
+	return cfg.middlewareRole(next, roleAdmin)
+}
+
+func (cfg *apiConfig) middlewareRole(next http.HandlerFunc, roles ...string) http.Handler {
+	return cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request) {
+		role, err := cfg.dbQueries.GetUserRole(context.Background(), userIDFromContext(r.Context()))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !slices.Contains(roles, role) {
+			api.RespondWithError(w, "Insufficient role for this action", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// Whether moderator's role ranks above user's
+func (cfg *apiConfig) outranks(ctx context.Context, moderator, user uuid.UUID) (bool, error) {
+	moderatorRole, err := cfg.dbQueries.GetUserRole(ctx, moderator)
+	if err != nil {
+		return false, err
+	}
+	userRole, err := cfg.dbQueries.GetUserRole(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	return roleRanks[moderatorRole] > roleRanks[userRole], nil
+}
+
+func (cfg *apiConfig) ReportChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reqBody := struct {
+		Category string `json:"category"`
+		Details  string `json:"details"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !reportCategories[reqBody.Category] {
+		api.RespondWithError(w, "Unknown report category: "+reqBody.Category, http.StatusBadRequest)
+		return
+	}
+	if len(reqBody.Details) > maxReportDetails {
+		api.RespondWithError(w, "Report details are too long", http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+
+	chirp, err := cfg.dbQueries.GetChirpByID(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	visible, err := cfg.canViewChirp(ctx, uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if chirp.UserID == userID {
+		api.RespondWithError(w, "You cannot report your own chirp", http.StatusBadRequest)
+		return
+	}
+
+	report, err := cfg.dbQueries.CreateReport(ctx, database.CreateReportParams{
+		ChirpID:    chirpID,
+		ReporterID: userID,
+		Category:   reqBody.Category,
+		Details:    sql.NullString{String: reqBody.Details, Valid: reqBody.Details != ""},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithJSON(w, "Already reported", http.StatusOK)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, report, http.StatusCreated)
+}
+
+// The caller's own reports and how each was resolved
+func (cfg *apiConfig) ListMyReports(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reports, err := cfg.dbQueries.GetReportsByReporter(context.Background(), database.GetReportsByReporterParams{
+		ReporterID: userIDFromContext(r.Context()),
+		Before:     before,
+		Lim:        limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, reports, http.StatusOK)
+}
+
+func (cfg *apiConfig) GetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	limit := int32(defaultPageSize)
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxQueueSize {
+			api.RespondWithError(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = int32(n)
+	}
+	rows, err := cfg.dbQueries.GetModerationQueue(context.Background(), limit)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, rows, http.StatusOK)
+}
+
+func (cfg *apiConfig) GetModerationLog(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := cfg.dbQueries.GetModerationLog(context.Background(), database.GetModerationLogParams{
+		Before: before,
+		Lim:    limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, entries, http.StatusOK)
+}
+
+// Applies a moderator decision to a reported chirp: dismiss the reports,
+// hide or delete the chirp, or suspend its author. Open reports on the chirp
+// are resolved, the decision is logged and each reporter is notified.
+func (cfg *apiConfig) ModerateChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reqBody := struct {
+		Action       string `json:"action"`
+		Reason       string `json:"reason"`
+		SuspendHours int    `json:"suspend_hours"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !moderationActions[reqBody.Action] {
+		api.RespondWithError(w, "Unknown moderation action: "+reqBody.Action, http.StatusBadRequest)
+		return
+	}
+	if reqBody.SuspendHours < 0 {
+		api.RespondWithError(w, "suspend_hours must be positive", http.StatusBadRequest)
+		return
+	}
+	moderatorID := userIDFromContext(r.Context())
+	ctx := context.Background()
+
+	chirp, err := cfg.dbQueries.GetChirpByID(ctx, chirpID)
+	found := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Reports on a chirp its author already deleted can still be dismissed
+	if !found && reqBody.Action != moderationDismiss {
+		api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if reqBody.Action == moderationSuspend {
+		if chirp.UserID == moderatorID {
+			api.RespondWithError(w, "You cannot suspend yourself", http.StatusForbidden)
+			return
+		}
+		outranks, err := cfg.outranks(ctx, moderatorID, chirp.UserID)
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !outranks {
+			api.RespondWithError(w, "You cannot suspend a user whose role is equal to or above yours", http.StatusForbidden)
+			return
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	var target uuid.NullUUID
+	if found {
+		target = uuid.NullUUID{UUID: chirp.UserID, Valid: true}
+	}
+	subject := uuid.NullUUID{UUID: chirpID, Valid: found}
+	switch reqBody.Action {
+	case moderationHide:
+		err = qtx.HideChirp(ctx, chirpID)
+	case moderationDelete:
+		_, err = qtx.DeleteChirpByID(ctx, chirpID)
+		// The notification can't point at a chirp that no longer exists
+		subject = uuid.NullUUID{}
+	case moderationSuspend:
+		duration := defaultSuspension
+		if reqBody.SuspendHours > 0 {
+			duration = time.Duration(reqBody.SuspendHours) * time.Hour
+		}
+		err = qtx.SuspendUser(ctx, database.SuspendUserParams{
+			ID:               chirp.UserID,
+			SuspendedUntil:   sql.NullTime{Time: time.Now().Add(duration), Valid: true},
+			SuspensionReason: sql.NullString{String: reqBody.Reason, Valid: reqBody.Reason != ""},
+		})
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	reporters, err := qtx.ResolveReports(ctx, database.ResolveReportsParams{
+		Resolution: sql.NullString{String: reqBody.Action, Valid: true},
+		ChirpID:    chirpID,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entry, err := qtx.CreateModerationLogEntry(ctx, database.CreateModerationLogEntryParams{
+		ModeratorID:  moderatorID,
+		Action:       reqBody.Action,
+		ChirpID:      uuid.NullUUID{UUID: chirpID, Valid: true},
+		TargetUserID: target,
+		Reason:       reqBody.Reason,
+		ReportCount:  int32(len(reporters)),
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var notified []uuid.UUID
+	for _, reporterID := range reporters {
+		created, err := notifySystem(ctx, qtx, reporterID, moderatorID, notificationReport, subject, reqBody.Action)
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if created {
+			notified = append(notified, reporterID)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, reporterID := range notified {
+		go cfg.publishNotification(reporterID, moderatorID, notificationReport, subject)
+	}
+	if reqBody.Action == moderationDelete {
+		go cfg.emitWebhookEvent(webhookChirpDeleted, struct {
+			ID     uuid.UUID `json:"id"`
+			UserID uuid.UUID `json:"user_id"`
+		}{ID: chirp.ID, UserID: chirp.UserID})
+		go cfg.federateChirpDeletion(chirp)
+	}
+	api.RespondWithJSON(w, entry, http.StatusOK)
+}
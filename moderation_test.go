@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestReportsQueueOncePerReporter(t *testing.T) {
+	ts := newTestServer(t)
+	_, authorToken := ts.createUser("author")
+	_, firstToken := ts.createUser("first")
+	_, secondToken := ts.createUser("second")
+	moderator, modToken := ts.createUser("mod")
+	if _, err := ts.cfg.db.Exec(`UPDATE users SET role = $2 WHERE id = $1`, moderator, roleModerator); err != nil {
+		t.Fatal(err)
+	}
+	c := ts.chirp(authorToken, "something reportable", nil)
+	path := "/api/chirps/" + c.ID.String() + "/report"
+
+	ts.must(http.StatusCreated, http.MethodPost, path, firstToken, map[string]string{"category": "spam"}, nil)
+	ts.must(http.StatusOK, http.MethodPost, path, firstToken, map[string]string{"category": "spam"}, nil)
+	ts.must(http.StatusCreated, http.MethodPost, path, secondToken, map[string]string{"category": "spam"}, nil)
+	if status, body := ts.do(http.MethodPost, path, authorToken, map[string]string{"category": "spam"}); status != http.StatusBadRequest {
+		t.Errorf("reporting one's own chirp: status %d, want 400: %s", status, body)
+	}
+
+	if status, body := ts.do(http.MethodGet, "/admin/moderation/queue", firstToken, nil); status != http.StatusForbidden {
+		t.Errorf("queue for a regular user: status %d, want 403: %s", status, body)
+	}
+	var queue []database.GetModerationQueueRow
+	ts.must(http.StatusOK, http.MethodGet, "/admin/moderation/queue", modToken, nil, &queue)
+	if len(queue) != 1 || queue[0].ChirpID != c.ID || queue[0].ReportCount != 2 {
+		t.Fatalf("queue = %+v, want the chirp with 2 reports", queue)
+	}
+
+	ts.must(http.StatusOK, http.MethodPost, "/admin/moderation/chirps/"+c.ID.String(), modToken,
+		map[string]string{"action": moderationDismiss}, nil)
+	ts.must(http.StatusOK, http.MethodGet, "/admin/moderation/queue", modToken, nil, &queue)
+	if len(queue) != 0 {
+		t.Errorf("%d chirps still queued after dismissal", len(queue))
+	}
+}
+
+func TestReportOutcomeReachesReporterWhoBlockedModerator(t *testing.T) {
+	ts := newTestServer(t)
+	_, authorToken := ts.createUser("author")
+	_, reporterToken := ts.createUser("reporter")
+	moderator, modToken := ts.createUser("mod")
+	if _, err := ts.cfg.db.Exec(`UPDATE users SET role = $2 WHERE id = $1`, moderator, roleModerator); err != nil {
+		t.Fatal(err)
+	}
+	c := ts.chirp(authorToken, "something reportable", nil)
+
+	ts.must(http.StatusCreated, http.MethodPost, "/api/chirps/"+c.ID.String()+"/report", reporterToken,
+		map[string]string{"category": "spam"}, nil)
+	ts.must(http.StatusOK, http.MethodPost, "/api/users/"+moderator.String()+"/block", reporterToken, nil, nil)
+	ts.must(http.StatusOK, http.MethodPost, "/admin/moderation/chirps/"+c.ID.String(), modToken,
+		map[string]string{"action": moderationHide}, nil)
+
+	var groups []notificationGroup
+	ts.must(http.StatusOK, http.MethodGet, "/api/notifications", reporterToken, nil, &groups)
+	if len(groups) != 1 {
+		t.Fatalf("reporter has %d notification groups, want 1", len(groups))
+	}
+	g := groups[0]
+	want := "A chirp you reported was reviewed: the chirp was hidden"
+	if g.Type != notificationReport || g.Summary != want || g.Detail != moderationHide || len(g.Actors) != 0 {
+		t.Errorf("notification = %+v", g)
+	}
+	var unread struct {
+		Unread int64 `json:"unread"`
+	}
+	ts.must(http.StatusOK, http.MethodGet, "/api/notifications/unread_count", reporterToken, nil, &unread)
+	if unread.Unread != 1 {
+		t.Errorf("unread count = %d, want 1", unread.Unread)
+	}
+}
+
+func TestReportNeedsAVisibleChirp(t *testing.T) {
+	ts := newTestServer(t)
+	_, authorToken := ts.createUser("author")
+	_, strangerToken := ts.createUser("stranger")
+	c := ts.chirp(authorToken, "for my followers", map[string]any{"visibility": visibilityFollowers})
+
+	status, body := ts.do(http.MethodPost, "/api/chirps/"+c.ID.String()+"/report", strangerToken,
+		map[string]string{"category": "spam"})
+	if status != http.StatusNotFound {
+		t.Errorf("reporting a chirp the caller can't see: status %d, want 404: %s", status, body)
+	}
+}
+
+func TestModeratorsOnlySuspendUsersRankedBelowThem(t *testing.T) {
+	ts := newTestServer(t)
+	moderator, modToken := ts.createUser("mod")
+	peer, peerToken := ts.createUser("peer")
+	admin, adminToken := ts.createUser("admin")
+	_, userToken := ts.createUser("user")
+	for id, role := range map[uuid.UUID]string{moderator: roleModerator, peer: roleModerator, admin: roleAdmin} {
+		if _, err := ts.cfg.db.Exec(`UPDATE users SET role = $2 WHERE id = $1`, id, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	suspend := map[string]string{"action": moderationSuspend, "reason": "testing"}
+	for who, token := range map[string]string{"themselves": modToken, "another moderator": peerToken, "an admin": adminToken} {
+		c := ts.chirp(token, "by "+who, nil)
+		status, body := ts.do(http.MethodPost, "/admin/moderation/chirps/"+c.ID.String(), modToken, suspend)
+		if status != http.StatusForbidden {
+			t.Errorf("suspending %s: status %d, want 403: %s", who, status, body)
+		}
+	}
+
+	c := ts.chirp(userToken, "by a regular user", nil)
+	ts.must(http.StatusOK, http.MethodPost, "/admin/moderation/chirps/"+c.ID.String(), modToken, suspend, nil)
+	if status, body := ts.do(http.MethodPost, "/api/chirps", userToken, map[string]string{"body": "still here"}); status != http.StatusForbidden {
+		t.Errorf("posting after suspension: status %d, want 403: %s", status, body)
+	}
+}
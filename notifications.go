@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	notificationFollow  = "follow"
+	notificationLike    = "like"
+	notificationRechirp = "rechirp"
+	notificationReply   = "reply"
+	notificationMention = "mention"
+	notificationReport  = "report"
+	// Sent to the author, as both recipient and actor, when their poll ends
+	notificationPollClosed = "poll_closed"
+)
+
+// Verb phrase used when summarising a group of each notification type
+var notificationVerbs = map[string]string{
+	notificationFollow:     "followed you",
+	notificationLike:       "liked your chirp",
+	notificationRechirp:    "rechirped your chirp",
+	notificationReply:      "replied to your chirp",
+	notificationMention:    "mentioned you",
+	notificationReport:     "reviewed a chirp you reported",
+	notificationPollClosed: "ended your poll",
+}
+
+// Notifications about something that happened rather than about who did
+// it. They are summarised with a fixed notice and list no actors.
+var notificationNotices = map[string]string{
+	// Moderators stay anonymous to the people who reported
+	notificationReport:     "A chirp you reported was reviewed",
+	notificationPollClosed: "Your poll has ended",
+}
+
+// Wording for a notice's detail, by notification type
+var noticeDetails = map[string]map[string]string{
+	notificationReport: reportResolutions,
+}
+
+type notificationActor struct {
+	ID     uuid.UUID `json:"id"`
+	Handle string    `json:"handle,omitempty"`
+}
+
+type notificationGroup struct {
+	ID        uuid.UUID           `json:"id"`
+	Type      string              `json:"type"`
+	ChirpID   *uuid.UUID          `json:"chirp_id,omitempty"`
+	Actors    []notificationActor `json:"actors"`
+	Count     int64               `json:"count"`
+	Unread    bool                `json:"unread"`
+	Summary   string              `json:"summary"`
+	Detail    string              `json:"detail,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// Records a notification for userID about something actorID did, reporting
+// whether one was stored. Acting on your own content never notifies you, and
+// neither do users you have blocked or muted. Notices are the exception to
+// both rules and go through notifySystem: a poll ending is the author's own
+// doing, and a report's outcome is owed to the reporter whoever reviewed it.
+func notify(ctx context.Context, q *database.Queries, userID, actorID uuid.UUID, kind string, chirpID uuid.NullUUID) (bool, error) {
+	if _, notice := notificationNotices[kind]; notice {
+		return notifySystem(ctx, q, userID, actorID, kind, chirpID, "")
+	}
+	if userID == actorID {
+		return false, nil
+	}
+	n, err := q.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  userID,
+		ActorID: actorID,
+		Type:    kind,
+		ChirpID: chirpID,
+	})
+	return n > 0, err
+}
+
+// Records a notice, which no block, mute or shadow ban holds back
+func notifySystem(ctx context.Context, q *database.Queries, userID, actorID uuid.UUID, kind string, chirpID uuid.NullUUID, detail string) (bool, error) {
+	n, err := q.CreateSystemNotification(ctx, database.CreateSystemNotificationParams{
+		UserID:  userID,
+		ActorID: actorID,
+		Type:    kind,
+		ChirpID: chirpID,
+		Detail:  sql.NullString{String: detail, Valid: detail != ""},
+	})
+	return n > 0, err
+}
+
+// Withdraws a notification when its action is undone (unlike, unfollow, ...)
+func unnotify(ctx context.Context, q *database.Queries, userID, actorID uuid.UUID, kind string, chirpID uuid.NullUUID) error {
+	return q.DeleteNotification(ctx, database.DeleteNotificationParams{
+		UserID:  userID,
+		ActorID: actorID,
+		Type:    kind,
+		ChirpID: chirpID,
+	})
+}
+
+// Best-effort variants for handlers where the action itself already succeeded
+func (cfg *apiConfig) recordNotification(userID, actorID uuid.UUID, kind string, chirpID uuid.NullUUID) {
+	created, err := notify(context.Background(), cfg.dbQueries, userID, actorID, kind, chirpID)
+	if err != nil {
+		log.Println("Error creating notification: ", err)
+		return
+	}
+	if !created {
+		return
+	}
+	cfg.publishNotification(userID, actorID, kind, chirpID)
+}
+
+func (cfg *apiConfig) retractNotification(userID, actorID uuid.UUID, kind string, chirpID uuid.NullUUID) {
+	if err := unnotify(context.Background(), cfg.dbQueries, userID, actorID, kind, chirpID); err != nil {
+		log.Println("Error removing notification: ", err)
+	}
+}
+
+func summarize(kind, detail string, actors []notificationActor, total int64) string {
+	name := func(a notificationActor) string {
+		if a.Handle != "" {
+			return "@" + a.Handle
+		}
+		return "Someone"
+	}
+	if notice, ok := notificationNotices[kind]; ok {
+		if d, ok := noticeDetails[kind][detail]; ok {
+			return notice + ": " + d
+		}
+		return notice
+	}
+	verb := notificationVerbs[kind]
+	switch {
+	case len(actors) == 0:
+		return fmt.Sprintf("%d people %s", total, verb)
+	case total == 1:
+		return fmt.Sprintf("%s %s", name(actors[0]), verb)
+	case total == 2 && len(actors) >= 2:
+		return fmt.Sprintf("%s and %s %s", name(actors[0]), name(actors[1]), verb)
+	case total == 2:
+		return fmt.Sprintf("%s and 1 other %s", name(actors[0]), verb)
+	default:
+		return fmt.Sprintf("%s and %d others %s", name(actors[0]), total-1, verb)
+	}
+}
+
+func (cfg *apiConfig) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("type")
+	if _, ok := notificationVerbs[kind]; kind != "" && !ok {
+		api.RespondWithError(w, "Unknown notification type: "+kind, http.StatusBadRequest)
+		return
+	}
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+
+	rows, err := cfg.dbQueries.GetGroupedNotifications(ctx, database.GetGroupedNotificationsParams{
+		UserID: userIDFromContext(r.Context()),
+		Type:   kind,
+		Before: before,
+		Lim:    limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var actorIDs []uuid.UUID
+	for _, row := range rows {
+		actorIDs = append(actorIDs, row.ActorIds...)
+	}
+	handles := make(map[uuid.UUID]string)
+	if len(actorIDs) > 0 {
+		users, err := cfg.dbQueries.GetUsersByIDs(ctx, actorIDs)
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, u := range users {
+			handles[u.ID] = u.Handle.String
+		}
+	}
+
+	groups := make([]notificationGroup, len(rows))
+	for i, row := range rows {
+		actors := []notificationActor{}
+		if _, notice := notificationNotices[row.Type]; !notice {
+			for _, id := range row.ActorIds {
+				actors = append(actors, notificationActor{ID: id, Handle: handles[id]})
+			}
+		}
+		groups[i] = notificationGroup{
+			ID:        row.LatestID,
+			Type:      row.Type,
+			Actors:    actors,
+			Count:     row.Total,
+			Unread:    row.Unread > 0,
+			Summary:   summarize(row.Type, row.Detail.String, actors, row.Total),
+			Detail:    row.Detail.String,
+			CreatedAt: row.LatestAt,
+		}
+		if row.ChirpID.Valid {
+			groups[i].ChirpID = &row.ChirpID.UUID
+		}
+	}
+	api.RespondWithJSON(w, groups, http.StatusOK)
+}
+
+// Marks everything up to and including the `up_to` notification as read, or
+// everything if no cursor is given.
+func (cfg *apiConfig) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	reqBody := struct {
+		UpTo *uuid.UUID `json:"up_to"`
+	}{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+
+	upTo := time.Now()
+	if reqBody.UpTo != nil {
+		createdAt, err := cfg.dbQueries.GetNotificationCreatedAt(ctx, database.GetNotificationCreatedAtParams{
+			ID:     *reqBody.UpTo,
+			UserID: userID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			api.RespondWithError(w, "Notification not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		upTo = createdAt
+	}
+
+	n, err := cfg.dbQueries.MarkNotificationsRead(ctx, database.MarkNotificationsReadParams{
+		UserID: userID,
+		UpTo:   upTo,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, struct {
+		Marked int64 `json:"marked"`
+	}{Marked: n}, http.StatusOK)
+}
+
+func (cfg *apiConfig) GetUnreadNotificationCount(w http.ResponseWriter, r *http.Request) {
+	count, err := cfg.dbQueries.CountUnreadNotifications(context.Background(), userIDFromContext(r.Context()))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, struct {
+		Unread int64 `json:"unread"`
+	}{Unread: count}, http.StatusOK)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Reads the `limit` and `before` (RFC 3339) query params used by paginated lists
+func parsePage(r *http.Request) (time.Time, int32, error) {
+	limit := defaultPageSize
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return time.Time{}, 0, errors.New("limit must be a positive integer")
+		}
+		limit = min(n, maxPageSize)
+	}
+
+	before := time.Now().Add(time.Minute)
+	if b := r.URL.Query().Get("before"); b != "" {
+		t, err := time.Parse(time.RFC3339Nano, b)
+		if err != nil {
+			return time.Time{}, 0, errors.New("before must be an RFC 3339 timestamp")
+		}
+		before = t
+	}
+	return before, int32(limit), nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Matches the slots in the pinned_chirps table
+const maxPinnedChirps = 3
+
+// Pins one of the caller's own chirps to their profile. Pinning it again is
+// a no-op (200).
+func (cfg *apiConfig) PinChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+
+	chirp, err := cfg.dbQueries.GetChirpByID(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if chirp.UserID != userID {
+		api.RespondWithError(w, "You can only pin your own chirps", http.StatusForbidden)
+		return
+	}
+
+	pinned, err := cfg.dbQueries.GetPinnedChirpIDs(ctx, userID)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if !slices.Contains(pinned, chirpID) {
+		// A concurrent pin can still take the last slot, in which case
+		// nothing is inserted
+		n := int64(0)
+		if len(pinned) < maxPinnedChirps {
+			n, err = cfg.dbQueries.PinChirp(ctx, database.PinChirpParams{UserID: userID, ChirpID: chirpID})
+			if err != nil {
+				api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if n == 0 {
+			api.RespondWithError(w, fmt.Sprintf("You can pin at most %d chirps", maxPinnedChirps), http.StatusConflict)
+			return
+		}
+		status = http.StatusCreated
+	}
+
+	resp, err := cfg.buildChirpResponse(ctx, uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, resp, status)
+}
+
+func (cfg *apiConfig) UnpinChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := cfg.dbQueries.UnpinChirp(context.Background(), database.UnpinChirpParams{
+		UserID:  userIDFromContext(r.Context()),
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		api.RespondWithError(w, "Chirp is not pinned", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// A user's pinned chirps as viewer may see them
+func (cfg *apiConfig) pinnedChirps(ctx context.Context, viewer uuid.NullUUID, userID uuid.UUID) ([]chirpResponse, error) {
+	rows, err := cfg.dbQueries.GetPinnedChirps(ctx, database.GetPinnedChirpsParams{
+		UserID:   userID,
+		ViewerID: viewer,
+	})
+	if err != nil {
+		return nil, err
+	}
+	chirps := make([]database.Chirp, len(rows))
+	for i, row := range rows {
+		chirps[i] = row.Chirp
+	}
+	return cfg.buildChirpResponses(ctx, viewer, chirps)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAtMostThreePins(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.createUser("pinner")
+	for i := range maxPinnedChirps {
+		c := ts.chirp(token, "pin me", nil)
+		ts.must(http.StatusCreated, http.MethodPost, "/api/chirps/"+c.ID.String()+"/pin", token, nil, nil)
+		if i == 0 {
+			// Pinning again is a no-op and takes no slot
+			ts.must(http.StatusOK, http.MethodPost, "/api/chirps/"+c.ID.String()+"/pin", token, nil, nil)
+		}
+	}
+	c := ts.chirp(token, "one too many", nil)
+	if status, body := ts.do(http.MethodPost, "/api/chirps/"+c.ID.String()+"/pin", token, nil); status != http.StatusConflict {
+		t.Errorf("fourth pin: status %d, want 409: %s", status, body)
+	}
+
+	var profile profileResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/users/pinner", "", nil, &profile)
+	if len(profile.PinnedChirps) != maxPinnedChirps {
+		t.Errorf("profile shows %d pins, want %d", len(profile.PinnedChirps), maxPinnedChirps)
+	}
+}
+
+func TestOnlyOwnChirpsCanBePinned(t *testing.T) {
+	ts := newTestServer(t)
+	_, authorToken := ts.createUser("author")
+	_, otherToken := ts.createUser("other")
+	c := ts.chirp(authorToken, "mine", nil)
+	if status, body := ts.do(http.MethodPost, "/api/chirps/"+c.ID.String()+"/pin", otherToken, nil); status != http.StatusForbidden {
+		t.Errorf("pinning someone else's chirp: status %d, want 403: %s", status, body)
+	}
+}
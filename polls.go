@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	minPollOptions   = 2
+	maxPollOptions   = 4
+	maxPollOptionLen = 25
+	minPollDuration  = 5 * time.Minute
+	maxPollDuration  = 7 * 24 * time.Hour
+
+	pollCloserInterval = 30 * time.Second
+)
+
+// Either closes_at or duration_minutes sets when the poll closes.
+// Scheduled chirps only take a duration, counted from publication.
+type pollRequest struct {
+	Options         []string  `json:"options"`
+	ClosesAt        time.Time `json:"closes_at"`
+	DurationMinutes int32     `json:"duration_minutes"`
+}
+
+// Vote counts are left out until the viewer has voted or the poll has
+// closed, so that early results cannot sway anyone
+type pollResponse struct {
+	Options    []pollOptionResponse `json:"options"`
+	ClosesAt   time.Time            `json:"closes_at"`
+	Closed     bool                 `json:"closed"`
+	TotalVotes *int32               `json:"total_votes,omitempty"`
+	MyVote     *int32               `json:"my_vote,omitempty"`
+}
+
+type pollOptionResponse struct {
+	Position int32  `json:"position"`
+	Text     string `json:"text"`
+	Votes    *int32 `json:"votes,omitempty"`
+}
+
+// Trims the options and checks them and the closing time, which is fixed
+// relative to now if only a duration was given
+func (p *pollRequest) validate(now time.Time) error {
+	if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+		return fmt.Errorf("a poll needs %d to %d options", minPollOptions, maxPollOptions)
+	}
+	seen := make(map[string]struct{}, len(p.Options))
+	for i, opt := range p.Options {
+		opt = strings.TrimSpace(opt)
+		if opt == "" || utf8.RuneCountInString(opt) > maxPollOptionLen {
+			return fmt.Errorf("poll options must be 1-%d characters", maxPollOptionLen)
+		}
+		if _, dup := seen[strings.ToLower(opt)]; dup {
+			return fmt.Errorf("duplicate poll option %q", opt)
+		}
+		seen[strings.ToLower(opt)] = struct{}{}
+		p.Options[i] = opt
+	}
+	if p.ClosesAt.IsZero() {
+		p.ClosesAt = now.Add(time.Duration(p.DurationMinutes) * time.Minute)
+	}
+	if d := p.ClosesAt.Sub(now); d < minPollDuration || d > maxPollDuration {
+		return fmt.Errorf("a poll must close between %s and %s from now", minPollDuration, maxPollDuration)
+	}
+	return nil
+}
+
+func createPoll(ctx context.Context, q *database.Queries, chirpID uuid.UUID, p pollRequest) error {
+	err := q.CreatePoll(ctx, database.CreatePollParams{ChirpID: chirpID, ClosesAt: p.ClosesAt.UTC()})
+	if err != nil {
+		return err
+	}
+	for i, opt := range p.Options {
+		err := q.CreatePollOption(ctx, database.CreatePollOptionParams{
+			ChirpID:  chirpID,
+			Position: int32(i),
+			Text:     opt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) attachPolls(ctx context.Context, viewer uuid.NullUUID, resp []chirpResponse) error {
+	ids := make([]uuid.UUID, len(resp))
+	for i := range resp {
+		ids[i] = resp[i].ID
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	polls, err := cfg.dbQueries.GetPollsForChirps(ctx, ids)
+	if err != nil || len(polls) == 0 {
+		return err
+	}
+	pollIDs := make([]uuid.UUID, len(polls))
+	for i, p := range polls {
+		pollIDs[i] = p.ChirpID
+	}
+	options, err := cfg.dbQueries.GetPollOptionsForChirps(ctx, pollIDs)
+	if err != nil {
+		return err
+	}
+	votes := make(map[uuid.UUID]int32)
+	if viewer.Valid {
+		rows, err := cfg.dbQueries.GetPollVotesByUser(ctx, database.GetPollVotesByUserParams{
+			UserID:   viewer.UUID,
+			ChirpIds: pollIDs,
+		})
+		if err != nil {
+			return err
+		}
+		for _, v := range rows {
+			votes[v.ChirpID] = v.Position
+		}
+	}
+
+	byChirp := make(map[uuid.UUID][]database.PollOption, len(polls))
+	for _, o := range options {
+		byChirp[o.ChirpID] = append(byChirp[o.ChirpID], o)
+	}
+	now := time.Now()
+	built := make(map[uuid.UUID]*pollResponse, len(polls))
+	for _, p := range polls {
+		pr := &pollResponse{
+			Options:  []pollOptionResponse{},
+			ClosesAt: p.ClosesAt,
+			Closed:   !now.Before(p.ClosesAt),
+		}
+		if v, ok := votes[p.ChirpID]; ok {
+			pr.MyVote = &v
+		}
+		showResults := pr.Closed || pr.MyVote != nil
+		var total int32
+		for _, o := range byChirp[p.ChirpID] {
+			opt := pollOptionResponse{Position: o.Position, Text: o.Text}
+			if showResults {
+				opt.Votes = &o.VoteCount
+			}
+			total += o.VoteCount
+			pr.Options = append(pr.Options, opt)
+		}
+		if showResults {
+			pr.TotalVotes = &total
+		}
+		built[p.ChirpID] = pr
+	}
+	for i := range resp {
+		resp[i].Poll = built[resp[i].ID]
+	}
+	return nil
+}
+
+// Casts the caller's vote, once, for the option at the given position
+func (cfg *apiConfig) VotePoll(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reqBody := struct {
+		Option *int32 `json:"option"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if reqBody.Option == nil {
+		api.RespondWithError(w, "An option is required", http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+
+	chirp, err := cfg.dbQueries.GetChirpByID(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	visible, err := cfg.canViewChirp(ctx, uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		api.RespondWithError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	blocked, err := hasBlocked(ctx, cfg.dbQueries, chirp.UserID, userID)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		api.RespondWithError(w, "You cannot vote in this poll", http.StatusForbidden)
+		return
+	}
+	poll, err := cfg.dbQueries.GetPoll(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "Chirp has no poll", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	options, err := cfg.dbQueries.GetPollOptionsForChirps(ctx, []uuid.UUID{chirpID})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if *reqBody.Option < 0 || int(*reqBody.Option) >= len(options) {
+		api.RespondWithError(w, "Unknown poll option", http.StatusBadRequest)
+		return
+	}
+
+	n, err := cfg.dbQueries.VotePoll(ctx, database.VotePollParams{
+		ChirpID:  chirpID,
+		UserID:   userID,
+		Position: *reqBody.Option,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		// Either the poll closed, possibly while this request was in flight,
+		// or the caller had already voted
+		if !time.Now().Before(poll.ClosesAt) {
+			api.RespondWithError(w, "Poll is closed", http.StatusConflict)
+			return
+		}
+		api.RespondWithError(w, "You have already voted", http.StatusConflict)
+		return
+	}
+	resp, err := cfg.buildChirpResponse(ctx, uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, resp.Poll, http.StatusCreated)
+}
+
+// Tells authors when their polls have ended
+func (cfg *apiConfig) PollCloser() {
+	ticker := time.NewTicker(pollCloserInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		cfg.closeDuePolls()
+	}
+}
+
+func (cfg *apiConfig) closeDuePolls() {
+	closed, err := cfg.dbQueries.CloseDuePolls(context.Background())
+	if err != nil {
+		log.Println("Error closing polls: ", err)
+		return
+	}
+	for _, p := range closed {
+		cfg.recordNotification(p.UserID, p.UserID, notificationPollClosed, uuid.NullUUID{UUID: p.ChirpID, Valid: true})
+	}
+}
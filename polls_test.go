@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOneVotePerUser(t *testing.T) {
+	ts := newTestServer(t)
+	_, authorToken := ts.createUser("author")
+	_, voterToken := ts.createUser("voter")
+	_, lurkerToken := ts.createUser("lurker")
+	c := ts.chirp(authorToken, "Tabs or spaces?", map[string]any{
+		"poll": map[string]any{"options": []string{"tabs", "spaces"}, "duration_minutes": 10},
+	})
+	path := "/api/chirps/" + c.ID.String() + "/poll/vote"
+
+	var poll pollResponse
+	ts.must(http.StatusCreated, http.MethodPost, path, voterToken, map[string]int{"option": 1}, &poll)
+	if poll.MyVote == nil || *poll.MyVote != 1 || poll.TotalVotes == nil || *poll.TotalVotes != 1 {
+		t.Errorf("poll after voting = %+v", poll)
+	}
+	for _, option := range []int{1, 0} {
+		if status, body := ts.do(http.MethodPost, path, voterToken, map[string]int{"option": option}); status != http.StatusConflict {
+			t.Errorf("voting again for %d: status %d, want 409: %s", option, status, body)
+		}
+	}
+
+	var seen chirpResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/chirps/"+c.ID.String(), lurkerToken, nil, &seen)
+	if seen.Poll == nil || seen.Poll.TotalVotes != nil {
+		t.Errorf("a user who hasn't voted sees counts in %+v", seen.Poll)
+	}
+}
+
+func TestVotingClosesWithThePoll(t *testing.T) {
+	ts := newTestServer(t)
+	_, authorToken := ts.createUser("author")
+	_, voterToken := ts.createUser("voter")
+	c := ts.chirp(authorToken, "Tabs or spaces?", map[string]any{
+		"poll": map[string]any{"options": []string{"tabs", "spaces"}, "duration_minutes": 10},
+	})
+	if _, err := ts.cfg.db.Exec(`UPDATE polls SET closes_at = NOW() - interval '1 minute' WHERE chirp_id = $1`, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	status, body := ts.do(http.MethodPost, "/api/chirps/"+c.ID.String()+"/poll/vote", voterToken, map[string]int{"option": 0})
+	if status != http.StatusConflict {
+		t.Errorf("voting in a closed poll: status %d, want 409: %s", status, body)
+	}
+}
+
+func TestPollClosedNotifiesAuthorOnce(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.createUser("alice")
+	c := ts.chirp(token, "Tabs or spaces?", map[string]any{
+		"poll": map[string]any{"options": []string{"tabs", "spaces"}, "duration_minutes": 10},
+	})
+	if _, err := ts.cfg.db.Exec(`UPDATE polls SET closes_at = closes_at - interval '1 day' WHERE chirp_id = $1`, c.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.cfg.closeDuePolls()
+	ts.cfg.closeDuePolls()
+
+	var groups []notificationGroup
+	ts.must(http.StatusOK, http.MethodGet, "/api/notifications", token, nil, &groups)
+	if len(groups) != 1 {
+		t.Fatalf("got %d notification groups, want 1: %+v", len(groups), groups)
+	}
+	g := groups[0]
+	if g.Type != notificationPollClosed || g.Count != 1 || g.ChirpID == nil || *g.ChirpID != c.ID {
+		t.Errorf("notification = %+v", g)
+	}
+	if g.Summary != notificationNotices[notificationPollClosed] || len(g.Actors) != 0 {
+		t.Errorf("summary %q with actors %+v", g.Summary, g.Actors)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/Lewvy/chirpy/internal/entities"
+	"github.com/google/uuid"
+)
+
+const (
+	maxDisplayName = 50
+	maxBio         = 160
+	maxLocation    = 30
+	maxWebsite     = 100
+)
+
+// The public face of a user. It is built from GetProfile, which never
+// selects email or password columns, so it cannot leak them.
+type profileResponse struct {
+	ID             uuid.UUID       `json:"id"`
+	Handle         string          `json:"handle,omitempty"`
+	DisplayName    string          `json:"display_name"`
+	Bio            string          `json:"bio"`
+	Location       string          `json:"location"`
+	Website        string          `json:"website"`
+	Avatar         *mediaResponse  `json:"avatar"`
+	Banner         *mediaResponse  `json:"banner"`
+	CreatedAt      time.Time       `json:"created_at"`
+	FollowerCount  int64           `json:"follower_count"`
+	FollowingCount int64           `json:"following_count"`
+	ChirpCount     int64           `json:"chirp_count"`
+	PinnedChirps   []chirpResponse `json:"pinned_chirps"`
+}
+
+func (cfg *apiConfig) buildProfileResponse(ctx context.Context, viewer uuid.NullUUID, userID uuid.UUID) (profileResponse, error) {
+	p, err := cfg.dbQueries.GetProfile(ctx, database.GetProfileParams{ID: userID, ViewerID: viewer})
+	if err != nil {
+		return profileResponse{}, err
+	}
+	pinned, err := cfg.pinnedChirps(ctx, viewer, userID)
+	if err != nil {
+		return profileResponse{}, err
+	}
+	resp := profileResponse{
+		ID:             p.ID,
+		Handle:         p.Handle.String,
+		DisplayName:    p.DisplayName,
+		Bio:            p.Bio,
+		Location:       p.Location,
+		Website:        p.Website,
+		CreatedAt:      p.CreatedAt,
+		FollowerCount:  p.FollowerCount,
+		FollowingCount: p.FollowingCount,
+		ChirpCount:     p.ChirpCount,
+		PinnedChirps:   pinned,
+	}
+	var ids []uuid.UUID
+	for _, id := range []uuid.NullUUID{p.AvatarMediaID, p.BannerMediaID} {
+		if id.Valid {
+			ids = append(ids, id.UUID)
+		}
+	}
+	if len(ids) == 0 {
+		return resp, nil
+	}
+	rows, err := cfg.dbQueries.GetMediaByIDs(ctx, ids)
+	if err != nil {
+		return profileResponse{}, err
+	}
+	variants, err := cfg.dbQueries.GetVariantsForMedia(ctx, ids)
+	if err != nil {
+		return profileResponse{}, err
+	}
+	for _, m := range rows {
+		var own []database.MediaVariant
+		for _, v := range variants {
+			if v.MediaID == m.ID {
+				own = append(own, v)
+			}
+		}
+		mr := cfg.newMediaResponse(m, own)
+		if p.AvatarMediaID.Valid && m.ID == p.AvatarMediaID.UUID {
+			resp.Avatar = &mr
+		}
+		if p.BannerMediaID.Valid && m.ID == p.BannerMediaID.UUID {
+			resp.Banner = &mr
+		}
+	}
+	return resp, nil
+}
+
+func (cfg *apiConfig) GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	handle := r.PathValue("handle")
+	if !entities.ValidHandle(handle) {
+		api.RespondWithError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	ctx := context.Background()
+	userID, err := cfg.dbQueries.GetUserIDByHandle(ctx, handle)
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp, err := cfg.buildProfileResponse(ctx, cfg.viewerID(r), userID)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+// Updates only the fields present in the body. An empty string clears a
+// field, including the handle and the avatar and banner media ids.
+func (cfg *apiConfig) UpdateMyProfile(w http.ResponseWriter, r *http.Request) {
+	reqBody := struct {
+		Handle        *string `json:"handle"`
+		DisplayName   *string `json:"display_name"`
+		Bio           *string `json:"bio"`
+		Location      *string `json:"location"`
+		Website       *string `json:"website"`
+		AvatarMediaID *string `json:"avatar_media_id"`
+		BannerMediaID *string `json:"banner_media_id"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromContext(r.Context())
+	ctx := context.Background()
+
+	current, err := cfg.dbQueries.GetProfile(ctx, database.GetProfileParams{
+		ID:       userID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	params := database.UpdateProfileParams{
+		ID:            userID,
+		Handle:        current.Handle,
+		DisplayName:   current.DisplayName,
+		Bio:           current.Bio,
+		Location:      current.Location,
+		Website:       current.Website,
+		AvatarMediaID: current.AvatarMediaID,
+		BannerMediaID: current.BannerMediaID,
+	}
+	if reqBody.Handle != nil {
+		handle := *reqBody.Handle
+		if handle != "" && !entities.ValidHandle(handle) {
+			api.RespondWithError(w, "Handle must be 1-30 letters, digits or underscores", http.StatusBadRequest)
+			return
+		}
+		params.Handle = sql.NullString{String: handle, Valid: handle != ""}
+	}
+	for _, f := range []struct {
+		name  string
+		value *string
+		max   int
+		dst   *string
+	}{
+		{"Display name", reqBody.DisplayName, maxDisplayName, &params.DisplayName},
+		{"Bio", reqBody.Bio, maxBio, &params.Bio},
+		{"Location", reqBody.Location, maxLocation, &params.Location},
+		{"Website", reqBody.Website, maxWebsite, &params.Website},
+	} {
+		if f.value == nil {
+			continue
+		}
+		v := strings.TrimSpace(*f.value)
+		if utf8.RuneCountInString(v) > f.max {
+			api.RespondWithError(w, fmt.Sprintf("%s must be at most %d characters", f.name, f.max), http.StatusBadRequest)
+			return
+		}
+		*f.dst = v
+	}
+	if reqBody.Website != nil && params.Website != "" && !validWebsite(params.Website) {
+		api.RespondWithError(w, "Website must be an http or https URL", http.StatusBadRequest)
+		return
+	}
+	if reqBody.AvatarMediaID != nil {
+		if params.AvatarMediaID, err = cfg.profileMedia(ctx, userID, *reqBody.AvatarMediaID); err != nil {
+			api.RespondWithError(w, "Avatar: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if reqBody.BannerMediaID != nil {
+		if params.BannerMediaID, err = cfg.profileMedia(ctx, userID, *reqBody.BannerMediaID); err != nil {
+			api.RespondWithError(w, "Banner: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := cfg.dbQueries.UpdateProfile(ctx, params); err != nil {
+		if isUniqueViolation(err) {
+			api.RespondWithError(w, "Handle is already taken", http.StatusConflict)
+			return
+		}
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp, err := cfg.buildProfileResponse(ctx, uuid.NullUUID{UUID: userID, Valid: true}, userID)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+func validWebsite(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Resolves a media id from a profile update. Only the caller's own uploads
+// qualify, and not ones that failed to process.
+func (cfg *apiConfig) profileMedia(ctx context.Context, userID uuid.UUID, raw string) (uuid.NullUUID, error) {
+	if raw == "" {
+		return uuid.NullUUID{}, nil
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	m, err := cfg.dbQueries.GetMediaByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && m.UserID != userID) {
+		return uuid.NullUUID{}, fmt.Errorf("unknown media: %s", id)
+	}
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	if m.Status == mediaFailed {
+		return uuid.NullUUID{}, errors.New("media could not be processed")
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestChirpCountOnlyCountsVisibleChirps(t *testing.T) {
+	ts := newTestServer(t)
+	author, authorToken := ts.createUser("author")
+	_, followerToken := ts.createUser("follower")
+	_, strangerToken := ts.createUser("stranger")
+	moderator, modToken := ts.createUser("mod")
+	if _, err := ts.cfg.db.Exec(`UPDATE users SET role = $2 WHERE id = $1`, moderator, roleModerator); err != nil {
+		t.Fatal(err)
+	}
+	ts.must(http.StatusCreated, http.MethodPost, "/api/users/"+author.String()+"/follow", followerToken, nil, nil)
+
+	ts.chirp(authorToken, "for everyone", nil)
+	ts.chirp(authorToken, "for my followers", map[string]any{"visibility": visibilityFollowers})
+	hidden := ts.chirp(authorToken, "soon to be hidden", nil)
+	ts.must(http.StatusOK, http.MethodPost, "/admin/moderation/chirps/"+hidden.ID.String(), modToken,
+		map[string]string{"action": moderationHide}, nil)
+
+	for _, tc := range []struct {
+		who   string
+		token string
+		want  int64
+	}{
+		{"author", authorToken, 3},
+		{"follower", followerToken, 2},
+		{"stranger", strangerToken, 1},
+		{"anonymous", "", 1},
+	} {
+		var profile profileResponse
+		ts.must(http.StatusOK, http.MethodGet, "/api/users/author", tc.token, nil, &profile)
+		if profile.ChirpCount != tc.want {
+			t.Errorf("%s sees chirp_count %d, want %d", tc.who, profile.ChirpCount, tc.want)
+		}
+	}
+}
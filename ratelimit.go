@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/ratelimit"
+)
+
+const (
+	rateLimitChirps        = "chirps"
+	rateLimitLogin         = "login"
+	rateLimitRegister      = "register"
+	rateLimitPasswordReset = "password_reset"
+)
+
+type rateLimitGroup struct {
+	rule ratelimit.Rule
+	key  func(cfg *apiConfig, r *http.Request) string
+}
+
+// Defaults for each route group; RATE_LIMIT_<GROUP>=<limit>/<window>
+// overrides them, e.g. RATE_LIMIT_LOGIN=10/5m
+var defaultRateLimits = map[string]rateLimitGroup{
+	rateLimitChirps:        {rule: ratelimit.Rule{Limit: 30, Window: time.Minute}, key: keyByUser},
+	rateLimitLogin:         {rule: ratelimit.Rule{Limit: 5, Window: time.Minute}, key: keyByIP},
+	rateLimitRegister:      {rule: ratelimit.Rule{Limit: 5, Window: time.Hour}, key: keyByIP},
+	rateLimitPasswordReset: {rule: ratelimit.Rule{Limit: 3, Window: 15 * time.Minute}, key: keyByIP},
+}
+
+func loadRateLimits() (map[string]rateLimitGroup, error) {
+	groups := make(map[string]rateLimitGroup, len(defaultRateLimits))
+	for name, g := range defaultRateLimits {
+		if s := os.Getenv("RATE_LIMIT_" + strings.ToUpper(name)); s != "" {
+			rule, err := ratelimit.ParseRule(s)
+			if err != nil {
+				return nil, fmt.Errorf("RATE_LIMIT_%s: %w", strings.ToUpper(name), err)
+			}
+			g.rule = rule
+		}
+		groups[name] = g
+	}
+	return groups, nil
+}
+
+// The caller's address; X-Forwarded-For is only believed behind a proxy
+// that sets it, as configured by TRUST_PROXY
+func (cfg *apiConfig) clientIP(r *http.Request) string {
+	if cfg.trustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func keyByIP(cfg *apiConfig, r *http.Request) string {
+	return "ip:" + cfg.clientIP(r)
+}
+
+// Signed-in callers share one budget across addresses; anyone else is
+// counted by address
+func keyByUser(cfg *apiConfig, r *http.Request) string {
+	if viewer := cfg.viewerID(r); viewer.Valid {
+		return "user:" + viewer.UUID.String()
+	}
+	return keyByIP(cfg, r)
+}
+
+func (cfg *apiConfig) middlewareRateLimit(group string, next http.HandlerFunc) http.Handler {
+	g, ok := cfg.rateLimits[group]
+	if !ok {
+		panic("unknown rate limit group " + group)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := cfg.limiter.Allow(r.Context(), group+":"+g.key(cfg, r), g.rule, time.Now())
+		if err != nil {
+			// Fail open: an outage of both limiters should not take the API down
+			log.Println("Error checking rate limit: ", err)
+			next(w, r)
+			return
+		}
+		res.SetHeaders(w.Header())
+		if !res.Allowed {
+			api.RespondWithError(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	})
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	scheduledDraft     = "draft"
+	scheduledPending   = "scheduled"
+	scheduledPublished = "published"
+	scheduledFailed    = "failed"
+
+	maxScheduleAhead = 365 * 24 * time.Hour
+
+	schedulerInterval  = 15 * time.Second
+	schedulerBatchSize = 20
+	// Long enough to publish one chirp; a crashed replica's claims are
+	// picked up by another once it lapses
+	schedulerLeaseSeconds = 60
+	schedulerMaxAttempts  = 3
+)
+
+// Returned from inside the chirp's transaction when another replica got
+// there first, so that the duplicate chirp is rolled back
+var errAlreadyPublished = errors.New("scheduled chirp already published")
+
+// A draft or scheduled chirp. publish_at is absent for drafts.
+type scheduledChirpRequest struct {
+	Body      string       `json:"body"`
+	QuoteOfID *uuid.UUID   `json:"quote_of_id"`
+	ReplyToID *uuid.UUID   `json:"reply_to_id"`
+	MediaIDs  []uuid.UUID  `json:"media_ids"`
+	Poll      *pollRequest `json:"poll"`
+	PublishAt *time.Time   `json:"publish_at"`
+	// Defaults to public
+	Visibility string `json:"visibility"`
+}
+
+type scheduledChirpResponse struct {
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	Body       string       `json:"body"`
+	QuoteOfID  *uuid.UUID   `json:"quote_of_id"`
+	ReplyToID  *uuid.UUID   `json:"reply_to_id"`
+	MediaIDs   []uuid.UUID  `json:"media_ids"`
+	Poll       *pollRequest `json:"poll"`
+	PublishAt  *time.Time   `json:"publish_at"`
+	Visibility string       `json:"visibility"`
+	Status     string       `json:"status"`
+	// Set once published, unless the chirp has since been deleted
+	ChirpID   *uuid.UUID `json:"chirp_id"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+func toScheduledChirpResponse(s database.ScheduledChirp) scheduledChirpResponse {
+	resp := scheduledChirpResponse{
+		ID:         s.ID,
+		CreatedAt:  s.CreatedAt,
+		UpdatedAt:  s.UpdatedAt,
+		Body:       s.Body,
+		MediaIDs:   s.MediaIds,
+		Visibility: s.Visibility,
+		Status:     s.Status,
+		LastError:  s.LastError.String,
+	}
+	if resp.MediaIDs == nil {
+		resp.MediaIDs = []uuid.UUID{}
+	}
+	if s.QuoteOfID.Valid {
+		resp.QuoteOfID = &s.QuoteOfID.UUID
+	}
+	if s.ReplyToID.Valid {
+		resp.ReplyToID = &s.ReplyToID.UUID
+	}
+	if len(s.PollOptions) > 0 {
+		resp.Poll = &pollRequest{Options: s.PollOptions, DurationMinutes: s.PollDurationMinutes}
+	}
+	if s.PublishAt.Valid {
+		resp.PublishAt = &s.PublishAt.Time
+	}
+	if s.ChirpID.Valid {
+		resp.ChirpID = &s.ChirpID.UUID
+	}
+	return resp
+}
+
+// Checks what can be checked before publication and turns the request into
+// column values. Quoted and replied-to chirps, blocks and spam are only
+// judged when the chirp is published, as they may change in the meantime.
+func (cfg *apiConfig) scheduledChirpParams(ctx context.Context, userID uuid.UUID, in scheduledChirpRequest) (database.CreateScheduledChirpParams, error) {
+	now := time.Now()
+	params := database.CreateScheduledChirpParams{
+		UserID:     userID,
+		Body:       strings.TrimSpace(in.Body),
+		MediaIds:   in.MediaIDs,
+		Status:     scheduledDraft,
+		Visibility: in.Visibility,
+	}
+	if params.Visibility == "" {
+		params.Visibility = visibilityPublic
+	}
+	if !validVisibility(params.Visibility) {
+		return params, errors.New("Unknown visibility: " + params.Visibility)
+	}
+	if len(params.Body) > maxChirpLength {
+		return params, errors.New("Chirp is too long")
+	}
+	if len(in.MediaIDs) > maxChirpMedia {
+		return params, fmt.Errorf("A chirp can have at most %d attachments", maxChirpMedia)
+	}
+	if len(in.MediaIDs) > 0 {
+		media, err := cfg.dbQueries.GetMediaByIDs(ctx, in.MediaIDs)
+		if err != nil {
+			return params, err
+		}
+		owned := make(map[uuid.UUID]bool, len(media))
+		for _, m := range media {
+			owned[m.ID] = m.UserID == userID && !m.ChirpID.Valid && m.Status != mediaFailed
+		}
+		for _, id := range in.MediaIDs {
+			if !owned[id] {
+				return params, fmt.Errorf("%w: %s", errUnknownMedia, id)
+			}
+		}
+	}
+	if in.Poll != nil {
+		if !in.Poll.ClosesAt.IsZero() {
+			return params, errors.New("Scheduled polls take duration_minutes rather than closes_at")
+		}
+		if err := in.Poll.validate(now); err != nil {
+			return params, err
+		}
+		params.PollOptions = in.Poll.Options
+		params.PollDurationMinutes = in.Poll.DurationMinutes
+	}
+	if in.QuoteOfID != nil {
+		params.QuoteOfID = uuid.NullUUID{UUID: *in.QuoteOfID, Valid: true}
+	}
+	if in.ReplyToID != nil {
+		params.ReplyToID = uuid.NullUUID{UUID: *in.ReplyToID, Valid: true}
+	}
+	if in.PublishAt != nil {
+		if !in.PublishAt.After(now) || in.PublishAt.Sub(now) > maxScheduleAhead {
+			return params, fmt.Errorf("publish_at must be in the future and at most %s away", maxScheduleAhead)
+		}
+		params.PublishAt = sql.NullTime{Time: in.PublishAt.UTC(), Valid: true}
+		params.Status = scheduledPending
+	}
+	return params, nil
+}
+
+// Saves a draft, or schedules a chirp if publish_at is given
+func (cfg *apiConfig) CreateScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	var reqBody scheduledChirpRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	userID := userIDFromContext(r.Context())
+	params, err := cfg.scheduledChirpParams(ctx, userID, reqBody)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s, err := cfg.dbQueries.CreateScheduledChirp(ctx, params)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if s.Status == scheduledPending {
+		nudgeScheduler()
+	}
+	api.RespondWithJSON(w, toScheduledChirpResponse(s), http.StatusCreated)
+}
+
+// Lists the caller's drafts and pending chirps, or those with the statuses
+// given as a comma-separated ?status
+func (cfg *apiConfig) ListScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	statuses := []string{scheduledDraft, scheduledPending}
+	if s := r.URL.Query().Get("status"); s != "" {
+		statuses = strings.Split(s, ",")
+		for _, status := range statuses {
+			switch status {
+			case scheduledDraft, scheduledPending, scheduledPublished, scheduledFailed:
+			default:
+				api.RespondWithError(w, fmt.Sprintf("Unknown status %q", status), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	rows, err := cfg.dbQueries.ListScheduledChirps(context.Background(), database.ListScheduledChirpsParams{
+		UserID:   userIDFromContext(r.Context()),
+		Statuses: statuses,
+		Before:   before,
+		Lim:      limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]scheduledChirpResponse, len(rows))
+	for i, s := range rows {
+		resp[i] = toScheduledChirpResponse(s)
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+// Replaces a draft or pending chirp. Leaving out publish_at turns it back
+// into a draft.
+func (cfg *apiConfig) UpdateScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var reqBody scheduledChirpRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	userID := userIDFromContext(r.Context())
+	params, err := cfg.scheduledChirpParams(ctx, userID, reqBody)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s, err := cfg.dbQueries.UpdateScheduledChirp(ctx, database.UpdateScheduledChirpParams{
+		ID:                  id,
+		UserID:              userID,
+		Body:                params.Body,
+		QuoteOfID:           params.QuoteOfID,
+		ReplyToID:           params.ReplyToID,
+		MediaIds:            params.MediaIds,
+		PollOptions:         params.PollOptions,
+		PollDurationMinutes: params.PollDurationMinutes,
+		PublishAt:           params.PublishAt,
+		Status:              params.Status,
+		Visibility:          params.Visibility,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		cfg.respondScheduledConflict(ctx, w, id, userID)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if s.Status == scheduledPending {
+		nudgeScheduler()
+	}
+	api.RespondWithJSON(w, toScheduledChirpResponse(s), http.StatusOK)
+}
+
+// Cancels a pending chirp or discards a draft
+func (cfg *apiConfig) CancelScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	userID := userIDFromContext(r.Context())
+	n, err := cfg.dbQueries.DeleteScheduledChirp(ctx, database.DeleteScheduledChirpParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		cfg.respondScheduledConflict(ctx, w, id, userID)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Explains why a scheduled chirp could not be changed: it does not exist,
+// it has been published, or a scheduler is publishing it right now
+func (cfg *apiConfig) respondScheduledConflict(ctx context.Context, w http.ResponseWriter, id, userID uuid.UUID) {
+	s, err := cfg.dbQueries.GetScheduledChirp(ctx, database.GetScheduledChirpParams{ID: id, UserID: userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "Scheduled chirp not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if s.Status == scheduledPublished || s.Status == scheduledFailed {
+		api.RespondWithError(w, fmt.Sprintf("Scheduled chirp is already %s", s.Status), http.StatusConflict)
+		return
+	}
+	api.RespondWithError(w, "Scheduled chirp is being published", http.StatusConflict)
+}
+
+// Wakes the scheduler when a chirp is scheduled, as webhookQueue does for
+// deliveries, so that ones due within the interval are not late
+var schedulerQueue = make(chan struct{}, 1)
+
+func nudgeScheduler() {
+	select {
+	case schedulerQueue <- struct{}{}:
+	default:
+	}
+}
+
+// Publishes due chirps. Each is leased with SKIP LOCKED, so replicas never
+// work on the same one at once, and marked published in the chirp's own
+// transaction, so a chirp is never published twice even if a lease lapses
+// mid-publication.
+func (cfg *apiConfig) ChirpScheduler() {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-schedulerQueue:
+		case <-ticker.C:
+		}
+		for {
+			due, err := cfg.dbQueries.ClaimDueScheduledChirps(context.Background(), database.ClaimDueScheduledChirpsParams{
+				LeaseSeconds: schedulerLeaseSeconds,
+				Batch:        schedulerBatchSize,
+			})
+			if err != nil {
+				log.Println("Error claiming scheduled chirps: ", err)
+				break
+			}
+			for _, s := range due {
+				cfg.publishScheduled(s)
+			}
+			if len(due) < schedulerBatchSize {
+				break
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) publishScheduled(s database.ScheduledChirp) {
+	ctx := context.Background()
+	in := newChirp{
+		Body:       s.Body,
+		UserID:     s.UserID,
+		MediaIDs:   s.MediaIds,
+		Visibility: s.Visibility,
+	}
+	if s.QuoteOfID.Valid {
+		in.QuoteOfID = &s.QuoteOfID.UUID
+	}
+	if s.ReplyToID.Valid {
+		in.ReplyToID = &s.ReplyToID.UUID
+	}
+	if len(s.PollOptions) > 0 {
+		in.Poll = &pollRequest{Options: s.PollOptions, DurationMinutes: s.PollDurationMinutes}
+	}
+
+	// Chirps posted directly are refused by middlewareAuth while the author
+	// is suspended. The scheduler has no request to gate, so it checks here.
+	state, err := cfg.dbQueries.GetAccountState(ctx, s.UserID)
+	if err == nil && isSuspended(state.SuspendedUntil) {
+		err = rejectChirp(http.StatusForbidden, suspensionMessage(state.SuspendedUntil, state.SuspensionReason))
+	}
+	if err == nil {
+		_, err = cfg.postChirp(ctx, in, func(q *database.Queries, chirp database.Chirp) error {
+			n, err := q.MarkScheduledChirpPublished(ctx, database.MarkScheduledChirpPublishedParams{
+				ID:      s.ID,
+				ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return errAlreadyPublished
+			}
+			return nil
+		})
+	}
+	if err == nil || errors.Is(err, errAlreadyPublished) {
+		return
+	}
+
+	// A rejected chirp will be rejected again, so it fails at once; anything
+	// else is retried once the lease lapses
+	lastError := sql.NullString{String: err.Error(), Valid: true}
+	var rejection *chirpRejection
+	if errors.As(err, &rejection) || s.Attempts >= schedulerMaxAttempts {
+		err = cfg.dbQueries.MarkScheduledChirpFailed(ctx, database.MarkScheduledChirpFailedParams{
+			ID:        s.ID,
+			LastError: lastError,
+		})
+	} else {
+		log.Println("Error publishing scheduled chirp: ", err)
+		err = cfg.dbQueries.RecordScheduledChirpError(ctx, database.RecordScheduledChirpErrorParams{
+			ID:        s.ID,
+			LastError: lastError,
+		})
+	}
+	if err != nil {
+		log.Println("Error recording scheduled chirp failure: ", err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/database"
+)
+
+// Two replicas claim the same chirp after its lease lapses mid-publication;
+// only one chirp may come of it
+func TestScheduledChirpPublishesExactlyOnce(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.createUser("planner")
+	var draft, scheduled scheduledChirpResponse
+	ts.must(http.StatusCreated, http.MethodPost, "/api/scheduled", token,
+		map[string]any{"body": "just a draft"}, &draft)
+	ts.must(http.StatusCreated, http.MethodPost, "/api/scheduled", token,
+		map[string]any{"body": "right on time", "publish_at": time.Now().Add(time.Minute)}, &scheduled)
+	if draft.Status != scheduledDraft || scheduled.Status != scheduledPending {
+		t.Fatalf("statuses = %q and %q", draft.Status, scheduled.Status)
+	}
+	if _, err := ts.cfg.db.Exec(`UPDATE scheduled_chirps SET publish_at = NOW() - interval '1 second' WHERE id = $1`, scheduled.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	claim := func() []database.ScheduledChirp {
+		due, err := ts.cfg.dbQueries.ClaimDueScheduledChirps(t.Context(), database.ClaimDueScheduledChirpsParams{
+			LeaseSeconds: 0,
+			Batch:        schedulerBatchSize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return due
+	}
+	first, second := claim(), claim()
+	if len(first) != 1 || len(second) != 1 || first[0].ID != scheduled.ID || second[0].ID != scheduled.ID {
+		t.Fatalf("claimed %d then %d chirps, want the scheduled one both times", len(first), len(second))
+	}
+	ts.cfg.publishScheduled(first[0])
+	ts.cfg.publishScheduled(second[0])
+
+	var chirps []chirpResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/chirps", token, nil, &chirps)
+	if len(chirps) != 1 || chirps[0].Body != "right on time" {
+		t.Fatalf("published %d chirps, want exactly one", len(chirps))
+	}
+	var published []scheduledChirpResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/scheduled?status="+scheduledPublished, token, nil, &published)
+	if len(published) != 1 || published[0].ChirpID == nil || *published[0].ChirpID != chirps[0].ID {
+		t.Errorf("published scheduled chirps = %+v", published)
+	}
+	if due := claim(); len(due) != 0 {
+		t.Errorf("%d chirps are still due after publication", len(due))
+	}
+}
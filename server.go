@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
@@ -8,7 +9,13 @@ import (
 	"os"
 	"sync/atomic"
 
+	"github.com/Lewvy/chirpy/internal/activitypub"
 	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/Lewvy/chirpy/internal/events"
+	"github.com/Lewvy/chirpy/internal/media"
+	"github.com/Lewvy/chirpy/internal/ratelimit"
+	"github.com/Lewvy/chirpy/internal/spam"
+	"github.com/Lewvy/chirpy/internal/trending"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"github.com/valkey-io/valkey-go"
@@ -16,8 +23,19 @@ import (
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	db             *sql.DB
 	dbQueries      *database.Queries
 	cache          valkey.Client
+	jwtSecret      string
+	trending       *trending.Tracker
+	events         *events.Broker
+	baseURL        string
+	federation     *activitypub.Federation
+	limiter        ratelimit.Limiter
+	rateLimits     map[string]rateLimitGroup
+	trustProxy     bool
+	spam           *spam.Pipeline
+	blobs          media.BlobStore
 }
 
 func main() {
@@ -47,33 +65,173 @@ func main() {
 
 	cfg := apiConfig{
 		fileserverHits: atomic.Int32{},
+		db:             db,
 		dbQueries:      database.New(db),
 		cache:          valkeyClient,
+		jwtSecret:      os.Getenv("JWT_SECRET"),
+		trending:       trending.New(valkeyClient),
+		events:         events.NewBroker(valkeyClient),
+		baseURL:        os.Getenv("BASE_URL"),
+		trustProxy:     os.Getenv("TRUST_PROXY") == "true",
+		limiter: &ratelimit.Fallback{
+			Primary:   ratelimit.NewValkey(valkeyClient),
+			Secondary: ratelimit.NewMemory(),
+			OnError:   func(err error) { log.Println("Rate limiting in memory: ", err) },
+		},
+	}
+	cfg.rateLimits, err = loadRateLimits()
+	if err != nil {
+		log.Fatalf("Error loading rate limits: %q", err.Error())
+	}
+	cfg.spam, err = newSpamPipeline()
+	if err != nil {
+		log.Fatalf("Error configuring spam checks: %q", err.Error())
+	}
+	cfg.blobs, err = newBlobStore()
+	if err != nil {
+		log.Fatalf("Error initializing media store: %q", err.Error())
+	}
+	if cfg.baseURL == "" {
+		cfg.baseURL = "http://localhost" + serveMux.Addr
+	}
+	cfg.federation, err = activitypub.New(federationStore{q: cfg.dbQueries}, cfg.baseURL)
+	if err != nil {
+		log.Fatalf("Error initializing federation: %q", err.Error())
 	}
 	defer valkeyClient.Close()
 	go cfg.Worker()
+	go cfg.WebhookWorker()
+	go cfg.MediaWorker()
+	for range linkPreviewWorkers {
+		go cfg.LinkPreviewWorker()
+	}
+	go cfg.MediaJanitor()
+	go cfg.PollCloser()
+	go cfg.ChirpScheduler()
+	go cfg.events.Run(context.Background())
+	go cfg.federation.Run(context.Background())
 
+	cfg.routes(mux, filePathRoot)
+
+	log.Printf("Serving files from %s on port %s\n", filePathRoot, serveMux.Addr)
+	log.Fatal(http.ListenAndServe(serveMux.Addr, mux))
+}
+
+// routes mounts every endpoint on mux, serving static files from filePathRoot
+func (cfg *apiConfig) routes(mux *http.ServeMux, filePathRoot string) {
 	handler := http.StripPrefix("/app", http.FileServer(http.Dir(filePathRoot)))
 
 	mux.Handle("/app/", cfg.middlewareMetricsInc(handler))
 	mux.HandleFunc("/app/assets", GetAssets)
 
-	mux.HandleFunc("POST /api/chirps", cfg.PostChirps)
+	mux.Handle("POST /api/chirps", cfg.middlewareRateLimit(rateLimitChirps, cfg.middlewareAuth(cfg.PostChirps).ServeHTTP))
 
-	mux.HandleFunc("POST /api/users/register", cfg.RegisterUser)
-	mux.HandleFunc("POST /api/users/login", cfg.Login)
-	mux.HandleFunc("PATCH /api/users/password-reset", cfg.PasswordReset)
+	mux.Handle("POST /api/users/register", cfg.middlewareRateLimit(rateLimitRegister, cfg.RegisterUser))
+	mux.Handle("POST /api/users/login", cfg.middlewareRateLimit(rateLimitLogin, cfg.Login))
+	mux.Handle("PATCH /api/users/password-reset", cfg.middlewareRateLimit(rateLimitPasswordReset, cfg.PasswordReset))
 
 	mux.HandleFunc("GET /api/chirps", cfg.GetAllChirps)
 	mux.HandleFunc("GET /api/chirps/{id}", cfg.GetChirp)
+	mux.Handle("DELETE /api/chirps/{id}", cfg.middlewareAuth(cfg.DeleteChirp))
+	mux.Handle("POST /api/chirps/{id}/like", cfg.middlewareAuth(cfg.LikeChirp))
+	mux.Handle("DELETE /api/chirps/{id}/like", cfg.middlewareAuth(cfg.UnlikeChirp))
+	mux.Handle("POST /api/chirps/{id}/rechirp", cfg.middlewareAuth(cfg.RechirpChirp))
+	mux.Handle("DELETE /api/chirps/{id}/rechirp", cfg.middlewareAuth(cfg.UndoRechirp))
+	mux.Handle("POST /api/chirps/{id}/poll/vote", cfg.middlewareAuth(cfg.VotePoll))
+	mux.Handle("POST /api/chirps/{id}/bookmark", cfg.middlewareAuth(cfg.BookmarkChirp))
+	mux.Handle("DELETE /api/chirps/{id}/bookmark", cfg.middlewareAuth(cfg.UnbookmarkChirp))
+	mux.Handle("POST /api/chirps/{id}/pin", cfg.middlewareAuth(cfg.PinChirp))
+	mux.Handle("DELETE /api/chirps/{id}/pin", cfg.middlewareAuth(cfg.UnpinChirp))
+
+	mux.Handle("GET /api/users/me/bookmarks", cfg.middlewareAuth(cfg.ListBookmarks))
+	mux.Handle("POST /api/collections", cfg.middlewareAuth(cfg.CreateCollection))
+	mux.Handle("GET /api/collections", cfg.middlewareAuth(cfg.ListCollections))
+	mux.Handle("PATCH /api/collections/{id}", cfg.middlewareAuth(cfg.RenameCollection))
+	mux.Handle("DELETE /api/collections/{id}", cfg.middlewareAuth(cfg.DeleteCollection))
+	mux.Handle("GET /api/collections/{id}/chirps", cfg.middlewareAuth(cfg.GetCollectionChirps))
+	mux.Handle("PUT /api/collections/{id}/chirps/{chirpID}", cfg.middlewareAuth(cfg.AddToCollection))
+	mux.Handle("DELETE /api/collections/{id}/chirps/{chirpID}", cfg.middlewareAuth(cfg.RemoveFromCollection))
+
+	mux.Handle("POST /api/scheduled", cfg.middlewareAuth(cfg.CreateScheduledChirp))
+	mux.Handle("GET /api/scheduled", cfg.middlewareAuth(cfg.ListScheduledChirps))
+	mux.Handle("PUT /api/scheduled/{id}", cfg.middlewareAuth(cfg.UpdateScheduledChirp))
+	mux.Handle("DELETE /api/scheduled/{id}", cfg.middlewareAuth(cfg.CancelScheduledChirp))
+
+	mux.Handle("POST /api/media", cfg.middlewareAuth(cfg.UploadMedia))
+	mux.HandleFunc("GET /media/{id}", cfg.ServeMedia)
+	mux.HandleFunc("GET /media/{id}/{variant}", cfg.ServeMedia)
+
+	mux.Handle("POST /api/chirps/{id}/report", cfg.middlewareAuth(cfg.ReportChirp))
+	mux.Handle("GET /api/reports", cfg.middlewareAuth(cfg.ListMyReports))
+
+	mux.HandleFunc("GET /api/users/{handle}", cfg.GetUserProfile)
+	mux.Handle("PATCH /api/users/me/profile", cfg.middlewareAuth(cfg.UpdateMyProfile))
+
+	mux.Handle("POST /api/users/{id}/follow", cfg.middlewareAuth(cfg.FollowUser))
+	mux.Handle("DELETE /api/users/{id}/follow", cfg.middlewareAuth(cfg.UnfollowUser))
+	mux.Handle("GET /api/timeline", cfg.middlewareAuth(cfg.GetTimeline))
+
+	mux.Handle("POST /api/lists", cfg.middlewareAuth(cfg.CreateList))
+	mux.Handle("GET /api/lists", cfg.middlewareAuth(cfg.ListMyLists))
+	mux.HandleFunc("GET /api/lists/{id}", cfg.GetList)
+	mux.Handle("PATCH /api/lists/{id}", cfg.middlewareAuth(cfg.UpdateList))
+	mux.Handle("DELETE /api/lists/{id}", cfg.middlewareAuth(cfg.DeleteList))
+	mux.HandleFunc("GET /api/lists/{id}/members", cfg.GetListMembers)
+	mux.Handle("PUT /api/lists/{id}/members/{userID}", cfg.middlewareAuth(cfg.AddListMember))
+	mux.Handle("DELETE /api/lists/{id}/members/{userID}", cfg.middlewareAuth(cfg.RemoveListMember))
+	mux.HandleFunc("GET /api/lists/{id}/timeline", cfg.GetListTimeline)
+
+	mux.Handle("POST /api/users/{id}/block", cfg.middlewareAuth(cfg.BlockUser))
+	mux.Handle("DELETE /api/users/{id}/block", cfg.middlewareAuth(cfg.UnblockUser))
+	mux.Handle("POST /api/users/{id}/mute", cfg.middlewareAuth(cfg.MuteUser))
+	mux.Handle("DELETE /api/users/{id}/mute", cfg.middlewareAuth(cfg.UnmuteUser))
+	mux.Handle("GET /api/blocks", cfg.middlewareAuth(cfg.ListBlocks))
+	mux.Handle("GET /api/mutes", cfg.middlewareAuth(cfg.ListMutes))
+
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", cfg.GetHashtagChirps)
+	mux.HandleFunc("GET /api/trending", cfg.GetTrending)
+
+	mux.Handle("GET /api/notifications", cfg.middlewareAuth(cfg.GetNotifications))
+	mux.Handle("POST /api/notifications/read", cfg.middlewareAuth(cfg.MarkNotificationsRead))
+	mux.Handle("GET /api/notifications/unread_count", cfg.middlewareAuth(cfg.GetUnreadNotificationCount))
+
+	mux.Handle("POST /api/conversations", cfg.middlewareAuth(cfg.CreateConversation))
+	mux.Handle("GET /api/conversations", cfg.middlewareAuth(cfg.ListConversations))
+	mux.Handle("GET /api/conversations/{id}/messages", cfg.middlewareAuth(cfg.GetMessages))
+	mux.Handle("POST /api/conversations/{id}/messages", cfg.middlewareAuth(cfg.SendMessage))
+	mux.Handle("POST /api/conversations/{id}/read", cfg.middlewareAuth(cfg.MarkConversationRead))
+
+	mux.HandleFunc("GET /api/stream", cfg.Stream)
+	mux.HandleFunc("GET /api/ws", cfg.WebSocket)
+
+	mux.Handle("POST /api/webhooks", cfg.middlewareAuth(cfg.CreateWebhook))
+	mux.Handle("GET /api/webhooks", cfg.middlewareAuth(cfg.ListWebhooks))
+	mux.Handle("DELETE /api/webhooks/{id}", cfg.middlewareAuth(cfg.DeleteWebhook))
+	mux.Handle("GET /api/webhooks/{id}/deliveries", cfg.middlewareAuth(cfg.ListWebhookDeliveries))
+	mux.Handle("POST /api/webhooks/{id}/deliveries/{deliveryID}/redeliver", cfg.middlewareAuth(cfg.RedeliverWebhook))
+
+	cfg.federation.Register(mux)
+	mux.Handle("POST /api/federation/follow", cfg.middlewareAuth(cfg.FollowRemote))
+	mux.Handle("POST /api/federation/unfollow", cfg.middlewareAuth(cfg.UnfollowRemote))
+	mux.Handle("GET /api/federation/timeline", cfg.middlewareAuth(cfg.GetFederatedTimeline))
+
+	for _, format := range []string{feedAtom, feedRSS, feedJSON} {
+		mux.HandleFunc("GET /users/{id}/feed."+format, cfg.UserFeed(format))
+		mux.HandleFunc("GET /hashtags/{tag}/feed."+format, cfg.HashtagFeed(format))
+	}
 
 	mux.HandleFunc("GET /api/healthz", Readiness)
 
 	mux.HandleFunc("GET /admin/metrics", cfg.Metrics)
 	mux.Handle("POST /admin/reset", cfg.middlewareCheckPlatform(cfg.DeleteAllUsers()))
 
-	mux.Handle("/debug/pprof/", http.DefaultServeMux)
+	mux.Handle("GET /admin/moderation/queue", cfg.middlewareModerator(cfg.GetModerationQueue))
+	mux.Handle("GET /admin/moderation/log", cfg.middlewareModerator(cfg.GetModerationLog))
+	mux.Handle("POST /admin/moderation/chirps/{id}", cfg.middlewareModerator(cfg.ModerateChirp))
+	mux.Handle("GET /admin/moderation/held", cfg.middlewareModerator(cfg.ListHeldChirps))
+	mux.Handle("POST /admin/moderation/held/{id}", cfg.middlewareModerator(cfg.ReviewHeldChirp))
+	mux.Handle("GET /admin/users/{id}/account", cfg.middlewareAdmin(cfg.GetAccountState))
+	mux.Handle("PUT /admin/users/{id}/account", cfg.middlewareAdmin(cfg.SetAccountState))
 
-	log.Printf("Serving files from %s on port %s\n", filePathRoot, serveMux.Addr)
-	log.Fatal(http.ListenAndServe(serveMux.Addr, mux))
+	mux.Handle("/debug/pprof/", http.DefaultServeMux)
 }
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Lewvy/chirpy/internal/activitypub"
+)
+
+// ServeMux panics on conflicting patterns, so mounting every route catches
+// a bad one before the server starts
+func TestRoutesRegister(t *testing.T) {
+	cfg := &apiConfig{rateLimits: defaultRateLimits}
+	fed, err := activitypub.New(federationStore{}, "http://localhost:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.federation = fed
+	cfg.routes(http.NewServeMux(), t.TempDir())
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/Lewvy/chirpy/internal/entities"
+	"github.com/Lewvy/chirpy/internal/spam"
+	"github.com/google/uuid"
+)
+
+const (
+	heldApprove = "approve"
+	heldReject  = "reject"
+
+	// How far back the author's chirps are compared against a new one
+	spamLookback = 24 * time.Hour
+)
+
+// Builds the checks run on every new chirp. SPAM_BLOCKED_DOMAINS is a comma
+// separated blocklist; SPAM_THRESHOLD is the score at which chirps are held,
+// with 0 turning holding off.
+func newSpamPipeline() (*spam.Pipeline, error) {
+	threshold := 1.0
+	if s := os.Getenv("SPAM_THRESHOLD"); s != "" {
+		t, err := strconv.ParseFloat(s, 64)
+		if err != nil || t < 0 {
+			return nil, fmt.Errorf("SPAM_THRESHOLD must be a non-negative number, got %q", s)
+		}
+		threshold = t
+	}
+	return &spam.Pipeline{
+		Checks: []spam.Check{
+			spam.Duplicate{MaxDistance: 10, MinWords: 4, Window: spamLookback},
+			spam.LinkDensity{MaxLinks: 2, MaxRatio: 0.6},
+			spam.Velocity{NewAccountAge: 24 * time.Hour, MaxPosts: 10, Window: time.Hour},
+			spam.NewDomainBlocklist(strings.Split(os.Getenv("SPAM_BLOCKED_DOMAINS"), ",")),
+		},
+		Threshold: threshold,
+	}, nil
+}
+
+func (cfg *apiConfig) scoreChirp(ctx context.Context, userID uuid.UUID, body string) (spam.Verdict, error) {
+	created, err := cfg.dbQueries.GetUserCreatedAt(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Nothing to score against; creating the chirp will fail anyway
+		return spam.Verdict{}, nil
+	}
+	if err != nil {
+		return spam.Verdict{}, err
+	}
+	now := time.Now()
+	rows, err := cfg.dbQueries.GetRecentChirpsByUser(ctx, database.GetRecentChirpsByUserParams{
+		UserID:    userID,
+		CreatedAt: now.Add(-spamLookback),
+	})
+	if err != nil {
+		return spam.Verdict{}, err
+	}
+	recent := make([]spam.Post, len(rows))
+	for i, row := range rows {
+		recent[i] = spam.Post{Body: row.Body, CreatedAt: row.CreatedAt}
+	}
+	return cfg.spam.Evaluate(spam.Input{
+		Body:           body,
+		AccountCreated: created,
+		Recent:         recent,
+		Now:            now,
+	}), nil
+}
+
+// Hides a new chirp and records why it was held, inside the caller's
+// transaction
+func holdChirp(ctx context.Context, q *database.Queries, chirp database.Chirp, verdict spam.Verdict) (database.Chirp, error) {
+	if err := q.HideChirp(ctx, chirp.ID); err != nil {
+		return chirp, err
+	}
+	err := q.CreateSpamHold(ctx, database.CreateSpamHoldParams{
+		ChirpID: chirp.ID,
+		Score:   verdict.Score,
+		Reasons: verdict.Reasons(),
+	})
+	if err != nil {
+		return chirp, err
+	}
+	return q.GetChirpByID(ctx, chirp.ID)
+}
+
+func (cfg *apiConfig) ListHeldChirps(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	held, err := cfg.dbQueries.GetSpamHolds(context.Background(), database.GetSpamHoldsParams{
+		Before: before,
+		Lim:    limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, held, http.StatusOK)
+}
+
+// Approves a held chirp, publishing it as if it had just been posted, or
+// rejects and deletes it. Either way the decision is logged.
+func (cfg *apiConfig) ReviewHeldChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reqBody := struct {
+		Action string `json:"action"`
+		Reason string `json:"reason"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if reqBody.Action != heldApprove && reqBody.Action != heldReject {
+		api.RespondWithError(w, "action must be approve or reject", http.StatusBadRequest)
+		return
+	}
+	moderatorID := userIDFromContext(r.Context())
+	ctx := context.Background()
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	n, err := qtx.DeleteSpamHold(ctx, chirpID)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		api.RespondWithError(w, "No held chirp with that id", http.StatusNotFound)
+		return
+	}
+
+	var chirp database.Chirp
+	var mentioned []uuid.UUID
+	var parent uuid.NullUUID
+	if reqBody.Action == heldReject {
+		chirp, err = qtx.DeleteChirpByID(ctx, chirpID)
+	} else {
+		chirp, mentioned, parent, err = releaseChirp(ctx, qtx, chirpID)
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entry, err := qtx.CreateModerationLogEntry(ctx, database.CreateModerationLogEntryParams{
+		ModeratorID:  moderatorID,
+		Action:       reqBody.Action,
+		ChirpID:      uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		TargetUserID: uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+		Reason:       reqBody.Reason,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if reqBody.Action == heldApprove {
+		subject := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		for _, userID := range mentioned {
+			go cfg.publishNotification(userID, chirp.UserID, notificationMention, subject)
+		}
+		if parent.Valid {
+			go cfg.publishNotification(parent.UUID, chirp.UserID, notificationReply, subject)
+		}
+		resp, err := cfg.buildChirpResponse(ctx, uuid.NullUUID{}, chirp)
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cfg.announceChirp(resp, entities.Unique(entities.Hashtags(chirp.Body)))
+	}
+	api.RespondWithJSON(w, entry, http.StatusOK)
+}
+
+// Unhides an approved chirp and sends the mention and reply notifications
+// that were held back with it. Returns who was notified.
+func releaseChirp(ctx context.Context, q *database.Queries, chirpID uuid.UUID) (database.Chirp, []uuid.UUID, uuid.NullUUID, error) {
+	var parent uuid.NullUUID
+	chirp, err := q.UnhideChirp(ctx, chirpID)
+	if err != nil {
+		return chirp, nil, parent, err
+	}
+	rows, err := q.GetMentionsForChirps(ctx, []uuid.UUID{chirp.ID})
+	if err != nil {
+		return chirp, nil, parent, err
+	}
+	var mentioned []uuid.UUID
+	seen := make(map[uuid.UUID]struct{})
+	for _, row := range rows {
+		if _, ok := seen[row.UserID]; !ok {
+			seen[row.UserID] = struct{}{}
+			mentioned = append(mentioned, row.UserID)
+		}
+	}
+	notified, err := notifyMentioned(ctx, q, chirp, mentioned)
+	if err != nil {
+		return chirp, nil, parent, err
+	}
+	if chirp.ReplyToID.Valid {
+		p, err := q.GetChirpByID(ctx, chirp.ReplyToID.UUID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return chirp, nil, parent, err
+		}
+		if err == nil {
+			created, err := notify(ctx, q, p.UserID, chirp.UserID, notificationReply, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+			if err != nil {
+				return chirp, nil, parent, err
+			}
+			parent = uuid.NullUUID{UUID: p.UserID, Valid: created}
+		}
+	}
+	return chirp, notified, parent, nil
+}
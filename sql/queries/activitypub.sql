@@ -0,0 +1,100 @@
+-- name: GetFederatedUserByHandle :one
+Select users.id, users.handle, ap_keys.public_key_pem, ap_keys.private_key_pem
+from users left join ap_keys on ap_keys.user_id = users.id
+where lower(users.handle) = lower(@handle::text);
+
+-- name: GetFederatedUserByID :one
+Select users.id, users.handle, ap_keys.public_key_pem, ap_keys.private_key_pem
+from users left join ap_keys on ap_keys.user_id = users.id
+where users.id = $1;
+
+-- name: SaveActorKeys :one
+-- Keeps the existing pair if a concurrent request stored one first.
+INSERT INTO ap_keys (user_id, public_key_pem, private_key_pem)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+RETURNING public_key_pem, private_key_pem;
+
+-- name: AddRemoteFollower :exec
+INSERT INTO ap_followers (user_id, actor_id, inbox)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, actor_id) DO UPDATE SET inbox = EXCLUDED.inbox;
+
+-- name: RemoveRemoteFollower :exec
+DELETE FROM ap_followers WHERE user_id = $1 AND actor_id = $2;
+
+-- name: GetRemoteFollowers :many
+Select actor_id, inbox from ap_followers where user_id = $1;
+
+-- name: AddRemoteFollowing :exec
+INSERT INTO ap_following (user_id, actor_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: AcceptRemoteFollowing :execrows
+UPDATE ap_following SET accepted = TRUE WHERE user_id = $1 AND actor_id = $2;
+
+-- name: RemoveRemoteFollowing :exec
+DELETE FROM ap_following WHERE user_id = $1 AND actor_id = $2;
+
+-- name: IsRemoteActorFollowed :one
+Select EXISTS(Select 1 from ap_following where actor_id = $1);
+
+-- name: GetRemoteActor :one
+Select * from ap_remote_actors where id = $1;
+
+-- name: UpsertRemoteActor :exec
+INSERT INTO ap_remote_actors (id, preferred_username, inbox, shared_inbox, key_id, public_key_pem, fetched_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (id) DO UPDATE SET
+    preferred_username = EXCLUDED.preferred_username,
+    inbox = EXCLUDED.inbox,
+    shared_inbox = EXCLUDED.shared_inbox,
+    key_id = EXCLUDED.key_id,
+    public_key_pem = EXCLUDED.public_key_pem,
+    fetched_at = EXCLUDED.fetched_at;
+
+-- name: CreateRemoteNote :exec
+INSERT INTO ap_remote_notes (id, actor_id, content, published)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: DeleteRemoteNote :exec
+DELETE FROM ap_remote_notes WHERE id = $1 AND actor_id = $2;
+
+-- name: GetFederatedTimeline :many
+-- Notes from remote actors that accepted the user's follow, newest first.
+Select ap_remote_notes.*, ap_remote_actors.preferred_username
+from ap_remote_notes
+join ap_following on ap_following.actor_id = ap_remote_notes.actor_id
+join ap_remote_actors on ap_remote_actors.id = ap_remote_notes.actor_id
+where ap_following.user_id = @user_id and ap_following.accepted
+  and ap_remote_notes.published < @before
+order by ap_remote_notes.published desc
+limit @lim;
+
+-- name: QueueRemoteDelivery :exec
+INSERT INTO ap_deliveries (user_id, inbox, body)
+VALUES ($1, $2, $3);
+
+-- name: ClaimDueRemoteDeliveries :many
+-- Leases due deliveries so that concurrent workers never send the same one
+-- twice; an expired lease makes the delivery due again.
+UPDATE ap_deliveries
+SET next_attempt_at = NOW() + make_interval(secs => @lease_seconds::int)
+WHERE id IN (
+    SELECT id FROM ap_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT @batch
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, user_id, inbox, body, attempts;
+
+-- name: DeleteRemoteDelivery :exec
+DELETE FROM ap_deliveries WHERE id = $1;
+
+-- name: MarkRemoteDeliveryFailed :exec
+UPDATE ap_deliveries
+SET status = $2, attempts = attempts + 1, last_error = $3, next_attempt_at = $4
+WHERE id = $1;
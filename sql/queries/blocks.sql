@@ -0,0 +1,47 @@
+-- name: BlockUser :execrows
+INSERT INTO blocks (blocker_id, blocked_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: MuteUser :execrows
+INSERT INTO mutes (muter_id, muted_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :execrows
+DELETE FROM mutes WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: ListBlocks :many
+Select blocked_id, created_at from blocks where blocker_id = $1 order by created_at desc;
+
+-- name: ListMutes :many
+Select muted_id, created_at from mutes where muter_id = $1 order by created_at desc;
+
+-- name: IsBlockedEitherWay :one
+Select EXISTS(
+    Select 1 from blocks
+    where (blocker_id = @a AND blocked_id = @b) OR (blocker_id = @b AND blocked_id = @a)
+);
+
+-- name: GetBlockersAmong :many
+-- Which of user_ids have blocked blocked_id.
+Select blocker_id from blocks
+where blocked_id = @blocked_id AND blocker_id = ANY(@user_ids::uuid[]);
+
+-- name: GetBlockedAmong :many
+-- Which of user_ids blocker_id has blocked.
+Select blocked_id from blocks
+where blocker_id = @blocker_id AND blocked_id = ANY(@user_ids::uuid[]);
+
+-- name: GetHiddenAuthorIDs :many
+-- Authors whose chirps the user never sees: blocked or muted.
+Select blocked_id AS user_id from blocks where blocker_id = $1
+UNION
+Select muted_id from mutes where muter_id = $1;
+
+-- name: RemoveFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = @a AND followee_id = @b) OR (follower_id = @b AND followee_id = @a);
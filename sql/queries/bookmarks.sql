@@ -0,0 +1,76 @@
+-- name: BookmarkChirp :execrows
+INSERT INTO bookmarks (user_id, chirp_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: UnbookmarkChirp :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetBookmarkedChirpIDs :many
+Select chirp_id from bookmarks
+where user_id = @user_id and chirp_id = ANY(@chirp_ids::uuid[]);
+
+-- name: GetBookmarks :many
+-- Bookmarks of chirps that have since been hidden, whose author was shadow
+-- banned, blocked or muted, or that the user can no longer see, are kept
+-- but not listed.
+SELECT sqlc.embed(chirps), bookmarks.created_at AS saved_at
+FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = @user_id AND bookmarks.created_at < @before
+  AND chirp_visible_to(chirps, @user_id)
+  AND NOT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = @user_id AND blocked_id = chirps.user_id)
+  AND NOT EXISTS (SELECT 1 FROM mutes WHERE muter_id = @user_id AND muted_id = chirps.user_id)
+ORDER BY bookmarks.created_at DESC
+LIMIT @lim;
+
+-- name: CreateCollection :one
+INSERT INTO collections (user_id, name)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: ListCollections :many
+SELECT sqlc.embed(collections),
+       (SELECT count(*) FROM collection_chirps WHERE collection_id = collections.id) AS chirp_count
+FROM collections
+WHERE user_id = $1
+ORDER BY name;
+
+-- name: GetCollection :one
+SELECT sqlc.embed(collections),
+       (SELECT count(*) FROM collection_chirps WHERE collection_id = collections.id) AS chirp_count
+FROM collections
+WHERE id = $1 AND user_id = $2;
+
+-- name: RenameCollection :execrows
+UPDATE collections SET name = $3, updated_at = NOW()
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteCollection :execrows
+DELETE FROM collections
+WHERE id = $1 AND user_id = $2;
+
+-- name: AddToCollection :execrows
+-- The chirp must already be bookmarked by the collection's owner.
+INSERT INTO collection_chirps (collection_id, user_id, chirp_id)
+SELECT id, user_id, @chirp_id FROM collections
+WHERE id = @collection_id AND user_id = @user_id
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveFromCollection :execrows
+DELETE FROM collection_chirps
+WHERE collection_id = $1 AND user_id = $2 AND chirp_id = $3;
+
+-- name: GetCollectionChirps :many
+SELECT sqlc.embed(chirps), collection_chirps.created_at AS saved_at
+FROM collection_chirps
+JOIN chirps ON chirps.id = collection_chirps.chirp_id
+WHERE collection_chirps.collection_id = @collection_id
+  AND collection_chirps.user_id = @user_id
+  AND collection_chirps.created_at < @before
+  AND chirp_visible_to(chirps, @user_id)
+  AND NOT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = @user_id AND blocked_id = chirps.user_id)
+  AND NOT EXISTS (SELECT 1 FROM mutes WHERE muter_id = @user_id AND muted_id = chirps.user_id)
+ORDER BY collection_chirps.created_at DESC
+LIMIT @lim;
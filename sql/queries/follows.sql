@@ -0,0 +1,41 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id)
+VALUES (
+    $1, $2
+    )
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetFollowerIDs :many
+Select follower_id from follows where followee_id = $1;
+
+-- name: GetTimeline :many
+-- Chirps by the given authors interleaved with their rechirps, newest
+-- activity first, as the viewer may see them. Unlisted chirps and the
+-- viewer's own rechirps are left out, though the viewer's own unlisted chirps
+-- are not. Backs both the home timeline and list timelines.
+SELECT sqlc.embed(chirps), timeline.rechirped_by, timeline.activity_at
+FROM (
+    SELECT c.id AS chirp_id, NULL::uuid AS rechirped_by, c.created_at AS activity_at
+    FROM chirps c
+    WHERE c.user_id = ANY(@author_ids::uuid[])
+    UNION ALL
+    SELECT r.chirp_id, r.user_id, r.created_at
+    FROM rechirps r
+    WHERE r.user_id = ANY(@author_ids::uuid[]) AND r.user_id IS DISTINCT FROM sqlc.narg('viewer_id')
+) AS timeline
+JOIN chirps ON chirps.id = timeline.chirp_id
+WHERE timeline.activity_at < @before
+  AND chirp_visible_to(chirps, sqlc.narg('viewer_id'))
+  AND (chirps.visibility <> 'unlisted' OR chirps.user_id = sqlc.narg('viewer_id'))
+  AND (timeline.rechirped_by IS NULL OR timeline.rechirped_by NOT IN (SELECT id FROM users WHERE shadow_banned_at IS NOT NULL))
+  AND chirps.user_id NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.narg('viewer_id') UNION SELECT muted_id FROM mutes WHERE muter_id = sqlc.narg('viewer_id'))
+  AND (timeline.rechirped_by IS NULL OR timeline.rechirped_by NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.narg('viewer_id') UNION SELECT muted_id FROM mutes WHERE muter_id = sqlc.narg('viewer_id')))
+ORDER BY timeline.activity_at DESC
+LIMIT @lim;
+
+-- name: GetFolloweeIDs :many
+Select followee_id from follows where follower_id = $1;
@@ -0,0 +1,22 @@
+-- name: UpsertHashtag :one
+INSERT INTO hashtags (tag)
+VALUES ($1)
+ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+RETURNING id;
+
+-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetChirpsByHashtag :many
+SELECT chirps.*
+FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE hashtags.tag = @tag AND chirps.created_at < @before
+  AND chirps.visibility <> 'unlisted' AND chirp_visible_to(chirps, sqlc.narg('viewer_id'))
+  AND NOT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = sqlc.narg('viewer_id') AND blocked_id = chirps.user_id)
+  AND NOT EXISTS (SELECT 1 FROM mutes WHERE muter_id = sqlc.narg('viewer_id') AND muted_id = chirps.user_id)
+ORDER BY chirps.created_at DESC
+LIMIT @lim;
@@ -0,0 +1,49 @@
+-- name: LikeChirp :execrows
+WITH inserted AS (
+    INSERT INTO chirp_likes (user_id, chirp_id)
+    VALUES ($1, $2)
+    ON CONFLICT DO NOTHING
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET like_count = like_count + 1
+WHERE id IN (SELECT chirp_id FROM inserted);
+
+-- name: UnlikeChirp :execrows
+WITH deleted AS (
+    DELETE FROM chirp_likes
+    WHERE user_id = $1 AND chirp_id = $2
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET like_count = like_count - 1
+WHERE id IN (SELECT chirp_id FROM deleted);
+
+-- name: Rechirp :execrows
+WITH inserted AS (
+    INSERT INTO rechirps (user_id, chirp_id)
+    VALUES ($1, $2)
+    ON CONFLICT DO NOTHING
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET rechirp_count = rechirp_count + 1
+WHERE id IN (SELECT chirp_id FROM inserted);
+
+-- name: UndoRechirp :execrows
+WITH deleted AS (
+    DELETE FROM rechirps
+    WHERE user_id = $1 AND chirp_id = $2
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET rechirp_count = rechirp_count - 1
+WHERE id IN (SELECT chirp_id FROM deleted);
+
+-- name: GetLikedChirpIDs :many
+Select chirp_id from chirp_likes
+where user_id = @user_id and chirp_id = ANY(@chirp_ids::uuid[]);
+
+-- name: GetRechirpedChirpIDs :many
+Select chirp_id from rechirps
+where user_id = @user_id and chirp_id = ANY(@chirp_ids::uuid[]);
@@ -0,0 +1,18 @@
+-- name: UpsertLinkPreview :exec
+INSERT INTO link_previews (url, status, title, description, image_url, site_name, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (url) DO UPDATE
+SET fetched_at = NOW(),
+    status = EXCLUDED.status,
+    title = EXCLUDED.title,
+    description = EXCLUDED.description,
+    image_url = EXCLUDED.image_url,
+    site_name = EXCLUDED.site_name,
+    error = EXCLUDED.error;
+
+-- name: GetLinkPreviewFetchedAt :one
+Select fetched_at from link_previews where url = $1;
+
+-- name: GetLinkPreviews :many
+Select * from link_previews
+where url = ANY(@urls::text[]) and status = 'ok';
@@ -0,0 +1,41 @@
+-- name: CreateList :one
+INSERT INTO lists (user_id, name, description, private)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetUserLists :many
+SELECT sqlc.embed(lists),
+       (SELECT count(*) FROM list_members WHERE list_id = lists.id) AS member_count
+FROM lists
+WHERE user_id = $1
+ORDER BY name;
+
+-- name: GetList :one
+SELECT sqlc.embed(lists),
+       (SELECT count(*) FROM list_members WHERE list_id = lists.id) AS member_count
+FROM lists
+WHERE id = $1;
+
+-- name: UpdateList :execrows
+UPDATE lists SET name = $3, description = $4, private = $5, updated_at = NOW()
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteList :execrows
+DELETE FROM lists
+WHERE id = $1 AND user_id = $2;
+
+-- name: AddListMember :execrows
+INSERT INTO list_members (list_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveListMember :execrows
+DELETE FROM list_members
+WHERE list_id = $1 AND user_id = $2;
+
+-- name: GetListMembers :many
+Select user_id, created_at from list_members
+where list_id = $1 order by created_at desc;
+
+-- name: GetListMemberIDs :many
+Select user_id from list_members where list_id = $1;
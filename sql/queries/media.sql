@@ -0,0 +1,76 @@
+-- name: CreateMedia :one
+INSERT INTO media (user_id, storage_key, content_type, size_bytes, width, height, blurhash, alt_text)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: AttachMedia :execrows
+-- Only the uploader can attach, and only media no chirp has claimed yet.
+UPDATE media SET chirp_id = @chirp_id, position = @position
+WHERE id = @id AND user_id = @user_id AND chirp_id IS NULL;
+
+-- name: GetMediaByID :one
+Select * from media where id = $1;
+
+-- name: GetMediaByIDs :many
+Select * from media where id = ANY(@ids::uuid[]);
+
+-- name: GetMediaForChirps :many
+Select * from media
+where chirp_id = ANY(@chirp_ids::uuid[])
+order by chirp_id, position;
+
+-- name: DeleteOrphanedMedia :many
+-- Returns every blob the rows still own: the original until processing
+-- has replaced it, and each variant.
+WITH deleted AS (
+    DELETE FROM media
+    WHERE chirp_id IS NULL AND created_at < $1
+      AND id NOT IN (SELECT avatar_media_id FROM users WHERE avatar_media_id IS NOT NULL
+                     UNION SELECT banner_media_id FROM users WHERE banner_media_id IS NOT NULL)
+      AND NOT EXISTS (SELECT 1 FROM scheduled_chirps s
+                      WHERE s.status IN ('draft', 'scheduled') AND media.id = ANY(s.media_ids))
+    RETURNING id, storage_key, status
+)
+SELECT storage_key FROM deleted WHERE status <> 'ready'
+UNION ALL
+SELECT media_variants.storage_key FROM media_variants
+JOIN deleted ON deleted.id = media_variants.media_id;
+
+-- name: ClaimPendingMedia :many
+-- Leases uploads awaiting processing, like ClaimDueWebhookDeliveries; an
+-- expired lease makes the upload due again.
+UPDATE media
+SET next_attempt_at = NOW() + make_interval(secs => @lease_seconds::int),
+    attempts = attempts + 1
+WHERE id IN (
+    SELECT id FROM media
+    WHERE status = 'processing' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT @batch
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CreateMediaVariant :exec
+INSERT INTO media_variants (media_id, variant, storage_key, content_type, size_bytes, width, height)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (media_id, variant) DO UPDATE
+SET storage_key = EXCLUDED.storage_key,
+    content_type = EXCLUDED.content_type,
+    size_bytes = EXCLUDED.size_bytes,
+    width = EXCLUDED.width,
+    height = EXCLUDED.height;
+
+-- name: MarkMediaReady :exec
+UPDATE media SET status = 'ready' WHERE id = $1;
+
+-- name: MarkMediaFailed :exec
+UPDATE media SET status = 'failed' WHERE id = $1;
+
+-- name: GetMediaVariant :one
+Select * from media_variants where media_id = $1 and variant = $2;
+
+-- name: GetVariantsForMedia :many
+Select * from media_variants
+where media_id = ANY(@media_ids::uuid[])
+order by media_id, width;
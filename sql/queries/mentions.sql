@@ -0,0 +1,10 @@
+-- name: CreateMention :exec
+INSERT INTO mentions (chirp_id, user_id, start_offset, end_offset)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetMentionsForChirps :many
+SELECT mentions.chirp_id, mentions.user_id, mentions.start_offset, mentions.end_offset, users.handle
+FROM mentions
+JOIN users ON users.id = mentions.user_id
+WHERE mentions.chirp_id = ANY(@chirp_ids::uuid[])
+ORDER BY mentions.chirp_id, mentions.start_offset;
@@ -0,0 +1,62 @@
+-- name: CreateConversation :one
+-- Returns the existing thread when a one-to-one conversation already exists.
+INSERT INTO conversations (direct_key)
+VALUES ($1)
+ON CONFLICT (direct_key) DO UPDATE SET direct_key = EXCLUDED.direct_key
+RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetConversationMember :one
+Select * from conversation_members where conversation_id = $1 and user_id = $2;
+
+-- name: GetConversationMembers :many
+Select * from conversation_members
+where conversation_id = ANY(@conversation_ids::uuid[])
+order by joined_at;
+
+-- name: ListConversations :many
+Select conversations.*,
+    (Select count(*) from messages
+     where messages.conversation_id = conversations.id
+       and messages.sender_id <> @user_id
+       and (conversation_members.last_read_at IS NULL OR messages.created_at > conversation_members.last_read_at)
+    ) AS unread_count
+from conversations
+join conversation_members on conversation_members.conversation_id = conversations.id
+where conversation_members.user_id = @user_id and conversations.updated_at < @before
+order by conversations.updated_at desc
+limit @lim;
+
+-- name: GetLatestMessages :many
+Select DISTINCT ON (conversation_id) * from messages
+where conversation_id = ANY(@conversation_ids::uuid[])
+order by conversation_id, created_at desc;
+
+-- name: CreateMessage :one
+INSERT INTO messages (conversation_id, sender_id, body)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET updated_at = $2 WHERE id = $1;
+
+-- name: GetMessages :many
+Select * from messages
+where conversation_id = @conversation_id and created_at < @before
+order by created_at desc
+limit @lim;
+
+-- name: GetMessageCreatedAt :one
+Select created_at from messages where id = $1 and conversation_id = $2;
+
+-- name: MarkConversationRead :exec
+-- Read receipts only move forward.
+UPDATE conversation_members
+SET last_read_at = @read_at::timestamp
+WHERE conversation_id = @conversation_id
+  AND user_id = @user_id
+  AND (last_read_at IS NULL OR last_read_at < @read_at::timestamp);
@@ -0,0 +1,79 @@
+-- name: CreateReport :one
+INSERT INTO reports (chirp_id, reporter_id, category, details)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: GetReportsByReporter :many
+Select * from reports
+where reporter_id = @reporter_id and created_at < @before
+order by created_at desc
+limit @lim;
+
+-- name: GetModerationQueue :many
+-- Open reports grouped per chirp, most reported first.
+SELECT
+    reports.chirp_id,
+    count(*) AS report_count,
+    array_agg(DISTINCT reports.category)::text[] AS categories,
+    min(reports.created_at)::timestamp AS first_reported_at,
+    max(reports.created_at)::timestamp AS last_reported_at,
+    chirps.user_id AS author_id,
+    chirps.body,
+    chirps.hidden_at
+FROM reports
+LEFT JOIN chirps ON chirps.id = reports.chirp_id
+WHERE reports.status = 'open'
+GROUP BY reports.chirp_id, chirps.id
+ORDER BY report_count DESC, first_reported_at
+LIMIT @lim;
+
+-- name: ResolveReports :many
+UPDATE reports
+SET status = 'resolved', resolved_at = NOW(), resolution = @resolution
+WHERE chirp_id = @chirp_id AND status = 'open'
+RETURNING reporter_id;
+
+-- name: HideChirp :exec
+UPDATE chirps SET hidden_at = NOW() WHERE id = $1 AND hidden_at IS NULL;
+
+-- name: DeleteChirpByID :one
+DELETE FROM chirps WHERE id = $1
+RETURNING *;
+
+-- name: SuspendUser :exec
+UPDATE users SET suspended_until = $2, suspension_reason = $3 WHERE id = $1;
+
+-- name: ShadowBanUser :exec
+UPDATE users SET shadow_banned_at = COALESCE(shadow_banned_at, NOW()) WHERE id = $1;
+
+-- name: LiftAccountRestrictions :exec
+UPDATE users
+SET suspended_until = NULL, suspension_reason = NULL, shadow_banned_at = NULL
+WHERE id = $1;
+
+-- name: GetUserRole :one
+Select role from users where id = $1;
+
+-- name: GetAccountState :one
+Select id, role, suspended_until, suspension_reason, shadow_banned_at from users where id = $1;
+
+-- name: GetShadowBannedAmong :many
+Select id from users where id = ANY(@user_ids::uuid[]) and shadow_banned_at IS NOT NULL;
+
+-- name: CreateModerationLogEntry :one
+INSERT INTO moderation_log (moderator_id, action, chirp_id, target_user_id, reason, report_count)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetModerationLog :many
+Select * from moderation_log
+where created_at < @before
+order by created_at desc
+limit @lim;
+
+-- name: GetModerationLogForUser :many
+Select * from moderation_log
+where target_user_id = @target_user_id and created_at < @before
+order by created_at desc
+limit @lim;
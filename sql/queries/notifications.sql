@@ -0,0 +1,61 @@
+-- name: CreateNotification :execrows
+-- Nothing is recorded when the recipient has blocked or muted the actor, or
+-- when the actor is shadow-banned.
+INSERT INTO notifications (user_id, actor_id, type, chirp_id)
+SELECT @user_id::uuid, @actor_id::uuid, @type::text, sqlc.narg('chirp_id')::uuid
+WHERE NOT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = @user_id AND blocked_id = @actor_id)
+  AND NOT EXISTS (SELECT 1 FROM mutes WHERE muter_id = @user_id AND muted_id = @actor_id)
+  AND NOT EXISTS (SELECT 1 FROM users WHERE id = @actor_id AND shadow_banned_at IS NOT NULL);
+
+-- name: CreateSystemNotification :execrows
+-- System notifications skip the block, mute and shadow-ban checks: the
+-- actor is only recorded, never shown.
+INSERT INTO notifications (user_id, actor_id, type, chirp_id, detail, system)
+VALUES ($1, $2, $3, $4, $5, TRUE);
+
+-- name: DeleteNotification :exec
+DELETE FROM notifications
+WHERE user_id = $1 AND actor_id = $2 AND type = $3 AND chirp_id IS NOT DISTINCT FROM $4;
+
+-- name: GetGroupedNotifications :many
+-- Similar notifications (same type, chirp and detail, same day) collapse
+-- into one group; actor_ids holds the three most recent actors.
+SELECT
+    type,
+    chirp_id,
+    detail,
+    (array_agg(id ORDER BY created_at DESC))[1]::uuid AS latest_id,
+    max(created_at)::timestamp AS latest_at,
+    count(*) AS total,
+    count(*) FILTER (WHERE read_at IS NULL) AS unread,
+    (array_agg(actor_id ORDER BY created_at DESC))[1:3]::uuid[] AS actor_ids
+FROM notifications
+WHERE user_id = @user_id
+  AND (@type::text = '' OR type = @type::text)
+  AND (system OR (
+    NOT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = @user_id AND blocked_id = notifications.actor_id)
+    AND NOT EXISTS (SELECT 1 FROM mutes WHERE muter_id = @user_id AND muted_id = notifications.actor_id)
+  ))
+GROUP BY type, chirp_id, detail, date_trunc('day', created_at)
+HAVING max(created_at) < @before
+ORDER BY latest_at DESC
+LIMIT @lim;
+
+-- name: CountUnreadNotifications :one
+SELECT count(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL
+  AND (system OR (
+    NOT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = notifications.actor_id)
+    AND NOT EXISTS (SELECT 1 FROM mutes WHERE muter_id = $1 AND muted_id = notifications.actor_id)
+  ));
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = @user_id
+  AND read_at IS NULL
+  AND created_at <= @up_to;
+
+-- name: GetNotificationCreatedAt :one
+SELECT created_at FROM notifications
+WHERE id = $1 AND user_id = $2;
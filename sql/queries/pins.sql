@@ -0,0 +1,30 @@
+-- name: GetPinnedChirpIDs :many
+Select chirp_id from pinned_chirps where user_id = $1;
+
+-- name: PinChirp :execrows
+-- Takes the lowest free slot. Inserts nothing when all three are taken or
+-- the chirp is already pinned.
+INSERT INTO pinned_chirps (user_id, chirp_id, slot)
+SELECT @user_id, @chirp_id, min(s)
+FROM generate_series(0, 2) AS s
+WHERE s NOT IN (SELECT slot FROM pinned_chirps WHERE user_id = @user_id)
+HAVING min(s) IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pinned_chirps
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetPinnedChirps :many
+-- A user's pins as the viewer may see them, most recently pinned first.
+-- Unlisted chirps stay off the profile for everyone but their author, and
+-- viewers who blocked or muted the user see none.
+SELECT sqlc.embed(chirps)
+FROM pinned_chirps
+JOIN chirps ON chirps.id = pinned_chirps.chirp_id
+WHERE pinned_chirps.user_id = @user_id
+  AND chirp_visible_to(chirps, sqlc.narg('viewer_id'))
+  AND (chirps.visibility <> 'unlisted' OR chirps.user_id = sqlc.narg('viewer_id'))
+  AND NOT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = sqlc.narg('viewer_id') AND blocked_id = chirps.user_id)
+  AND NOT EXISTS (SELECT 1 FROM mutes WHERE muter_id = sqlc.narg('viewer_id') AND muted_id = chirps.user_id)
+ORDER BY pinned_chirps.created_at DESC;
@@ -0,0 +1,46 @@
+-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, closes_at)
+VALUES ($1, $2);
+
+-- name: CreatePollOption :exec
+INSERT INTO poll_options (chirp_id, position, text)
+VALUES ($1, $2, $3);
+
+-- name: GetPoll :one
+Select * from polls where chirp_id = $1;
+
+-- name: GetPollsForChirps :many
+Select * from polls where chirp_id = ANY(@chirp_ids::uuid[]);
+
+-- name: GetPollOptionsForChirps :many
+Select * from poll_options
+where chirp_id = ANY(@chirp_ids::uuid[])
+order by chirp_id, position;
+
+-- name: GetPollVotesByUser :many
+Select chirp_id, position from poll_votes
+where user_id = @user_id and chirp_id = ANY(@chirp_ids::uuid[]);
+
+-- name: VotePoll :execrows
+-- Records the vote and bumps the option's tally in one statement, so
+-- concurrent votes can neither double count nor slip in after closing.
+WITH inserted AS (
+    INSERT INTO poll_votes (chirp_id, user_id, position)
+    SELECT @chirp_id::uuid, @user_id::uuid, @position::int
+    WHERE EXISTS (SELECT 1 FROM polls WHERE polls.chirp_id = @chirp_id AND polls.closes_at > NOW())
+    ON CONFLICT DO NOTHING
+    RETURNING chirp_id, position
+)
+UPDATE poll_options
+SET vote_count = vote_count + 1
+WHERE (chirp_id, position) IN (SELECT chirp_id, position FROM inserted);
+
+-- name: CloseDuePolls :many
+-- Marks ended polls as announced and returns them with their authors. Each
+-- poll is returned once, however many closers are running.
+UPDATE polls
+SET closed_notified_at = NOW()
+FROM chirps
+WHERE chirps.id = polls.chirp_id
+  AND polls.closes_at <= NOW() AND polls.closed_notified_at IS NULL
+RETURNING polls.chirp_id, chirps.user_id;
@@ -0,0 +1,18 @@
+-- name: GetUserIDByHandle :one
+SELECT id FROM users WHERE lower(handle) = lower(@handle::text);
+
+-- name: GetProfile :one
+-- chirp_count only counts the chirps the viewer may read.
+SELECT id, created_at, handle, display_name, bio, location, website, avatar_media_id, banner_media_id,
+    (SELECT count(*) FROM follows WHERE followee_id = users.id) AS follower_count,
+    (SELECT count(*) FROM follows WHERE follower_id = users.id) AS following_count,
+    (SELECT count(*) FROM chirps
+     WHERE chirps.user_id = users.id AND chirp_visible_to(chirps, sqlc.narg('viewer_id'))) AS chirp_count
+FROM users
+WHERE id = @id;
+
+-- name: UpdateProfile :exec
+UPDATE users
+SET handle = $2, display_name = $3, bio = $4, location = $5, website = $6,
+    avatar_media_id = $7, banner_media_id = $8, updated_at = NOW()
+WHERE id = $1;
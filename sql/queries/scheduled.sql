@@ -0,0 +1,63 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (user_id, body, quote_of_id, reply_to_id, media_ids, poll_options, poll_duration_minutes, publish_at, status, visibility)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING *;
+
+-- name: GetScheduledChirp :one
+Select * from scheduled_chirps where id = $1 and user_id = $2;
+
+-- name: ListScheduledChirps :many
+Select * from scheduled_chirps
+where user_id = @user_id and status = ANY(@statuses::text[]) and created_at < @before
+order by created_at desc
+limit @lim;
+
+-- name: UpdateScheduledChirp :one
+-- Only drafts and pending chirps can change, and not while a scheduler is
+-- publishing them.
+UPDATE scheduled_chirps
+SET body = $3, quote_of_id = $4, reply_to_id = $5, media_ids = $6, poll_options = $7,
+    poll_duration_minutes = $8, publish_at = $9, status = $10, visibility = $11, attempts = 0, last_error = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+  AND status IN ('draft', 'scheduled')
+  AND (lease_until IS NULL OR lease_until < NOW())
+RETURNING *;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = $1 AND user_id = $2
+  AND status IN ('draft', 'scheduled', 'failed')
+  AND (lease_until IS NULL OR lease_until < NOW());
+
+-- name: ClaimDueScheduledChirps :many
+-- Leases due chirps the way ClaimDueWebhookDeliveries does, so replicas
+-- never work on the same one at once.
+UPDATE scheduled_chirps
+SET lease_until = NOW() + make_interval(secs => @lease_seconds::int),
+    attempts = attempts + 1
+WHERE id IN (
+    SELECT id FROM scheduled_chirps
+    WHERE status = 'scheduled' AND publish_at <= NOW()
+      AND (lease_until IS NULL OR lease_until < NOW())
+    ORDER BY publish_at
+    LIMIT @batch
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkScheduledChirpPublished :execrows
+-- Runs in the new chirp's transaction. Only the first publisher finds the
+-- row still scheduled; any other must roll its chirp back.
+UPDATE scheduled_chirps
+SET status = 'published', chirp_id = $2, lease_until = NULL, last_error = NULL, updated_at = NOW()
+WHERE id = $1 AND status = 'scheduled';
+
+-- name: MarkScheduledChirpFailed :exec
+UPDATE scheduled_chirps
+SET status = 'failed', lease_until = NULL, last_error = $2, updated_at = NOW()
+WHERE id = $1 AND status = 'scheduled';
+
+-- name: RecordScheduledChirpError :exec
+-- The lease is kept, so the chirp is retried once it lapses.
+UPDATE scheduled_chirps SET last_error = $2 WHERE id = $1;
@@ -0,0 +1,28 @@
+-- name: CreateSpamHold :exec
+INSERT INTO spam_holds (chirp_id, score, reasons)
+VALUES ($1, $2, $3);
+
+-- name: GetSpamHolds :many
+SELECT sqlc.embed(chirps), spam_holds.score, spam_holds.reasons, spam_holds.created_at AS held_at
+FROM spam_holds
+JOIN chirps ON chirps.id = spam_holds.chirp_id
+WHERE spam_holds.created_at < @before
+ORDER BY spam_holds.created_at DESC
+LIMIT @lim;
+
+-- name: DeleteSpamHold :execrows
+DELETE FROM spam_holds WHERE chirp_id = $1;
+
+-- name: UnhideChirp :one
+UPDATE chirps SET hidden_at = NULL WHERE id = $1
+RETURNING *;
+
+-- name: GetRecentChirpsByUser :many
+-- Includes hidden chirps, so a held chirp still counts against its copies.
+Select body, created_at from chirps
+where user_id = $1 and created_at > $2
+order by created_at desc
+limit 50;
+
+-- name: GetUserCreatedAt :one
+Select created_at from users where id = $1;
@@ -1,7 +1,7 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
-  $1, $2, $3, $4, $5
+  $1, $2, $3, $4, $5, $6
   )
 RETURNING *;
 
@@ -9,22 +9,52 @@ RETURNING *;
 TRUNCATE TABLE users RESTART IDENTITY CASCADE;
 
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at,body,  user_id)
+INSERT INTO chirps (id, created_at, updated_at,body,  user_id, quote_of_id, reply_to_id, visibility)
 VALUES (
-    $1, $2, $3, $4, $5
+    $1, $2, $3, $4, $5, $6, $7, $8
     )
 RETURNING *;
 
 -- name: GetAllChirps :many
-Select * from chirps order by created_at;
+-- Leaves out hidden and unlisted chirps, chirps the viewer may not see,
+-- authors the viewer has blocked or muted, and shadow-banned authors other
+-- than the viewer.
+Select * from chirps
+WHERE visibility <> 'unlisted' AND chirp_visible_to(chirps, sqlc.narg('viewer_id'))
+  AND NOT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = sqlc.narg('viewer_id') AND blocked_id = chirps.user_id)
+  AND NOT EXISTS (SELECT 1 FROM mutes WHERE muter_id = sqlc.narg('viewer_id') AND muted_id = chirps.user_id)
+order by created_at;
 
 -- name: GetChirpByID :one
 Select * from chirps where id = $1;
 
+-- name: GetChirpsByIDs :many
+Select * from chirps where id = ANY(@ids::uuid[]);
+
+-- name: GetVisibleChirpIDs :many
+Select id from chirps
+where id = ANY(@ids::uuid[]) and chirp_visible_to(chirps, sqlc.narg('viewer_id'));
+
 -- name: GetUserByEmail :one
-Select id, hashed_password, email, created_at, updated_at from users where email = $1;
+Select id, hashed_password, email, created_at, updated_at, suspended_until, suspension_reason from users where email = $1;
 
 -- name: UpdateUserPw :exec
 Update users
 set hashed_password = $1
 where email = $2;
+
+-- name: GetUsersByHandles :many
+Select id, handle from users where lower(handle) = ANY(@handles::text[]);
+
+-- name: GetUsersByIDs :many
+Select id, handle from users where id = ANY(@ids::uuid[]);
+
+-- name: DeleteChirp :one
+DELETE FROM chirps WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: GetChirpsByUser :many
+-- Feeds and federation have no viewer, so only public chirps are listed.
+Select * from chirps
+where user_id = $1 and visibility = 'public' and chirp_visible_to(chirps, NULL)
+order by created_at desc limit $2;
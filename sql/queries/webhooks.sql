@@ -0,0 +1,77 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (user_id, url, secret, events)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: ListWebhooks :many
+Select * from webhooks where user_id = $1 order by created_at;
+
+-- name: GetWebhook :one
+Select * from webhooks where id = $1 and user_id = $2;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1 AND user_id = $2;
+
+-- name: GetActiveWebhooksForEvent :many
+Select id from webhooks where active and @event_type::text = ANY(events);
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+VALUES ($1, $2, $3, $4)
+RETURNING id;
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Leases due deliveries so that concurrent dispatchers never send the same
+-- one twice; an expired lease makes the delivery due again.
+UPDATE webhook_deliveries
+SET next_attempt_at = NOW() + make_interval(secs => @lease_seconds::int)
+FROM webhooks
+WHERE webhooks.id = webhook_deliveries.webhook_id
+  AND webhook_deliveries.id IN (
+    SELECT d.id FROM webhook_deliveries d
+    JOIN webhooks w ON w.id = d.webhook_id
+    WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND w.active
+    ORDER BY d.next_attempt_at
+    LIMIT @batch
+    FOR UPDATE OF d SKIP LOCKED
+  )
+RETURNING webhook_deliveries.id, webhook_deliveries.webhook_id, webhook_deliveries.event_id,
+    webhook_deliveries.event_type, webhook_deliveries.payload, webhook_deliveries.attempts,
+    webhooks.url, webhooks.secret;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = NOW()
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = $2, attempts = attempts + 1, last_status_code = $3, last_error = $4, next_attempt_at = $5
+WHERE id = $1;
+
+-- name: ResetWebhookFailures :exec
+UPDATE webhooks SET failure_count = 0 WHERE id = $1;
+
+-- name: IncrementWebhookFailures :one
+UPDATE webhooks SET failure_count = failure_count + 1
+WHERE id = $1
+RETURNING failure_count;
+
+-- name: DisableWebhook :exec
+UPDATE webhooks SET active = FALSE, disabled_at = NOW() WHERE id = $1;
+
+-- name: EnableWebhook :exec
+UPDATE webhooks SET active = TRUE, disabled_at = NULL, failure_count = 0 WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+Select * from webhook_deliveries
+where webhook_id = @webhook_id and created_at < @before
+order by created_at desc
+limit @lim;
+
+-- name: RedeliverWebhookDelivery :one
+INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+SELECT webhook_id, event_id, event_type, payload
+FROM webhook_deliveries
+WHERE webhook_deliveries.id = $1 AND webhook_deliveries.webhook_id = $2
+RETURNING *;
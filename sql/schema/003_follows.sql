@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE follows (
+    follower_id uuid NOT NULL,
+    followee_id uuid NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    FOREIGN KEY(follower_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(followee_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX follows_followee_idx ON follows(followee_id);
+
+-- +goose Down
+DROP TABLE follows;
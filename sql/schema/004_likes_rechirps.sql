@@ -0,0 +1,40 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN rechirp_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE chirp_likes (
+    user_id uuid NOT NULL,
+    chirp_id uuid NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, chirp_id),
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(chirp_id)
+        REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE rechirps (
+    user_id uuid NOT NULL,
+    chirp_id uuid NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, chirp_id),
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(chirp_id)
+        REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_likes_chirp_idx ON chirp_likes(chirp_id);
+CREATE INDEX rechirps_user_created_idx ON rechirps(user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE rechirps;
+DROP TABLE chirp_likes;
+ALTER TABLE chirps
+    DROP COLUMN rechirp_count,
+    DROP COLUMN like_count;
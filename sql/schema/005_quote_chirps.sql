@@ -0,0 +1,10 @@
+-- +goose Up
+-- No foreign key: a quote keeps pointing at its source after deletion so the
+-- API can render a tombstone instead of silently dropping the quote.
+ALTER TABLE chirps ADD COLUMN quote_of_id uuid;
+
+CREATE INDEX chirps_quote_of_idx ON chirps(quote_of_id);
+
+-- +goose Down
+DROP INDEX chirps_quote_of_idx;
+ALTER TABLE chirps DROP COLUMN quote_of_id;
@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE hashtags (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    tag text NOT NULL UNIQUE
+);
+
+CREATE TABLE chirp_hashtags (
+    chirp_id uuid NOT NULL,
+    hashtag_id uuid NOT NULL,
+    PRIMARY KEY (chirp_id, hashtag_id),
+    FOREIGN KEY(chirp_id)
+        REFERENCES chirps(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(hashtag_id)
+        REFERENCES hashtags(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_hashtags_hashtag_idx ON chirp_hashtags(hashtag_id);
+
+-- +goose Down
+DROP TABLE chirp_hashtags;
+DROP TABLE hashtags;
@@ -0,0 +1,45 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN handle varchar(30);
+CREATE UNIQUE INDEX users_handle_key ON users (lower(handle));
+
+CREATE TABLE mentions (
+    chirp_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    PRIMARY KEY (chirp_id, start_offset),
+    FOREIGN KEY(chirp_id)
+        REFERENCES chirps(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX mentions_user_idx ON mentions(user_id);
+
+CREATE TABLE notifications (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id uuid NOT NULL,
+    actor_id uuid NOT NULL,
+    type text NOT NULL,
+    chirp_id uuid,
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(actor_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(chirp_id)
+        REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_user_created_idx ON notifications(user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE notifications;
+DROP TABLE mentions;
+DROP INDEX users_handle_key;
+ALTER TABLE users DROP COLUMN handle;
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE notifications ADD COLUMN read_at TIMESTAMP;
+CREATE INDEX notifications_unread_idx ON notifications(user_id) WHERE read_at IS NULL;
+
+ALTER TABLE chirps ADD COLUMN reply_to_id uuid
+    REFERENCES chirps(id)
+    ON DELETE SET NULL;
+CREATE INDEX chirps_reply_to_idx ON chirps(reply_to_id);
+
+-- +goose Down
+DROP INDEX chirps_reply_to_idx;
+ALTER TABLE chirps DROP COLUMN reply_to_id;
+DROP INDEX notifications_unread_idx;
+ALTER TABLE notifications DROP COLUMN read_at;
@@ -0,0 +1,63 @@
+-- +goose Up
+CREATE TABLE ap_keys (
+    user_id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    public_key_pem text NOT NULL,
+    private_key_pem text NOT NULL,
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE ap_remote_actors (
+    id text PRIMARY KEY,
+    preferred_username text NOT NULL,
+    inbox text NOT NULL,
+    shared_inbox text,
+    key_id text NOT NULL,
+    public_key_pem text NOT NULL,
+    fetched_at TIMESTAMP NOT NULL
+);
+
+-- Remote actors following local users
+CREATE TABLE ap_followers (
+    user_id uuid NOT NULL,
+    actor_id text NOT NULL,
+    inbox text NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, actor_id),
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+-- Local users following remote actors
+CREATE TABLE ap_following (
+    user_id uuid NOT NULL,
+    actor_id text NOT NULL,
+    accepted BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, actor_id),
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX ap_following_actor_idx ON ap_following(actor_id);
+
+CREATE TABLE ap_remote_notes (
+    id text PRIMARY KEY,
+    actor_id text NOT NULL,
+    content text NOT NULL,
+    published TIMESTAMP NOT NULL,
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX ap_remote_notes_actor_idx ON ap_remote_notes(actor_id, published DESC);
+
+-- +goose Down
+DROP TABLE ap_remote_notes;
+DROP TABLE ap_following;
+DROP TABLE ap_followers;
+DROP TABLE ap_remote_actors;
+DROP TABLE ap_keys;
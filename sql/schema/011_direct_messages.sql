@@ -0,0 +1,46 @@
+-- +goose Up
+CREATE TABLE conversations (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- "<lower uuid>:<higher uuid>" for one-to-one conversations so each pair
+    -- of users shares a single thread; NULL for groups
+    direct_key text UNIQUE
+);
+
+CREATE TABLE conversation_members (
+    conversation_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_read_at TIMESTAMP,
+    PRIMARY KEY (conversation_id, user_id),
+    FOREIGN KEY(conversation_id)
+        REFERENCES conversations(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX conversation_members_user_idx ON conversation_members(user_id);
+
+CREATE TABLE messages (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    conversation_id uuid NOT NULL,
+    sender_id uuid NOT NULL,
+    body text NOT NULL,
+    FOREIGN KEY(conversation_id)
+        REFERENCES conversations(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(sender_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX messages_conversation_idx ON messages(conversation_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE messages;
+DROP TABLE conversation_members;
+DROP TABLE conversations;
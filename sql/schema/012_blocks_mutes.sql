@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE blocks (
+    blocker_id uuid NOT NULL,
+    blocked_id uuid NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    FOREIGN KEY(blocker_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(blocked_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX blocks_blocked_idx ON blocks(blocked_id);
+
+CREATE TABLE mutes (
+    muter_id uuid NOT NULL,
+    muted_id uuid NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (muter_id, muted_id),
+    FOREIGN KEY(muter_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(muted_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    CHECK (muter_id <> muted_id)
+);
+
+-- +goose Down
+DROP TABLE mutes;
+DROP TABLE blocks;
@@ -0,0 +1,48 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN role text NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP;
+
+-- Hidden chirps stay in the database but drop out of every listing
+ALTER TABLE chirps ADD COLUMN hidden_at TIMESTAMP;
+
+-- chirp_id has no foreign key so reports outlive a deleted chirp
+CREATE TABLE reports (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    chirp_id uuid NOT NULL,
+    reporter_id uuid NOT NULL,
+    category text NOT NULL,
+    details text,
+    status text NOT NULL DEFAULT 'open',
+    resolved_at TIMESTAMP,
+    resolution text,
+    UNIQUE (chirp_id, reporter_id),
+    FOREIGN KEY(reporter_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX reports_open_idx ON reports(chirp_id) WHERE status = 'open';
+
+-- Append-only record of moderator decisions; no foreign keys so entries
+-- survive the users and chirps they refer to
+CREATE TABLE moderation_log (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    moderator_id uuid NOT NULL,
+    action text NOT NULL,
+    chirp_id uuid,
+    target_user_id uuid,
+    reason text NOT NULL DEFAULT '',
+    report_count INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX moderation_log_created_idx ON moderation_log(created_at DESC);
+
+-- +goose Down
+DROP TABLE moderation_log;
+DROP TABLE reports;
+ALTER TABLE chirps DROP COLUMN hidden_at;
+ALTER TABLE users DROP COLUMN suspended_until;
+ALTER TABLE users DROP COLUMN role;
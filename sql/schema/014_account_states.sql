@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN suspension_reason text;
+-- Shadow-banned users keep posting, but only they can see their chirps
+ALTER TABLE users ADD COLUMN shadow_banned_at TIMESTAMP;
+
+CREATE INDEX moderation_log_target_idx ON moderation_log(target_user_id, created_at DESC);
+
+-- +goose Down
+DROP INDEX moderation_log_target_idx;
+ALTER TABLE users DROP COLUMN shadow_banned_at;
+ALTER TABLE users DROP COLUMN suspension_reason;
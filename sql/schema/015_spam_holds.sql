@@ -0,0 +1,17 @@
+-- +goose Up
+-- Chirps the spam checks held back; the chirp itself is stored hidden until
+-- a moderator approves or rejects it
+CREATE TABLE spam_holds (
+    chirp_id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    score double precision NOT NULL,
+    reasons text[] NOT NULL,
+    FOREIGN KEY(chirp_id)
+        REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX spam_holds_created_idx ON spam_holds(created_at DESC);
+
+-- +goose Down
+DROP TABLE spam_holds;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- Uploads start unattached. chirp_id is set when a chirp claims them and
+-- cleared again if the chirp is deleted, leaving the blob for the janitor.
+CREATE TABLE media (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id uuid NOT NULL,
+    chirp_id uuid,
+    position INTEGER NOT NULL DEFAULT 0,
+    storage_key text NOT NULL,
+    content_type text NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    blurhash text NOT NULL,
+    alt_text text NOT NULL DEFAULT '',
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(chirp_id)
+        REFERENCES chirps(id)
+        ON DELETE SET NULL
+);
+
+CREATE INDEX media_chirp_idx ON media(chirp_id, position);
+CREATE INDEX media_orphan_idx ON media(created_at) WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP TABLE media;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- Uploads are processed in the background. The original, which may still
+-- carry EXIF data, is never served and is deleted once the media is ready,
+-- leaving storage_key pointing at nothing.
+ALTER TABLE media ADD COLUMN status text NOT NULL DEFAULT 'processing'
+    CHECK (status IN ('processing', 'ready', 'failed'));
+ALTER TABLE media ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE media ADD COLUMN next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX media_pending_idx ON media(next_attempt_at) WHERE status = 'processing';
+
+CREATE TABLE media_variants (
+    media_id uuid NOT NULL,
+    variant text NOT NULL,
+    storage_key text NOT NULL,
+    content_type text NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    PRIMARY KEY(media_id, variant),
+    FOREIGN KEY(media_id)
+        REFERENCES media(id)
+        ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE media_variants;
+DROP INDEX media_pending_idx;
+ALTER TABLE media DROP COLUMN next_attempt_at;
+ALTER TABLE media DROP COLUMN attempts;
+ALTER TABLE media DROP COLUMN status;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN display_name text NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN bio text NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN location text NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN website text NOT NULL DEFAULT '';
+-- Uploaded through /api/media like chirp attachments; the janitor leaves
+-- media a profile points at alone
+ALTER TABLE users ADD COLUMN avatar_media_id uuid REFERENCES media(id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN banner_media_id uuid REFERENCES media(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN banner_media_id;
+ALTER TABLE users DROP COLUMN avatar_media_id;
+ALTER TABLE users DROP COLUMN website;
+ALTER TABLE users DROP COLUMN location;
+ALTER TABLE users DROP COLUMN bio;
+ALTER TABLE users DROP COLUMN display_name;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- One row per URL, shared by every chirp that links to it. Failed fetches
+-- are kept too, so a broken link is not retried on every post.
+CREATE TABLE link_previews (
+    url text PRIMARY KEY,
+    fetched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    status text NOT NULL CHECK (status IN ('ok', 'failed')),
+    title text NOT NULL DEFAULT '',
+    description text NOT NULL DEFAULT '',
+    image_url text NOT NULL DEFAULT '',
+    site_name text NOT NULL DEFAULT '',
+    error text
+);
+
+-- +goose Down
+DROP TABLE link_previews;
@@ -0,0 +1,44 @@
+-- +goose Up
+CREATE TABLE polls (
+    chirp_id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closes_at TIMESTAMP NOT NULL,
+    -- Set once the author has been told the poll ended
+    closed_notified_at TIMESTAMP,
+    FOREIGN KEY(chirp_id)
+        REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX polls_closing_idx ON polls(closes_at) WHERE closed_notified_at IS NULL;
+
+CREATE TABLE poll_options (
+    chirp_id uuid NOT NULL,
+    position INTEGER NOT NULL,
+    text text NOT NULL,
+    vote_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY(chirp_id, position),
+    FOREIGN KEY(chirp_id)
+        REFERENCES polls(chirp_id)
+        ON DELETE CASCADE
+);
+
+-- The primary key is what makes a vote once-only
+CREATE TABLE poll_votes (
+    chirp_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(chirp_id, user_id),
+    FOREIGN KEY(chirp_id, position)
+        REFERENCES poll_options(chirp_id, position)
+        ON DELETE CASCADE,
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
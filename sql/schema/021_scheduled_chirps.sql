@@ -0,0 +1,37 @@
+-- +goose Up
+-- Drafts and chirps waiting for publish_at. Nothing here is checked against
+-- other tables until publication, when the chirp goes through the same
+-- validation as one posted directly.
+CREATE TABLE scheduled_chirps (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id uuid NOT NULL,
+    body text NOT NULL,
+    quote_of_id uuid,
+    reply_to_id uuid,
+    media_ids uuid[] NOT NULL DEFAULT '{}',
+    poll_options text[] NOT NULL DEFAULT '{}',
+    poll_duration_minutes INTEGER NOT NULL DEFAULT 0,
+    -- NULL for drafts
+    publish_at TIMESTAMP,
+    status text NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'scheduled', 'published', 'failed')),
+    -- Held by a scheduler while it publishes; edits wait until it lapses
+    lease_until TIMESTAMP,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    chirp_id uuid,
+    last_error text,
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(chirp_id)
+        REFERENCES chirps(id)
+        ON DELETE SET NULL
+);
+
+CREATE INDEX scheduled_chirps_due_idx ON scheduled_chirps(publish_at) WHERE status = 'scheduled';
+CREATE INDEX scheduled_chirps_user_idx ON scheduled_chirps(user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE scheduled_chirps;
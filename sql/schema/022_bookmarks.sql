@@ -0,0 +1,51 @@
+-- +goose Up
+CREATE TABLE bookmarks (
+    user_id uuid NOT NULL,
+    chirp_id uuid NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, chirp_id),
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(chirp_id)
+        REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX bookmarks_user_created_idx ON bookmarks(user_id, created_at DESC);
+
+CREATE TABLE collections (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    UNIQUE(user_id, name),
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+-- Entries reference the owner's bookmark, so removing the bookmark, or
+-- deleting the chirp, takes the chirp out of every collection too
+CREATE TABLE collection_chirps (
+    collection_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    chirp_id uuid NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (collection_id, chirp_id),
+    FOREIGN KEY(collection_id)
+        REFERENCES collections(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(user_id, chirp_id)
+        REFERENCES bookmarks(user_id, chirp_id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX collection_chirps_bookmark_idx ON collection_chirps(user_id, chirp_id);
+CREATE INDEX collection_chirps_created_idx ON collection_chirps(collection_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE collection_chirps;
+DROP TABLE collections;
+DROP TABLE bookmarks;
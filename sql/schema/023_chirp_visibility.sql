@@ -0,0 +1,29 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN visibility text NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'followers', 'unlisted', 'mentioned'));
+ALTER TABLE scheduled_chirps ADD COLUMN visibility text NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'followers', 'unlisted', 'mentioned'));
+
+-- The one definition of who may see a chirp given its visibility. Authors,
+-- mentioned users and the author of the chirp being replied to always can;
+-- followers-only chirps also reach followers. Unlisted chirps are visible
+-- to anyone, and it is up to lists to leave them out. viewer is NULL for
+-- anonymous requests.
+-- +goose StatementBegin
+CREATE FUNCTION chirp_visible_to(c chirps, viewer uuid) RETURNS boolean
+LANGUAGE sql STABLE AS $$
+    SELECT COALESCE(
+        c.visibility IN ('public', 'unlisted')
+        OR c.user_id = viewer
+        OR (c.visibility = 'followers'
+            AND EXISTS (SELECT 1 FROM follows WHERE follower_id = viewer AND followee_id = c.user_id))
+        OR EXISTS (SELECT 1 FROM mentions WHERE chirp_id = c.id AND user_id = viewer)
+        OR EXISTS (SELECT 1 FROM chirps p WHERE p.id = c.reply_to_id AND p.user_id = viewer),
+        false)
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION chirp_visible_to(chirps, uuid);
+ALTER TABLE scheduled_chirps DROP COLUMN visibility;
+ALTER TABLE chirps DROP COLUMN visibility;
@@ -0,0 +1,51 @@
+-- +goose Up
+-- Each user has three pin slots. Taking the lowest free slot on insert is
+-- what enforces the limit, even under concurrent pins.
+CREATE TABLE pinned_chirps (
+    user_id uuid NOT NULL,
+    chirp_id uuid NOT NULL,
+    slot smallint NOT NULL CHECK (slot BETWEEN 0 AND 2),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, slot),
+    UNIQUE(user_id, chirp_id),
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(chirp_id)
+        REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE lists (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    private boolean NOT NULL DEFAULT false,
+    UNIQUE(user_id, name),
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE list_members (
+    list_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (list_id, user_id),
+    FOREIGN KEY(list_id)
+        REFERENCES lists(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX list_members_user_idx ON list_members(user_id);
+
+-- +goose Down
+DROP TABLE list_members;
+DROP TABLE lists;
+DROP TABLE pinned_chirps;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Outgoing activities waiting to be posted to remote inboxes. The body is
+-- stored as sent, since it is signed byte for byte.
+CREATE TABLE ap_deliveries (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id uuid NOT NULL,
+    inbox text NOT NULL,
+    body bytea NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error text,
+    FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX ap_deliveries_due_idx ON ap_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE ap_deliveries;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- System notifications (reviewed reports, ended polls) come from the service
+-- rather than from another user, so blocks and mutes never hold them back.
+-- detail carries what the notice is about, such as a report's resolution.
+ALTER TABLE notifications ADD COLUMN system BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE notifications ADD COLUMN detail text;
+
+-- +goose Down
+ALTER TABLE notifications DROP COLUMN detail;
+ALTER TABLE notifications DROP COLUMN system;
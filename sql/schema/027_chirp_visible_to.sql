@@ -0,0 +1,34 @@
+-- +goose Up
+-- chirp_visible_to also covers moderation now, so every query shares one
+-- predicate: a chirp hidden by a moderator, or by a shadow-banned author,
+-- is visible to its author alone.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION chirp_visible_to(c chirps, viewer uuid) RETURNS boolean
+LANGUAGE sql STABLE AS $$
+    SELECT COALESCE(
+        c.user_id = viewer
+        OR (c.hidden_at IS NULL
+            AND NOT EXISTS (SELECT 1 FROM users WHERE id = c.user_id AND shadow_banned_at IS NOT NULL)
+            AND (c.visibility IN ('public', 'unlisted')
+                OR (c.visibility = 'followers'
+                    AND EXISTS (SELECT 1 FROM follows WHERE follower_id = viewer AND followee_id = c.user_id))
+                OR EXISTS (SELECT 1 FROM mentions WHERE chirp_id = c.id AND user_id = viewer)
+                OR EXISTS (SELECT 1 FROM chirps p WHERE p.id = c.reply_to_id AND p.user_id = viewer))),
+        false)
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION chirp_visible_to(c chirps, viewer uuid) RETURNS boolean
+LANGUAGE sql STABLE AS $$
+    SELECT COALESCE(
+        c.visibility IN ('public', 'unlisted')
+        OR c.user_id = viewer
+        OR (c.visibility = 'followers'
+            AND EXISTS (SELECT 1 FROM follows WHERE follower_id = viewer AND followee_id = c.user_id))
+        OR EXISTS (SELECT 1 FROM mentions WHERE chirp_id = c.id AND user_id = viewer)
+        OR EXISTS (SELECT 1 FROM chirps p WHERE p.id = c.reply_to_id AND p.user_id = viewer),
+        false)
+$$;
+-- +goose StatementEnd
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/auth"
+	"github.com/Lewvy/chirpy/internal/events"
+	"github.com/google/uuid"
+)
+
+const (
+	streamChirps        = "chirps"
+	streamTimeline      = "timeline"
+	streamNotifications = "notifications"
+
+	eventChirpCreated = "chirp.created"
+	eventNotification = "notification"
+	// Sent when events the client missed are gone from the stream
+	eventReset = "reset"
+
+	heartbeatInterval = 15 * time.Second
+)
+
+type notificationEvent struct {
+	Type    string     `json:"type"`
+	ActorID uuid.UUID  `json:"actor_id"`
+	ChirpID *uuid.UUID `json:"chirp_id,omitempty"`
+}
+
+func (cfg *apiConfig) publishChirp(chirp chirpResponse) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := cfg.events.Publish(ctx, events.TopicChirps, eventChirpCreated, chirp); err != nil {
+		log.Println("Error publishing chirp: ", err)
+	}
+}
+
+func (cfg *apiConfig) publishNotification(userID, actorID uuid.UUID, kind string, chirpID uuid.NullUUID) {
+	if _, notice := notificationNotices[kind]; userID == actorID && !notice {
+		return
+	}
+	ev := notificationEvent{Type: kind, ActorID: actorID}
+	if chirpID.Valid {
+		ev.ChirpID = &chirpID.UUID
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := cfg.events.Publish(ctx, events.NotificationsTopic(userID.String()), eventNotification, ev); err != nil {
+		log.Println("Error publishing notification: ", err)
+	}
+}
+
+// EventSource cannot set headers, so the stream also accepts ?access_token=
+func (cfg *apiConfig) streamViewer(r *http.Request) uuid.NullUUID {
+	if viewer := cfg.viewerID(r); viewer.Valid {
+		return viewer
+	}
+	token := r.URL.Query().Get("access_token")
+	if token == "" {
+		return uuid.NullUUID{}
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+// Server-Sent Events feed of new chirps and, for authenticated clients, their
+// timeline and notifications. Select feeds with ?streams=chirps,timeline.
+func (cfg *apiConfig) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		api.RespondWithError(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	wanted := map[string]bool{}
+	for _, s := range strings.Split(r.URL.Query().Get("streams"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			wanted[s] = true
+		}
+	}
+	if len(wanted) == 0 {
+		wanted[streamChirps] = true
+	}
+	for s := range wanted {
+		if s != streamChirps && s != streamTimeline && s != streamNotifications {
+			api.RespondWithError(w, "Unknown stream: "+s, http.StatusBadRequest)
+			return
+		}
+	}
+
+	viewer := cfg.streamViewer(r)
+	if (wanted[streamTimeline] || wanted[streamNotifications]) && !viewer.Valid {
+		api.RespondWithError(w, "Timeline and notification streams require a token", http.StatusUnauthorized)
+		return
+	}
+
+	var topics []string
+	if wanted[streamChirps] || wanted[streamTimeline] {
+		topics = append(topics, events.TopicChirps)
+	}
+	if wanted[streamNotifications] {
+		topics = append(topics, events.NotificationsTopic(viewer.UUID.String()))
+	}
+
+	var followees map[uuid.UUID]struct{}
+	if wanted[streamTimeline] {
+		ids, err := cfg.dbQueries.GetFolloweeIDs(context.Background(), viewer.UUID)
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		followees = toSet(append(ids, viewer.UUID))
+	}
+
+	hidden, err := cfg.hiddenAuthors(context.Background(), viewer)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Topics the client has no position on start from the newest event, so
+	// that a catch-up after a lag covers them as well as the others
+	cursor := events.ParseCursor(r.Header.Get("Last-Event-ID"))
+	for _, topic := range topics {
+		if _, ok := cursor[topic]; ok {
+			continue
+		}
+		last, err := cfg.events.LastID(r.Context(), topic)
+		if err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cursor[topic] = last
+	}
+
+	// Subscribe before replaying so nothing published in between is lost;
+	// the cursor drops anything delivered twice.
+	sub := cfg.events.Subscribe(topics...)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(ev events.Event) error {
+		if !cursor.Advance(ev) {
+			return nil
+		}
+		name := ev.Type
+		if ev.Topic == events.TopicChirps {
+			var author struct {
+				UserID     uuid.UUID `json:"user_id"`
+				Visibility string    `json:"visibility"`
+			}
+			json.Unmarshal(ev.Data, &author)
+			if _, ok := hidden[author.UserID]; ok {
+				return nil
+			}
+			if !cfg.canViewChirpEvent(viewer, ev.Data) {
+				return nil
+			}
+			_, inTimeline := followees[author.UserID]
+			// The chirps stream is a public list, so unlisted chirps only
+			// show up in timelines
+			switch {
+			case wanted[streamChirps] && author.Visibility != visibilityUnlisted:
+			case inTimeline:
+				name = streamTimeline
+			default:
+				return nil
+			}
+		}
+		_, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", cursor, name, ev.Data)
+		return err
+	}
+	// When a topic was trimmed past the cursor the client is told to reset,
+	// reloading what it shows, and the topic resumes from its newest event
+	catchUp := func() error {
+		for _, topic := range topics {
+			missed, err := cfg.events.Replay(r.Context(), topic, cursor[topic])
+			if errors.Is(err, events.ErrTrimmed) {
+				if cursor[topic], err = cfg.events.LastID(r.Context(), topic); err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: {}\n\n", cursor, eventReset); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			for _, ev := range missed {
+				if err := send(ev); err != nil {
+					return err
+				}
+			}
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	if err := catchUp(); err != nil {
+		log.Println("Error replaying events: ", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case ev := <-sub.C:
+			if err := send(ev); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-sub.Lagged:
+			// Events were dropped for this slow client; the stream still has them
+			if err := catchUp(); err != nil {
+				log.Println("Error replaying events: ", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lewvy/chirpy/internal/activitypub"
+	"github.com/Lewvy/chirpy/internal/auth"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/Lewvy/chirpy/internal/events"
+	"github.com/Lewvy/chirpy/internal/media"
+	"github.com/Lewvy/chirpy/internal/ratelimit"
+	"github.com/Lewvy/chirpy/internal/trending"
+	"github.com/google/uuid"
+	"github.com/valkey-io/valkey-go"
+)
+
+// Handler tests run the real routes against Postgres and Valkey, and are
+// skipped unless CHIRPY_TEST_DB_URL (a postgres:// URL) and
+// CHIRPY_TEST_VALKEY_ADDR are set. Every test server gets its own schema
+// with all migrations applied, so tests never see each other's rows.
+
+const testJWTSecret = "test-secret"
+
+type testServer struct {
+	t   *testing.T
+	cfg *apiConfig
+	srv *httptest.Server
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	dbURL, cacheAddr := os.Getenv("CHIRPY_TEST_DB_URL"), os.Getenv("CHIRPY_TEST_VALKEY_ADDR")
+	if dbURL == "" || cacheAddr == "" {
+		t.Skip("CHIRPY_TEST_DB_URL and CHIRPY_TEST_VALKEY_ADDR are not set")
+	}
+	db := openTestSchema(t, dbURL)
+	cache, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{cacheAddr}})
+	if err != nil {
+		t.Fatalf("connecting to valkey: %v", err)
+	}
+	t.Cleanup(cache.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	cfg := &apiConfig{
+		db:         db,
+		dbQueries:  database.New(db),
+		cache:      cache,
+		jwtSecret:  testJWTSecret,
+		trending:   trending.New(cache),
+		events:     events.NewBroker(cache),
+		baseURL:    srv.URL,
+		limiter:    ratelimit.NewMemory(),
+		rateLimits: defaultRateLimits,
+	}
+	if cfg.spam, err = newSpamPipeline(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.blobs, err = media.NewFileStore(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.federation, err = activitypub.New(federationStore{q: cfg.dbQueries}, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	// Test servers listen on loopback, which the default client refuses
+	cfg.federation.Client = &http.Client{Timeout: 5 * time.Second}
+	cfg.federation.Backoff = func(int) time.Duration { return 10 * time.Millisecond }
+	go cfg.events.Run(ctx)
+	go cfg.federation.Run(ctx)
+	cfg.routes(mux, t.TempDir())
+	return &testServer{t: t, cfg: cfg, srv: srv}
+}
+
+// openTestSchema creates a fresh schema, migrates it and returns a pool
+// whose connections use it
+func openTestSchema(t *testing.T, dbURL string) *sql.DB {
+	t.Helper()
+	admin, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("creating schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		t.Fatalf("CHIRPY_TEST_DB_URL: %v", err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema+",public")
+	u.RawQuery = q.Encode()
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob("sql/schema/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(migrationUp(string(src))); err != nil {
+			t.Fatalf("applying %s: %v", file, err)
+		}
+	}
+	return db
+}
+
+// migrationUp extracts the statements of a goose migration's Up section
+func migrationUp(src string) string {
+	_, up, _ := strings.Cut(src, "-- +goose Up")
+	up, _, _ = strings.Cut(up, "-- +goose Down")
+	var b strings.Builder
+	for _, line := range strings.Split(up, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "-- +goose") {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// createUser inserts a user with the given handle and returns its id and an
+// access token
+func (ts *testServer) createUser(handle string) (uuid.UUID, string) {
+	ts.t.Helper()
+	hash, err := auth.HashPassword("password")
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	now := time.Now()
+	u, err := ts.cfg.dbQueries.CreateUser(context.Background(), database.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          handle + "@example.com",
+		HashedPassword: *hash,
+		Handle:         sql.NullString{String: handle, Valid: true},
+	})
+	if err != nil {
+		ts.t.Fatalf("creating user: %v", err)
+	}
+	token, err := auth.MakeJWT(u.ID, testJWTSecret, time.Hour)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	return u.ID, token
+}
+
+// do sends body as JSON with the token, if any, and returns the response
+// status with its body
+func (ts *testServer) do(method, path, token string, body any) (int, []byte) {
+	ts.t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			ts.t.Fatal(err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, ts.srv.URL+path, r)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	return resp.StatusCode, b
+}
+
+// must is do for requests expected to answer want; it decodes the response
+// into out unless out is nil
+func (ts *testServer) must(want int, method, path, token string, body, out any) {
+	ts.t.Helper()
+	status, b := ts.do(method, path, token, body)
+	if status != want {
+		ts.t.Fatalf("%s %s: status %d, want %d: %s", method, path, status, want, b)
+	}
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			ts.t.Fatalf("%s %s: decoding %s: %v", method, path, b, err)
+		}
+	}
+}
+
+// chirp posts a chirp and returns it; fields are merged into the request
+func (ts *testServer) chirp(token, body string, fields map[string]any) chirpResponse {
+	ts.t.Helper()
+	req := map[string]any{"body": body}
+	for k, v := range fields {
+		req[k] = v
+	}
+	var c chirpResponse
+	ts.must(http.StatusOK, http.MethodPost, "/api/chirps", token, req, &c)
+	return c
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Who a chirp is for. Unlisted chirps can be read by anyone with the link
+// but are left out of public lists; the rest are enforced by the
+// chirp_visible_to SQL function, which every list query applies.
+const (
+	visibilityPublic    = "public"
+	visibilityFollowers = "followers"
+	visibilityUnlisted  = "unlisted"
+	visibilityMentioned = "mentioned"
+)
+
+func validVisibility(v string) bool {
+	switch v {
+	case visibilityPublic, visibilityFollowers, visibilityUnlisted, visibilityMentioned:
+		return true
+	}
+	return false
+}
+
+// Whether viewer may read the chirp at all. Every read of a single chirp
+// goes through here. Authors always see their own chirps; for everyone else
+// chirp_visible_to also rules out chirps hidden by a moderator or by a
+// shadow-banned author.
+func (cfg *apiConfig) canViewChirp(ctx context.Context, viewer uuid.NullUUID, chirp database.Chirp) (bool, error) {
+	if viewer.Valid && viewer.UUID == chirp.UserID {
+		return true, nil
+	}
+	visible, err := cfg.visibleChirpIDs(ctx, viewer, []uuid.UUID{chirp.ID})
+	_, ok := visible[chirp.ID]
+	return ok, err
+}
+
+// The subset of ids whose visibility lets viewer see them
+func (cfg *apiConfig) visibleChirpIDs(ctx context.Context, viewer uuid.NullUUID, ids []uuid.UUID) (map[uuid.UUID]struct{}, error) {
+	visible, err := cfg.dbQueries.GetVisibleChirpIDs(ctx, database.GetVisibleChirpIDsParams{
+		Ids:      ids,
+		ViewerID: viewer,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toSet(visible), nil
+}
+
+// Whether a chirp event from the chirps topic may be relayed to viewer.
+// Only chirps that are neither hidden nor shadow banned are announced, so
+// public and unlisted ones need no lookup.
+func (cfg *apiConfig) canViewChirpEvent(viewer uuid.NullUUID, data []byte) bool {
+	var chirp database.Chirp
+	if err := json.Unmarshal(data, &chirp); err != nil {
+		return false
+	}
+	if chirp.Visibility == visibilityPublic || chirp.Visibility == visibilityUnlisted {
+		return true
+	}
+	ok, err := cfg.canViewChirp(context.Background(), viewer, chirp)
+	if err != nil {
+		log.Println("Error checking chirp visibility: ", err)
+	}
+	return ok
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Lewvy/chirpy/internal/database"
+)
+
+func TestFollowersOnlyChirpsNeedAFollow(t *testing.T) {
+	ts := newTestServer(t)
+	author, authorToken := ts.createUser("author")
+	_, followerToken := ts.createUser("follower")
+	_, strangerToken := ts.createUser("stranger")
+	ts.must(http.StatusCreated, http.MethodPost, "/api/users/"+author.String()+"/follow", followerToken, nil, nil)
+	c := ts.chirp(authorToken, "for my followers", map[string]any{"visibility": visibilityFollowers})
+	path := "/api/chirps/" + c.ID.String()
+
+	for _, tc := range []struct {
+		who   string
+		token string
+		want  int
+	}{
+		{"author", authorToken, http.StatusOK},
+		{"follower", followerToken, http.StatusOK},
+		{"stranger", strangerToken, http.StatusNotFound},
+		{"anonymous", "", http.StatusNotFound},
+	} {
+		if status, body := ts.do(http.MethodGet, path, tc.token, nil); status != tc.want {
+			t.Errorf("%s: status %d, want %d: %s", tc.who, status, tc.want, body)
+		}
+	}
+
+	var chirps []chirpResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/chirps", strangerToken, nil, &chirps)
+	for _, got := range chirps {
+		if got.ID == c.ID {
+			t.Errorf("stranger's GET /api/chirps lists the followers-only chirp")
+		}
+	}
+}
+
+func TestHiddenChirpsAreOnlyVisibleToTheirAuthor(t *testing.T) {
+	ts := newTestServer(t)
+	_, authorToken := ts.createUser("author")
+	_, readerToken := ts.createUser("reader")
+	moderator, modToken := ts.createUser("mod")
+	if _, err := ts.cfg.db.Exec(`UPDATE users SET role = $2 WHERE id = $1`, moderator, roleModerator); err != nil {
+		t.Fatal(err)
+	}
+	c := ts.chirp(authorToken, "soon to be hidden", nil)
+	ts.must(http.StatusOK, http.MethodPost, "/admin/moderation/chirps/"+c.ID.String(), modToken,
+		map[string]string{"action": moderationHide}, nil)
+
+	path := "/api/chirps/" + c.ID.String()
+	ts.must(http.StatusOK, http.MethodGet, path, authorToken, nil, nil)
+	if status, body := ts.do(http.MethodGet, path, readerToken, nil); status != http.StatusNotFound {
+		t.Errorf("reader: status %d, want 404: %s", status, body)
+	}
+}
+
+func TestListTimelineLeavesOutUnlisted(t *testing.T) {
+	ts := newTestServer(t)
+	author, authorToken := ts.createUser("author")
+	_, ownerToken := ts.createUser("owner")
+	var list listResponse
+	ts.must(http.StatusCreated, http.MethodPost, "/api/lists", ownerToken, map[string]any{"name": "friends"}, &list)
+	ts.must(http.StatusCreated, http.MethodPut, "/api/lists/"+list.ID.String()+"/members/"+author.String(), ownerToken, nil, nil)
+
+	public := ts.chirp(authorToken, "for everyone", nil)
+	ts.chirp(authorToken, "only by link", map[string]any{"visibility": visibilityUnlisted})
+
+	var timeline []chirpResponse
+	ts.must(http.StatusOK, http.MethodGet, "/api/lists/"+list.ID.String()+"/timeline", ownerToken, nil, &timeline)
+	if len(timeline) != 1 || timeline[0].ID != public.ID {
+		t.Errorf("list timeline has %d chirps, want only the public one", len(timeline))
+	}
+}
+
+func TestMediaFollowsChirpVisibility(t *testing.T) {
+	ts := newTestServer(t)
+	author, authorToken := ts.createUser("author")
+	_, strangerToken := ts.createUser("stranger")
+	c := ts.chirp(authorToken, "for my followers", map[string]any{"visibility": visibilityFollowers})
+
+	ctx := t.Context()
+	data := []byte("not really a png")
+	if err := ts.cfg.blobs.Put(ctx, "media/test.png", data, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ts.cfg.dbQueries.CreateMedia(ctx, database.CreateMediaParams{
+		UserID:      author,
+		StorageKey:  "media/test.png",
+		ContentType: "image/png",
+		SizeBytes:   int64(len(data)),
+		Width:       1,
+		Height:      1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.cfg.db.Exec(`UPDATE media SET chirp_id = $2, status = 'ready' WHERE id = $1`, m.ID, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	err = ts.cfg.dbQueries.CreateMediaVariant(ctx, database.CreateMediaVariantParams{
+		MediaID:     m.ID,
+		Variant:     "full",
+		StorageKey:  "media/test.png",
+		ContentType: "image/png",
+		SizeBytes:   int64(len(data)),
+		Width:       1,
+		Height:      1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/media/" + m.ID.String()
+	ts.must(http.StatusOK, http.MethodGet, path, authorToken, nil, nil)
+	for who, token := range map[string]string{"stranger": strangerToken, "anonymous": ""} {
+		if status, body := ts.do(http.MethodGet, path, token, nil); status != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404: %s", who, status, body)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/database"
+	"github.com/Lewvy/chirpy/internal/linkpreview"
+	"github.com/Lewvy/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookChirpCreated   = "chirp.created"
+	webhookChirpDeleted   = "chirp.deleted"
+	webhookUserRegistered = "user.registered"
+
+	webhookTimeout      = 10 * time.Second
+	webhookPollInterval = 5 * time.Second
+	webhookBatchSize    = 20
+	// Long enough to cover a delivery attempt; a crashed dispatcher's claims
+	// become due again once it lapses.
+	webhookLeaseSeconds = 60
+)
+
+var webhookEventTypes = []string{webhookChirpCreated, webhookChirpDeleted, webhookUserRegistered}
+
+// Events about other users' accounts; only admins may subscribe to them
+var adminWebhookEventTypes = []string{webhookUserRegistered}
+
+// Nudges the dispatcher when new deliveries are queued, so it does not have
+// to wait for the next poll. Deliveries themselves live in the database.
+var webhookQueue = make(chan struct{}, 1)
+
+type webhookEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type webhookResponse struct {
+	ID           uuid.UUID  `json:"id"`
+	URL          string     `json:"url"`
+	Events       []string   `json:"events"`
+	Active       bool       `json:"active"`
+	FailureCount int32      `json:"failure_count"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DisabledAt   *time.Time `json:"disabled_at,omitempty"`
+	// Only returned when the webhook is created
+	Secret string `json:"secret,omitempty"`
+}
+
+func toWebhookResponse(hook database.Webhook) webhookResponse {
+	resp := webhookResponse{
+		ID:           hook.ID,
+		URL:          hook.Url,
+		Events:       hook.Events,
+		Active:       hook.Active,
+		FailureCount: hook.FailureCount,
+		CreatedAt:    hook.CreatedAt,
+	}
+	if hook.DisabledAt.Valid {
+		resp.DisabledAt = &hook.DisabledAt.Time
+	}
+	return resp
+}
+
+// Queues a delivery of the event to every active webhook subscribed to it
+func (cfg *apiConfig) emitWebhookEvent(eventType string, data any) {
+	ctx := context.Background()
+	hooks, err := cfg.dbQueries.GetActiveWebhooksForEvent(ctx, eventType)
+	if err != nil {
+		log.Println("Error loading webhooks: ", err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	ev := webhookEvent{ID: uuid.New(), Type: eventType, CreatedAt: time.Now().UTC(), Data: data}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		log.Println("Error encoding webhook event: ", err)
+		return
+	}
+	for _, hookID := range hooks {
+		_, err := cfg.dbQueries.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			WebhookID: hookID,
+			EventID:   ev.ID,
+			EventType: eventType,
+			Payload:   payload,
+		})
+		if err != nil {
+			log.Println("Error queueing webhook delivery: ", err)
+		}
+	}
+	nudgeWebhookDispatcher()
+}
+
+func nudgeWebhookDispatcher() {
+	select {
+	case webhookQueue <- struct{}{}:
+	default:
+	}
+}
+
+func (cfg *apiConfig) WebhookWorker() {
+	// Receivers are user-supplied URLs, so the transport refuses to reach
+	// internal addresses. Redirects are not followed: a 3xx is a failure.
+	transport := linkpreview.NewTransport()
+	transport.ResponseHeaderTimeout = webhookTimeout
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   webhookTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-webhookQueue:
+		case <-ticker.C:
+		}
+		for {
+			due, err := cfg.dbQueries.ClaimDueWebhookDeliveries(context.Background(), database.ClaimDueWebhookDeliveriesParams{
+				LeaseSeconds: webhookLeaseSeconds,
+				Batch:        webhookBatchSize,
+			})
+			if err != nil {
+				log.Println("Error claiming webhook deliveries: ", err)
+				break
+			}
+			for _, d := range due {
+				cfg.deliverWebhook(client, d)
+			}
+			if len(due) < webhookBatchSize {
+				break
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) deliverWebhook(client *http.Client, d database.ClaimDueWebhookDeliveriesRow) {
+	ctx := context.Background()
+	statusCode, err := postWebhook(client, d)
+	if err == nil {
+		err = cfg.dbQueries.MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+			ID:             d.ID,
+			LastStatusCode: sql.NullInt32{Int32: int32(statusCode), Valid: true},
+		})
+		if err != nil {
+			log.Println("Error recording webhook delivery: ", err)
+		}
+		if err := cfg.dbQueries.ResetWebhookFailures(ctx, d.WebhookID); err != nil {
+			log.Println("Error resetting webhook failures: ", err)
+		}
+		return
+	}
+
+	attempt := int(d.Attempts) + 1
+	status := "pending"
+	if attempt >= webhooks.MaxAttempts {
+		status = "failed"
+	}
+	err = cfg.dbQueries.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+		ID:             d.ID,
+		Status:         status,
+		LastStatusCode: sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0},
+		LastError:      sql.NullString{String: err.Error(), Valid: true},
+		NextAttemptAt:  time.Now().Add(webhooks.Backoff(attempt, rand.Float64())),
+	})
+	if err != nil {
+		log.Println("Error recording webhook failure: ", err)
+	}
+
+	failures, err := cfg.dbQueries.IncrementWebhookFailures(ctx, d.WebhookID)
+	if err != nil {
+		log.Println("Error counting webhook failures: ", err)
+		return
+	}
+	if failures >= webhooks.DisableAfter {
+		log.Printf("Disabling webhook %s after %d consecutive failures\n", d.WebhookID, failures)
+		if err := cfg.dbQueries.DisableWebhook(ctx, d.WebhookID); err != nil {
+			log.Println("Error disabling webhook: ", err)
+		}
+	}
+}
+
+// Returns the response status (0 if none) and an error unless it was 2xx
+func postWebhook(client *http.Client, d database.ClaimDueWebhookDeliveriesRow) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, d.Url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(webhooks.EventHeader, d.EventType)
+	req.Header.Set(webhooks.DeliveryHeader, d.ID.String())
+	req.Header.Set(webhooks.SignatureHeader, webhooks.Sign(d.Secret, time.Now(), d.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (cfg *apiConfig) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	reqBody := struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	target, err := url.Parse(reqBody.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		api.RespondWithError(w, "url must be an absolute http(s) URL", http.StatusBadRequest)
+		return
+	}
+	if err := linkpreview.CheckHost(r.Context(), target.Hostname()); err != nil {
+		api.RespondWithError(w, "url must point to a public address", http.StatusBadRequest)
+		return
+	}
+	if len(reqBody.Events) == 0 {
+		api.RespondWithError(w, "At least one event is required", http.StatusBadRequest)
+		return
+	}
+	for _, ev := range reqBody.Events {
+		if !slices.Contains(webhookEventTypes, ev) {
+			api.RespondWithError(w, "Unknown event: "+ev, http.StatusBadRequest)
+			return
+		}
+	}
+	if slices.ContainsFunc(reqBody.Events, func(ev string) bool { return slices.Contains(adminWebhookEventTypes, ev) }) {
+		role, err := cfg.dbQueries.GetUserRole(r.Context(), userIDFromContext(r.Context()))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if role != roleAdmin {
+			api.RespondWithError(w, "Only admins can subscribe to "+webhookUserRegistered, http.StatusForbidden)
+			return
+		}
+	}
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hook, err := cfg.dbQueries.CreateWebhook(context.Background(), database.CreateWebhookParams{
+		UserID: userIDFromContext(r.Context()),
+		Url:    target.String(),
+		Secret: secret,
+		Events: slices.Compact(slices.Sorted(slices.Values(reqBody.Events))),
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := toWebhookResponse(hook)
+	resp.Secret = hook.Secret
+	api.RespondWithJSON(w, resp, http.StatusCreated)
+}
+
+func (cfg *apiConfig) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := cfg.dbQueries.ListWebhooks(context.Background(), userIDFromContext(r.Context()))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]webhookResponse, len(hooks))
+	for i, hook := range hooks {
+		resp[i] = toWebhookResponse(hook)
+	}
+	api.RespondWithJSON(w, resp, http.StatusOK)
+}
+
+func (cfg *apiConfig) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := cfg.dbQueries.DeleteWebhook(context.Background(), database.DeleteWebhookParams{
+		ID:     id,
+		UserID: userIDFromContext(r.Context()),
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		api.RespondWithError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Loads the caller's webhook named in the path, writing the error response
+// itself when it cannot
+func (cfg *apiConfig) ownedWebhook(w http.ResponseWriter, r *http.Request) (database.Webhook, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return database.Webhook{}, false
+	}
+	hook, err := cfg.dbQueries.GetWebhook(context.Background(), database.GetWebhookParams{
+		ID:     id,
+		UserID: userIDFromContext(r.Context()),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "Webhook not found", http.StatusNotFound)
+		return database.Webhook{}, false
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return database.Webhook{}, false
+	}
+	return hook, true
+}
+
+func (cfg *apiConfig) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+	before, limit, err := parsePage(r)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	deliveries, err := cfg.dbQueries.ListWebhookDeliveries(context.Background(), database.ListWebhookDeliveriesParams{
+		WebhookID: hook.ID,
+		Before:    before,
+		Lim:       limit,
+	})
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.RespondWithJSON(w, deliveries, http.StatusOK)
+}
+
+// Queues a fresh copy of a past delivery. A webhook disabled for failing is
+// switched back on, since asking for a redelivery implies the receiver works.
+func (cfg *apiConfig) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+
+	delivery, err := cfg.dbQueries.RedeliverWebhookDelivery(ctx, database.RedeliverWebhookDeliveryParams{
+		ID:        deliveryID,
+		WebhookID: hook.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		api.RespondWithError(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !hook.Active {
+		if err := cfg.dbQueries.EnableWebhook(ctx, hook.ID); err != nil {
+			api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	nudgeWebhookDispatcher()
+	api.RespondWithJSON(w, delivery, http.StatusAccepted)
+}
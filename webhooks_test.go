@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOnlyAdminsSubscribeToRegistrations(t *testing.T) {
+	ts := newTestServer(t)
+	_, userToken := ts.createUser("user")
+	adminToken := ts.newAdmin("admin")
+	// An IP literal, so the public-address check needs no DNS
+	const hookURL = "https://93.184.215.14/hook"
+
+	registrations := map[string]any{"url": hookURL, "events": []string{webhookChirpCreated, webhookUserRegistered}}
+	if status, body := ts.do(http.MethodPost, "/api/webhooks", userToken, registrations); status != http.StatusForbidden {
+		t.Errorf("user subscribing to %s: status %d, want 403: %s", webhookUserRegistered, status, body)
+	}
+	ts.must(http.StatusCreated, http.MethodPost, "/api/webhooks", userToken,
+		map[string]any{"url": hookURL, "events": []string{webhookChirpCreated}}, nil)
+	ts.must(http.StatusCreated, http.MethodPost, "/api/webhooks", adminToken, registrations, nil)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lewvy/chirpy/api"
+	"github.com/Lewvy/chirpy/internal/entities"
+	"github.com/Lewvy/chirpy/internal/events"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait    = 10 * time.Second
+	wsPongWait     = 60 * time.Second
+	wsPingInterval = wsPongWait * 9 / 10
+	wsMaxMessage   = 4096
+	// Messages queued for a client before it is considered too slow
+	wsSendBuffer = 32
+
+	wsChannelTimeline      = "timeline"
+	wsChannelHashtag       = "hashtag"
+	wsChannelUser          = "user"
+	wsChannelNotifications = "notifications"
+)
+
+// Tokens can arrive in the query string, which a page on another site
+// could fill in, so browsers are only let in from our own origin. Clients
+// that send no Origin are not browsers and are let through.
+func (cfg *apiConfig) checkWSOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	o, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	base, err := url.Parse(cfg.baseURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(o.Scheme, base.Scheme) && strings.EqualFold(o.Host, base.Host)
+}
+
+type wsClientMessage struct {
+	Op      string `json:"op"`
+	Channel string `json:"channel"`
+	ID      string `json:"id,omitempty"`
+}
+
+type wsServerMessage struct {
+	Type    string          `json:"type"`
+	Channel string          `json:"channel,omitempty"`
+	ID      string          `json:"id,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+type wsSubscription struct {
+	channel string
+	id      string
+}
+
+type wsConn struct {
+	cfg    *apiConfig
+	conn   *websocket.Conn
+	userID uuid.UUID
+	send   chan []byte
+	done   chan struct{}
+	once   sync.Once
+
+	mu        sync.Mutex
+	subs      map[wsSubscription]struct{}
+	followees map[uuid.UUID]struct{}
+	// Blocked and muted authors, never relayed on any channel
+	hidden map[uuid.UUID]struct{}
+}
+
+// WebSocket endpoint carrying the same events as /api/stream, with clients
+// choosing channels through subscribe/unsubscribe messages.
+func (cfg *apiConfig) WebSocket(w http.ResponseWriter, r *http.Request) {
+	viewer := cfg.streamViewer(r)
+	if !viewer.Valid {
+		api.RespondWithError(w, "A valid token is required", http.StatusUnauthorized)
+		return
+	}
+	hidden, err := cfg.hiddenAuthors(context.Background(), viewer)
+	if err != nil {
+		api.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     cfg.checkWSOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error
+		return
+	}
+
+	c := &wsConn{
+		cfg:    cfg,
+		conn:   conn,
+		userID: viewer.UUID,
+		send:   make(chan []byte, wsSendBuffer),
+		done:   make(chan struct{}),
+		subs:   make(map[wsSubscription]struct{}),
+		hidden: hidden,
+	}
+	sub := cfg.events.Subscribe(events.TopicChirps, events.NotificationsTopic(viewer.UUID.String()))
+
+	go c.writePump()
+	go c.relay(sub)
+	c.readPump()
+}
+
+func (c *wsConn) close(code int, reason string) {
+	c.once.Do(func() {
+		close(c.done)
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+		c.conn.Close()
+	})
+}
+
+// Queues msg without blocking; a full buffer means the client is not keeping
+// up, and it is disconnected rather than allowed to stall everyone else.
+func (c *wsConn) enqueue(msg wsServerMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Println("Error encoding websocket message: ", err)
+		return
+	}
+	select {
+	case <-c.done:
+	case c.send <- data:
+	default:
+		c.close(websocket.CloseTryAgainLater, "too slow")
+	}
+}
+
+func (c *wsConn) readPump() {
+	defer c.close(websocket.CloseNormalClosure, "")
+	c.conn.SetReadLimit(wsMaxMessage)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var msg wsClientMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Println("Websocket read error: ", err)
+			}
+			return
+		}
+		c.handle(msg)
+	}
+}
+
+func (c *wsConn) writePump() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConn) handle(msg wsClientMessage) {
+	key := wsSubscription{channel: msg.Channel, id: msg.ID}
+	switch msg.Channel {
+	case wsChannelTimeline, wsChannelNotifications:
+		key.id = ""
+	case wsChannelHashtag:
+		key.id = entities.NormalizeTag(msg.ID)
+		if key.id == "" {
+			c.enqueue(wsServerMessage{Type: "error", Error: "hashtag subscriptions need an id"})
+			return
+		}
+	case wsChannelUser:
+		// Canonical form, so it compares equal to the ids in events
+		id, err := uuid.Parse(msg.ID)
+		if err != nil {
+			c.enqueue(wsServerMessage{Type: "error", Error: "user subscriptions need a user id"})
+			return
+		}
+		key.id = id.String()
+	default:
+		c.enqueue(wsServerMessage{Type: "error", Error: "unknown channel: " + msg.Channel})
+		return
+	}
+
+	switch msg.Op {
+	case "subscribe":
+		if key.channel == wsChannelTimeline {
+			ids, err := c.cfg.dbQueries.GetFolloweeIDs(context.Background(), c.userID)
+			if err != nil {
+				c.enqueue(wsServerMessage{Type: "error", Error: "could not load timeline"})
+				return
+			}
+			c.mu.Lock()
+			c.followees = toSet(append(ids, c.userID))
+			c.mu.Unlock()
+		}
+		c.mu.Lock()
+		c.subs[key] = struct{}{}
+		c.mu.Unlock()
+		c.enqueue(wsServerMessage{Type: "subscribed", Channel: key.channel, ID: key.id})
+	case "unsubscribe":
+		c.mu.Lock()
+		delete(c.subs, key)
+		c.mu.Unlock()
+		c.enqueue(wsServerMessage{Type: "unsubscribed", Channel: key.channel, ID: key.id})
+	default:
+		c.enqueue(wsServerMessage{Type: "error", Error: "unknown op: " + msg.Op})
+	}
+}
+
+// Routes broker events to whichever of the client's subscriptions match
+func (c *wsConn) relay(sub *events.Subscription) {
+	defer sub.Close()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-sub.Lagged:
+			c.close(websocket.CloseTryAgainLater, "too slow")
+			return
+		case ev := <-sub.C:
+			// Checked outside matches, which holds the lock, as it may
+			// query the database
+			if ev.Topic == events.TopicChirps && !c.cfg.canViewChirpEvent(uuid.NullUUID{UUID: c.userID, Valid: true}, ev.Data) {
+				continue
+			}
+			for _, key := range c.matches(ev) {
+				c.enqueue(wsServerMessage{Type: "event", Channel: key.channel, ID: key.id, Event: ev.Type, Data: ev.Data})
+			}
+		}
+	}
+}
+
+func (c *wsConn) matches(ev events.Event) []wsSubscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ev.Topic != events.TopicChirps {
+		key := wsSubscription{channel: wsChannelNotifications}
+		if _, ok := c.subs[key]; ok {
+			return []wsSubscription{key}
+		}
+		return nil
+	}
+
+	var chirp struct {
+		UserID     uuid.UUID `json:"user_id"`
+		Visibility string    `json:"visibility"`
+		Entities   struct {
+			Hashtags []entities.Entity `json:"hashtags"`
+		} `json:"entities"`
+	}
+	if err := json.Unmarshal(ev.Data, &chirp); err != nil {
+		return nil
+	}
+	if _, ok := c.hidden[chirp.UserID]; ok {
+		return nil
+	}
+	// User and hashtag channels are public lists, which leave unlisted
+	// chirps out
+	listed := chirp.Visibility != visibilityUnlisted
+	var out []wsSubscription
+	for key := range c.subs {
+		switch key.channel {
+		case wsChannelTimeline:
+			if _, ok := c.followees[chirp.UserID]; ok {
+				out = append(out, key)
+			}
+		case wsChannelUser:
+			if listed && key.id == chirp.UserID.String() {
+				out = append(out, key)
+			}
+		case wsChannelHashtag:
+			if !listed {
+				continue
+			}
+			for _, tag := range chirp.Entities.Hashtags {
+				if tag.Text == key.id {
+					out = append(out, key)
+					break
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckWSOrigin(t *testing.T) {
+	cfg := &apiConfig{baseURL: "https://chirpy.example"}
+	cases := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"https://chirpy.example", true},
+		{"https://CHIRPY.example", true},
+		{"http://chirpy.example", false},
+		{"https://evil.example", false},
+		{"https://chirpy.example.evil.example", false},
+		{"null", false},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/api/ws", nil)
+		if c.origin != "" {
+			r.Header.Set("Origin", c.origin)
+		}
+		if got := cfg.checkWSOrigin(r); got != c.want {
+			t.Errorf("Origin %q: got %v, want %v", c.origin, got, c.want)
+		}
+	}
+}